@@ -0,0 +1,227 @@
+package audit
+
+import (
+	"context"
+	"io"
+	"storage/domain"
+	"time"
+)
+
+type auditedRecordService struct {
+	domain.RecordService
+	audit domain.AuditService
+}
+
+// NewAuditedRecordService wraps rs so that every mutation is written to the audit log.
+func NewAuditedRecordService(rs domain.RecordService, as domain.AuditService) domain.RecordService {
+	return &auditedRecordService{
+		RecordService: rs,
+		audit:         as,
+	}
+}
+
+func (a *auditedRecordService) Set(ctx context.Context, record *domain.Record) error {
+	err := a.RecordService.Set(ctx, record)
+	a.record(ctx, domain.AuditActionRecordSet, record.Key, err)
+	return err
+}
+
+func (a *auditedRecordService) PutBlob(ctx context.Context, record *domain.Record, body io.Reader) error {
+	err := a.RecordService.PutBlob(ctx, record, body)
+	a.record(ctx, domain.AuditActionRecordSet, record.Key, err)
+	return err
+}
+
+func (a *auditedRecordService) SetTtl(ctx context.Context, req *domain.Record) (*domain.Record, error) {
+	r, err := a.RecordService.SetTtl(ctx, req)
+	a.record(ctx, domain.AuditActionRecordSetTtl, req.Key, err)
+	return r, err
+}
+
+func (a *auditedRecordService) Restore(ctx context.Context, key string, version int) (*domain.Record, error) {
+	r, err := a.RecordService.Restore(ctx, key, version)
+	a.record(ctx, domain.AuditActionRecordRestore, key, err)
+	return r, err
+}
+
+func (a *auditedRecordService) Incr(ctx context.Context, key string, delta float64) (*domain.Record, error) {
+	r, err := a.RecordService.Incr(ctx, key, delta)
+	a.record(ctx, domain.AuditActionRecordIncr, key, err)
+	return r, err
+}
+
+func (a *auditedRecordService) Patch(ctx context.Context, key string, patchType domain.PatchType, patch string) (*domain.Record, error) {
+	r, err := a.RecordService.Patch(ctx, key, patchType, patch)
+	a.record(ctx, domain.AuditActionRecordPatch, key, err)
+	return r, err
+}
+
+func (a *auditedRecordService) HashSet(ctx context.Context, key string, fields map[string]string) (int, error) {
+	n, err := a.RecordService.HashSet(ctx, key, fields)
+	a.record(ctx, domain.AuditActionHashSet, key, err)
+	return n, err
+}
+
+func (a *auditedRecordService) HashDelete(ctx context.Context, key string, fields ...string) (int, error) {
+	n, err := a.RecordService.HashDelete(ctx, key, fields...)
+	a.record(ctx, domain.AuditActionHashDelete, key, err)
+	return n, err
+}
+
+func (a *auditedRecordService) ListPush(ctx context.Context, key string, end domain.ListEnd, values ...string) (int, error) {
+	n, err := a.RecordService.ListPush(ctx, key, end, values...)
+	a.record(ctx, domain.AuditActionListPush, key, err)
+	return n, err
+}
+
+func (a *auditedRecordService) ListPop(ctx context.Context, key string, end domain.ListEnd, count int) ([]string, error) {
+	values, err := a.RecordService.ListPop(ctx, key, end, count)
+	a.record(ctx, domain.AuditActionListPop, key, err)
+	return values, err
+}
+
+func (a *auditedRecordService) SetAdd(ctx context.Context, key string, members ...string) (int, error) {
+	n, err := a.RecordService.SetAdd(ctx, key, members...)
+	a.record(ctx, domain.AuditActionSetAdd, key, err)
+	return n, err
+}
+
+func (a *auditedRecordService) SetRemove(ctx context.Context, key string, members ...string) (int, error) {
+	n, err := a.RecordService.SetRemove(ctx, key, members...)
+	a.record(ctx, domain.AuditActionSetRemove, key, err)
+	return n, err
+}
+
+func (a *auditedRecordService) ZSetAdd(ctx context.Context, key string, members ...domain.ScoredMember) (int, error) {
+	n, err := a.RecordService.ZSetAdd(ctx, key, members...)
+	a.record(ctx, domain.AuditActionZSetAdd, key, err)
+	return n, err
+}
+
+func (a *auditedRecordService) ZSetIncr(ctx context.Context, key, member string, by float64) (float64, error) {
+	score, err := a.RecordService.ZSetIncr(ctx, key, member, by)
+	a.record(ctx, domain.AuditActionZSetIncr, key, err)
+	return score, err
+}
+
+func (a *auditedRecordService) ZSetPop(ctx context.Context, key string, max bool, count int) ([]domain.ScoredMember, error) {
+	members, err := a.RecordService.ZSetPop(ctx, key, max, count)
+	a.record(ctx, domain.AuditActionZSetPop, key, err)
+	return members, err
+}
+
+func (a *auditedRecordService) ZSetRemove(ctx context.Context, key string, members ...string) (int, error) {
+	n, err := a.RecordService.ZSetRemove(ctx, key, members...)
+	a.record(ctx, domain.AuditActionZSetRemove, key, err)
+	return n, err
+}
+
+func (a *auditedRecordService) StreamAdd(ctx context.Context, key string, values ...string) ([]int64, error) {
+	ids, err := a.RecordService.StreamAdd(ctx, key, values...)
+	a.record(ctx, domain.AuditActionStreamAdd, key, err)
+	return ids, err
+}
+
+func (a *auditedRecordService) StreamCreateGroup(ctx context.Context, key, group string, after int64) error {
+	err := a.RecordService.StreamCreateGroup(ctx, key, group, after)
+	a.record(ctx, domain.AuditActionStreamGroupCreate, key, err)
+	return err
+}
+
+func (a *auditedRecordService) StreamDeleteGroup(ctx context.Context, key, group string) error {
+	err := a.RecordService.StreamDeleteGroup(ctx, key, group)
+	a.record(ctx, domain.AuditActionStreamGroupDelete, key, err)
+	return err
+}
+
+func (a *auditedRecordService) StreamAck(ctx context.Context, key, group string, ids ...int64) (int, error) {
+	n, err := a.RecordService.StreamAck(ctx, key, group, ids...)
+	a.record(ctx, domain.AuditActionStreamAck, key, err)
+	return n, err
+}
+
+func (a *auditedRecordService) StreamClaim(ctx context.Context, key, group, consumer string, minIdle time.Duration, count int) ([]domain.StreamEntry, error) {
+	entries, err := a.RecordService.StreamClaim(ctx, key, group, consumer, minIdle, count)
+	a.record(ctx, domain.AuditActionStreamClaim, key, err)
+	return entries, err
+}
+
+func (a *auditedRecordService) StreamTrim(ctx context.Context, key string, maxLength int, before time.Time) (int, error) {
+	n, err := a.RecordService.StreamTrim(ctx, key, maxLength, before)
+	a.record(ctx, domain.AuditActionStreamTrim, key, err)
+	return n, err
+}
+
+func (a *auditedRecordService) CreateIndex(ctx context.Context, index *domain.RecordIndex) error {
+	err := a.RecordService.CreateIndex(ctx, index)
+	a.audit.Record(ctx, &domain.AuditEvent{
+		Action:  domain.AuditActionIndexCreate,
+		Key:     index.Prefix,
+		Detail:  index.Name + " " + index.Path,
+		Outcome: outcome(err),
+	})
+	return err
+}
+
+func (a *auditedRecordService) DropIndex(ctx context.Context, name string) error {
+	err := a.RecordService.DropIndex(ctx, name)
+	a.audit.Record(ctx, &domain.AuditEvent{
+		Action:  domain.AuditActionIndexDrop,
+		Detail:  name,
+		Outcome: outcome(err),
+	})
+	return err
+}
+
+func (a *auditedRecordService) record(ctx context.Context, action, key string, err error) {
+	a.audit.Record(ctx, &domain.AuditEvent{
+		Action:  action,
+		Key:     key,
+		Outcome: outcome(err),
+	})
+}
+
+type auditedUserService struct {
+	domain.UserService
+	audit domain.AuditService
+}
+
+// NewAuditedUserService wraps us so that registrations and logins are written to the audit log.
+func NewAuditedUserService(us domain.UserService, as domain.AuditService) domain.UserService {
+	return &auditedUserService{
+		UserService: us,
+		audit:       as,
+	}
+}
+
+func (a *auditedUserService) Register(ctx context.Context, req *domain.User) (*domain.User, error) {
+	u, err := a.UserService.Register(ctx, req)
+
+	event := &domain.AuditEvent{
+		Action:  domain.AuditActionUserRegister,
+		Detail:  req.Email,
+		Outcome: outcome(err),
+	}
+	if err == nil {
+		event.UserId = u.Id
+	}
+	a.audit.Record(ctx, event)
+
+	return u, err
+}
+
+func (a *auditedUserService) Login(ctx context.Context, req *domain.User) (string, error) {
+	token, err := a.UserService.Login(ctx, req)
+
+	event := &domain.AuditEvent{
+		Action:  domain.AuditActionUserLogin,
+		Detail:  req.Email,
+		Outcome: outcome(err),
+	}
+	if err == nil {
+		event.UserId, _ = a.UserService.VerifyToken(token)
+	}
+	a.audit.Record(ctx, event)
+
+	return token, err
+}
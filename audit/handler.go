@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"storage/domain"
+	"time"
+)
+
+const exportPageSize = 1000
+
+type handler struct {
+	service domain.AuditService
+}
+
+func NewAuditController(rg *gin.RouterGroup, as domain.AuditService) {
+	h := &handler{service: as}
+
+	rg.GET("", h.list)
+	rg.GET("export", h.export)
+}
+
+// ClientIpMiddleware stores the source ip of the request in its context so
+// that it can be attached to audit events.
+func ClientIpMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(domain.ContextWithClientIp(c.Request.Context(), c.ClientIP()))
+		c.Next()
+	}
+}
+
+// @Summary list audit events
+// @Accept  json
+// @Produce  json
+// @Param   user_id query int false "actor user id"
+// @Param   action query string false "action, e.g. record.set"
+// @Param   key query string false "record key"
+// @Param   outcome query string false "success or failure"
+// @Param   from query string false "RFC3339 lower bound (inclusive)"
+// @Param   to query string false "RFC3339 upper bound (exclusive)"
+// @Param   after_id query int false "return events with a greater id"
+// @Param   limit query int false "page size, at most 1000"
+// @Success 200 {object} []response
+// @Failure 400 {string} string
+// @Failure 403 {string} string
+// @Router /admin/audit [get]
+func (h *handler) list(c *gin.Context) {
+	var req listRequest
+	if err := c.BindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	events, err := h.service.List(c.Request.Context(), req.toFilter())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := make([]*response, 0, len(events))
+	for _, e := range events {
+		res = append(res, toResponse(e))
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// @Summary export audit events as JSON lines
+// @Accept  json
+// @Produce  application/x-ndjson
+// @Param   user_id query int false "actor user id"
+// @Param   action query string false "action, e.g. record.set"
+// @Param   key query string false "record key"
+// @Param   outcome query string false "success or failure"
+// @Param   from query string false "RFC3339 lower bound (inclusive)"
+// @Param   to query string false "RFC3339 upper bound (exclusive)"
+// @Success 200 {string} string
+// @Failure 400 {string} string
+// @Failure 403 {string} string
+// @Router /admin/audit/export [get]
+func (h *handler) export(c *gin.Context) {
+	var req listRequest
+	if err := c.BindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filter := req.toFilter()
+	filter.Limit = exportPageSize
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	c.Status(http.StatusOK)
+
+	enc := json.NewEncoder(c.Writer)
+	for {
+		events, err := h.service.List(c.Request.Context(), filter)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		for _, e := range events {
+			if err = enc.Encode(toResponse(e)); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+
+		if len(events) < exportPageSize {
+			return
+		}
+		filter.AfterId = events[len(events)-1].Id
+	}
+}
+
+type listRequest struct {
+	UserId  int       `form:"user_id"`
+	Action  string    `form:"action"`
+	Key     string    `form:"key"`
+	Outcome string    `form:"outcome" binding:"omitempty,oneof=success failure"`
+	From    time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To      time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	AfterId int       `form:"after_id"`
+	Limit   int       `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+func (l *listRequest) toFilter() *domain.AuditFilter {
+	return &domain.AuditFilter{
+		UserId:  l.UserId,
+		Action:  l.Action,
+		Key:     l.Key,
+		Outcome: l.Outcome,
+		From:    l.From,
+		To:      l.To,
+		AfterId: l.AfterId,
+		Limit:   l.Limit,
+	}
+}
+
+type response struct {
+	Id        int       `json:"id"`
+	UserId    int       `json:"user_id,omitempty"`
+	Action    string    `json:"action"`
+	Key       string    `json:"key,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	SourceIp  string    `json:"source_ip,omitempty"`
+	Outcome   string    `json:"outcome"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func toResponse(e *domain.AuditEvent) *response {
+	return &response{
+		Id:        e.Id,
+		UserId:    e.UserId,
+		Action:    e.Action,
+		Key:       e.Key,
+		Detail:    e.Detail,
+		SourceIp:  e.SourceIp,
+		Outcome:   e.Outcome,
+		CreatedAt: e.CreatedAt,
+	}
+}
@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http/httptest"
+	"net/url"
+	"storage/domain"
+	"storage/domain/mocks"
+	"storage/util"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_handler_list(t *testing.T) {
+	events := []*domain.AuditEvent{
+		{Id: 1, UserId: 7, Action: domain.AuditActionRecordSet, Key: "key", Outcome: domain.AuditOutcomeSuccess, CreatedAt: time.Now().UTC()},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockService := new(mocks.MockAuditService)
+		mockService.On("List", mock.Anything, &domain.AuditFilter{UserId: 7, Action: domain.AuditActionRecordSet}).
+			Return(events, nil).Once()
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonGet(ctx, []gin.Param{}, url.Values{"user_id": {"7"}, "action": {domain.AuditActionRecordSet}})
+
+		h := handler{service: mockService}
+		h.list(ctx)
+
+		var res []*response
+		err := json.Unmarshal(w.Body.Bytes(), &res)
+
+		assert.Equal(t, 200, w.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, toResponse(events[0]), res[0])
+	})
+
+	t.Run("invalid outcome", func(t *testing.T) {
+		mockService := new(mocks.MockAuditService)
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonGet(ctx, []gin.Param{}, url.Values{"outcome": {"maybe"}})
+
+		h := handler{service: mockService}
+		h.list(ctx)
+
+		assert.Equal(t, 400, w.Code)
+	})
+
+	t.Run("service error", func(t *testing.T) {
+		mockService := new(mocks.MockAuditService)
+		mockService.On("List", mock.Anything, mock.Anything).Return(nil, errors.New("")).Once()
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonGet(ctx, []gin.Param{}, url.Values{})
+
+		h := handler{service: mockService}
+		h.list(ctx)
+
+		assert.Equal(t, 400, w.Code)
+	})
+}
+
+func Test_handler_export(t *testing.T) {
+	events := []*domain.AuditEvent{
+		{Id: 1, Action: domain.AuditActionUserLogin, Outcome: domain.AuditOutcomeSuccess},
+		{Id: 2, Action: domain.AuditActionUserLogin, Outcome: domain.AuditOutcomeFailure},
+	}
+
+	mockService := new(mocks.MockAuditService)
+	mockService.On("List", mock.Anything, &domain.AuditFilter{Action: domain.AuditActionUserLogin, Limit: exportPageSize}).
+		Return(events, nil).Once()
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonGet(ctx, []gin.Param{}, url.Values{"action": {domain.AuditActionUserLogin}})
+
+	h := handler{service: mockService}
+	h.export(ctx)
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	if assert.Len(t, lines, 2) {
+		var res response
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &res))
+		assert.Equal(t, toResponse(events[1]), &res)
+	}
+	mockService.AssertExpectations(t)
+}
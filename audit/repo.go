@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"storage/domain"
+	"time"
+)
+
+const defaultListLimit = 100
+
+type auditEvent struct {
+	ID        int
+	UserId    int    `gorm:"index"`
+	Action    string `gorm:"index"`
+	Key       string `gorm:"index"`
+	Detail    string
+	SourceIp  string
+	Outcome   string
+	CreatedAt time.Time `gorm:"index"`
+}
+
+type postgresRepo struct {
+	db *gorm.DB
+}
+
+func NewPostgresAuditRepository(db *gorm.DB) domain.AuditRepository {
+	return &postgresRepo{db: db}
+}
+
+func (p *postgresRepo) Create(ctx context.Context, event *domain.AuditEvent) error {
+	e := convertToModel(event)
+	if err := p.db.WithContext(ctx).Create(e).Error; err != nil {
+		return err
+	}
+
+	event.Id = e.ID
+	event.CreatedAt = e.CreatedAt
+	return nil
+}
+
+func (p *postgresRepo) List(ctx context.Context, filter *domain.AuditFilter) ([]*domain.AuditEvent, error) {
+	q := p.db.WithContext(ctx)
+	if filter.UserId != 0 {
+		q = q.Where("user_id = ?", filter.UserId)
+	}
+	if filter.Action != "" {
+		q = q.Where("action = ?", filter.Action)
+	}
+	if filter.Key != "" {
+		q = q.Where("key = ?", filter.Key)
+	}
+	if filter.Outcome != "" {
+		q = q.Where("outcome = ?", filter.Outcome)
+	}
+	if !filter.From.IsZero() {
+		q = q.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		q = q.Where("created_at < ?", filter.To)
+	}
+	if filter.AfterId != 0 {
+		q = q.Where("id > ?", filter.AfterId)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+
+	var rows []auditEvent
+	err := q.Order("id").Limit(limit).Find(&rows).Error
+
+	var events []*domain.AuditEvent
+	for _, e := range rows {
+		events = append(events, e.toAuditEvent())
+	}
+	return events, err
+}
+
+func convertToModel(e *domain.AuditEvent) *auditEvent {
+	return &auditEvent{
+		UserId:   e.UserId,
+		Action:   e.Action,
+		Key:      e.Key,
+		Detail:   e.Detail,
+		SourceIp: e.SourceIp,
+		Outcome:  e.Outcome,
+	}
+}
+
+func (e *auditEvent) toAuditEvent() *domain.AuditEvent {
+	return &domain.AuditEvent{
+		Id:        e.ID,
+		UserId:    e.UserId,
+		Action:    e.Action,
+		Key:       e.Key,
+		Detail:    e.Detail,
+		SourceIp:  e.SourceIp,
+		Outcome:   e.Outcome,
+		CreatedAt: e.CreatedAt,
+	}
+}
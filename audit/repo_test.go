@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"storage/domain"
+	"testing"
+	"time"
+)
+
+func initDB() (sqlmock.Sqlmock, error, *postgresRepo) {
+	db, mock, err := sqlmock.New()
+	gormDb, err := gorm.Open(postgres.New(postgres.Config{Conn: db}))
+	repo := postgresRepo{db: gormDb}
+	return mock, err, &repo
+}
+
+func TestPostgresRepo_Create(t *testing.T) {
+	event := &domain.AuditEvent{
+		UserId:   7,
+		Action:   domain.AuditActionRecordSet,
+		Key:      "key",
+		SourceIp: "10.0.0.1",
+		Outcome:  domain.AuditOutcomeSuccess,
+	}
+
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "audit_events"`).
+		WithArgs(event.UserId, event.Action, event.Key, "", event.SourceIp, event.Outcome, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectCommit()
+
+	err = repo.Create(context.TODO(), event)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, event.Id)
+	assert.False(t, event.CreatedAt.IsZero())
+}
+
+func TestPostgresRepo_List(t *testing.T) {
+	from := time.Now().Add(-time.Hour)
+	rows := sqlmock.NewRows([]string{"id", "user_id", "action", "key", "outcome"}).
+		AddRow(4, 7, domain.AuditActionRecordSet, "key", domain.AuditOutcomeSuccess)
+
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	query := `SELECT \* FROM "audit_events" WHERE user_id = \$1 AND key = \$2 AND created_at >= \$3 AND id > \$4 ORDER BY id LIMIT 10`
+	mock.ExpectQuery(query).
+		WithArgs(7, "key", from, 3).
+		WillReturnRows(rows)
+
+	events, err := repo.List(context.TODO(), &domain.AuditFilter{
+		UserId:  7,
+		Key:     "key",
+		From:    from,
+		AfterId: 3,
+		Limit:   10,
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, 4, events[0].Id)
+		assert.Equal(t, domain.AuditActionRecordSet, events[0].Action)
+	}
+}
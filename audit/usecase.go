@@ -0,0 +1,42 @@
+package audit
+
+import (
+	"context"
+	"log"
+	"storage/domain"
+)
+
+type service struct {
+	repo domain.AuditRepository
+}
+
+func NewAuditService(repo domain.AuditRepository) domain.AuditService {
+	return &service{repo: repo}
+}
+
+// Record appends event to the audit log. The actor and source ip are taken
+// from ctx when not set on the event. Failures are logged rather than
+// returned so that auditing never changes the outcome of the audited call.
+func (s *service) Record(ctx context.Context, event *domain.AuditEvent) {
+	if event.UserId == 0 {
+		event.UserId = domain.UserIdFromContext(ctx)
+	}
+	if event.SourceIp == "" {
+		event.SourceIp = domain.ClientIpFromContext(ctx)
+	}
+
+	if err := s.repo.Create(ctx, event); err != nil {
+		log.Printf("audit: failed to record %s event: %v\n", event.Action, err)
+	}
+}
+
+func (s *service) List(ctx context.Context, filter *domain.AuditFilter) ([]*domain.AuditEvent, error) {
+	return s.repo.List(ctx, filter)
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return domain.AuditOutcomeFailure
+	}
+	return domain.AuditOutcomeSuccess
+}
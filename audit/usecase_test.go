@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"storage/domain"
+	"storage/domain/mocks"
+	"testing"
+)
+
+func Test_service_Record(t *testing.T) {
+	t.Run("actor and ip from context", func(t *testing.T) {
+		repo := new(mocks.MockAuditRepository)
+		expected := &domain.AuditEvent{
+			UserId:   7,
+			Action:   domain.AuditActionRecordSet,
+			Key:      "key",
+			SourceIp: "10.0.0.1",
+			Outcome:  domain.AuditOutcomeSuccess,
+		}
+		repo.On("Create", mock.Anything, expected).Return(nil).Once()
+
+		ctx := domain.ContextWithUserId(context.TODO(), 7)
+		ctx = domain.ContextWithClientIp(ctx, "10.0.0.1")
+
+		s := NewAuditService(repo)
+		s.Record(ctx, &domain.AuditEvent{
+			Action:  domain.AuditActionRecordSet,
+			Key:     "key",
+			Outcome: domain.AuditOutcomeSuccess,
+		})
+
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("repository failure is swallowed", func(t *testing.T) {
+		repo := new(mocks.MockAuditRepository)
+		repo.On("Create", mock.Anything, mock.Anything).Return(errors.New("db down")).Once()
+
+		s := NewAuditService(repo)
+		assert.NotPanics(t, func() {
+			s.Record(context.TODO(), &domain.AuditEvent{Action: domain.AuditActionUserLogin})
+		})
+
+		repo.AssertExpectations(t)
+	})
+}
+
+func Test_auditedRecordService_Set(t *testing.T) {
+	mockRecord := &domain.Record{Key: "key", Value: "val"}
+
+	t.Run("success", func(t *testing.T) {
+		rs := new(mocks.MockRecordService)
+		as := new(mocks.MockAuditService)
+		rs.On("Set", mock.Anything, mockRecord).Return(nil).Once()
+		as.On("Record", mock.Anything, &domain.AuditEvent{
+			Action:  domain.AuditActionRecordSet,
+			Key:     mockRecord.Key,
+			Outcome: domain.AuditOutcomeSuccess,
+		}).Once()
+
+		err := NewAuditedRecordService(rs, as).Set(context.TODO(), mockRecord)
+		assert.NoError(t, err)
+
+		rs.AssertExpectations(t)
+		as.AssertExpectations(t)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		rs := new(mocks.MockRecordService)
+		as := new(mocks.MockAuditService)
+		rs.On("Set", mock.Anything, mockRecord).Return(errors.New("")).Once()
+		as.On("Record", mock.Anything, &domain.AuditEvent{
+			Action:  domain.AuditActionRecordSet,
+			Key:     mockRecord.Key,
+			Outcome: domain.AuditOutcomeFailure,
+		}).Once()
+
+		err := NewAuditedRecordService(rs, as).Set(context.TODO(), mockRecord)
+		assert.Error(t, err)
+
+		as.AssertExpectations(t)
+	})
+
+	t.Run("reads are not audited", func(t *testing.T) {
+		rs := new(mocks.MockRecordService)
+		as := new(mocks.MockAuditService)
+		rs.On("Get", mock.Anything, "key").Return(mockRecord, nil).Once()
+
+		r, err := NewAuditedRecordService(rs, as).Get(context.TODO(), "key")
+		assert.NoError(t, err)
+		assert.Equal(t, mockRecord, r)
+
+		as.AssertNotCalled(t, "Record", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/allegro/bigcache/v3"
+	"storage/metrics"
+)
+
+type bigCache struct {
+	*bigcache.BigCache
+}
+
+// NewBigCache creates a cache backed by bigcache, which keeps entries off
+// the garbage collected heap and evicts them by age only.
+func NewBigCache(config Config) (Cache, error) {
+	c, err := bigcache.New(context.Background(), getBigCacheConfig(config))
+	if err != nil {
+		return nil, err
+	}
+	return &bigCache{BigCache: c}, nil
+}
+
+func getBigCacheConfig(config Config) bigcache.Config {
+	c := bigcache.DefaultConfig(config.LifeWindow)
+	c.MaxEntriesInWindow = config.MaxEntries
+	c.HardMaxCacheSize = config.MaxSizeMB
+	c.OnRemoveWithReason = func(_ string, _ []byte, reason bigcache.RemoveReason) {
+		metrics.CacheEvictions.WithLabelValues(removeReasonLabel(reason)).Inc()
+	}
+	return c
+}
+
+func removeReasonLabel(reason bigcache.RemoveReason) string {
+	switch reason {
+	case bigcache.Expired:
+		return "expired"
+	case bigcache.NoSpace:
+		return "no_space"
+	default:
+		return "deleted"
+	}
+}
+
+func (c *bigCache) Get(key string) ([]byte, error) {
+	value, err := c.BigCache.Get(key)
+	if errors.Is(err, bigcache.ErrEntryNotFound) {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (c *bigCache) Delete(key string) error {
+	err := c.BigCache.Delete(key)
+	if errors.Is(err, bigcache.ErrEntryNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (c *bigCache) Stats() metrics.CacheStats {
+	stats := c.BigCache.Stats()
+	return metrics.CacheStats{
+		Hits:       stats.Hits,
+		Misses:     stats.Misses,
+		DelHits:    stats.DelHits,
+		DelMisses:  stats.DelMisses,
+		Collisions: stats.Collisions,
+		Entries:    c.Len(),
+		Capacity:   c.Capacity(),
+	}
+}
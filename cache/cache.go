@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"storage/metrics"
+	"time"
+)
+
+const (
+	TypeBigCache = "bigcache"
+	TypeLRU      = "lru"
+	TypeLFU      = "lfu"
+	TypeNone     = "none"
+)
+
+var ErrNotFound = errors.New("cache entry not found")
+
+// Cache is an in-memory byte cache. Entries may be dropped at any time, e.g.
+// once they outlive the life window or to make room for new ones.
+type Cache interface {
+	// Get returns ErrNotFound for missing entries.
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte) error
+	Delete(key string) error
+	// Reset drops every entry.
+	Reset() error
+	Stats() metrics.CacheStats
+	Close() error
+}
+
+type Config struct {
+	// Type selects the implementation: TypeBigCache, TypeLRU, TypeLFU or TypeNone.
+	Type string
+	// LifeWindow is how long an entry is kept after it was set.
+	LifeWindow time.Duration
+	// MaxEntries bounds the number of entries of the lru and lfu caches; for
+	// bigcache it only sizes the initial allocation.
+	MaxEntries int
+	// MaxSizeMB bounds the memory used by keys and values.
+	MaxSizeMB int
+	// Rules decide per key prefix whether keys are cached at all.
+	Rules []Rule
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Type:       TypeBigCache,
+		LifeWindow: 10 * time.Minute,
+		MaxEntries: 10000,
+		MaxSizeMB:  32,
+	}
+}
+
+// New creates the cache selected by config, applying its rules.
+func New(config Config) (Cache, error) {
+	var c Cache
+	var err error
+	switch config.Type {
+	case "", TypeBigCache:
+		c, err = NewBigCache(config)
+	case TypeLRU:
+		c = NewLRUCache(config)
+	case TypeLFU:
+		c = NewLFUCache(config)
+	case TypeNone:
+		c = NewNoopCache()
+	default:
+		err = fmt.Errorf("unknown cache type: %s", config.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(config.Rules) > 0 {
+		c = WithRules(c, config.Rules)
+	}
+	return c, nil
+}
@@ -0,0 +1,287 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"errors"
+	"storage/metrics"
+	"sync"
+	"time"
+)
+
+var ErrEntryTooLarge = errors.New("cache entry is larger than the cache")
+
+type entry struct {
+	key   string
+	value []byte
+	setAt time.Time
+
+	// bookkeeping of the eviction policies
+	element *list.Element
+	hits    int
+	tick    uint64
+	index   int
+}
+
+func (e *entry) size() int {
+	return len(e.key) + len(e.value)
+}
+
+// evictionPolicy picks the entry to drop when a memoryCache is full.
+type evictionPolicy interface {
+	add(e *entry)
+	touch(e *entry)
+	remove(e *entry)
+	victim() *entry
+	reset()
+}
+
+type memoryCache struct {
+	mu       sync.Mutex
+	config   Config
+	maxBytes int
+	policy   evictionPolicy
+	entries  map[string]*entry
+	bytes    int
+	stats    metrics.CacheStats
+	now      func() time.Time
+}
+
+// NewLRUCache creates a size bounded cache that evicts the least recently
+// used entry when full.
+func NewLRUCache(config Config) Cache {
+	return newMemoryCache(config, newLRUPolicy())
+}
+
+// NewLFUCache creates a size bounded cache that evicts the least frequently
+// used entry when full, the least recently used one among equals. Hit
+// counts are halved periodically, so frequency reflects recent use.
+func NewLFUCache(config Config) Cache {
+	return newMemoryCache(config, newLFUPolicy())
+}
+
+func newMemoryCache(config Config, policy evictionPolicy) *memoryCache {
+	return &memoryCache{
+		config:   config,
+		maxBytes: config.MaxSizeMB << 20,
+		policy:   policy,
+		entries:  map[string]*entry{},
+		now:      time.Now,
+	}
+}
+
+func (c *memoryCache) Get(key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if ok && c.expired(e) {
+		c.remove(e, "expired")
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		return nil, ErrNotFound
+	}
+
+	c.stats.Hits++
+	c.policy.touch(e)
+	return e.value, nil
+}
+
+func (c *memoryCache) expired(e *entry) bool {
+	return c.config.LifeWindow > 0 && c.now().Sub(e.setAt) > c.config.LifeWindow
+}
+
+func (c *memoryCache) Set(key string, value []byte) error {
+	e := &entry{key: key, value: value, setAt: c.now()}
+	if c.maxBytes > 0 && e.size() > c.maxBytes {
+		return ErrEntryTooLarge
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if old, ok := c.entries[key]; ok {
+		c.policy.remove(old)
+		delete(c.entries, key)
+		c.bytes -= old.size()
+	}
+
+	for len(c.entries) > 0 && c.full(e) {
+		c.remove(c.policy.victim(), "no_space")
+	}
+
+	c.entries[key] = e
+	c.bytes += e.size()
+	c.policy.add(e)
+	return nil
+}
+
+// full reports whether e only fits after an eviction.
+func (c *memoryCache) full(e *entry) bool {
+	if c.config.MaxEntries > 0 && len(c.entries) >= c.config.MaxEntries {
+		return true
+	}
+	return c.maxBytes > 0 && c.bytes+e.size() > c.maxBytes
+}
+
+func (c *memoryCache) remove(e *entry, reason string) {
+	c.policy.remove(e)
+	delete(c.entries, e.key)
+	c.bytes -= e.size()
+	metrics.CacheEvictions.WithLabelValues(reason).Inc()
+}
+
+func (c *memoryCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.stats.DelHits++
+		c.remove(e, "deleted")
+	} else {
+		c.stats.DelMisses++
+	}
+	return nil
+}
+
+func (c *memoryCache) Reset() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = map[string]*entry{}
+	c.bytes = 0
+	c.policy.reset()
+	return nil
+}
+
+func (c *memoryCache) Stats() metrics.CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	stats.Capacity = c.bytes
+	return stats
+}
+
+func (c *memoryCache) Close() error {
+	return c.Reset()
+}
+
+type lruPolicy struct {
+	order *list.List
+}
+
+func newLRUPolicy() *lruPolicy {
+	return &lruPolicy{order: list.New()}
+}
+
+func (p *lruPolicy) add(e *entry) {
+	e.element = p.order.PushFront(e)
+}
+
+func (p *lruPolicy) touch(e *entry) {
+	p.order.MoveToFront(e.element)
+}
+
+func (p *lruPolicy) remove(e *entry) {
+	p.order.Remove(e.element)
+}
+
+func (p *lruPolicy) victim() *entry {
+	return p.order.Back().Value.(*entry)
+}
+
+func (p *lruPolicy) reset() {
+	p.order.Init()
+}
+
+// lfuAgingSamples is how many hits per entry the lfu policy counts before
+// it halves every count, so that entries that stopped being used lose their
+// rank to the ones used now.
+const lfuAgingSamples = 10
+
+type lfuPolicy struct {
+	entries lfuHeap
+	ticks   uint64
+	samples int
+}
+
+func newLFUPolicy() *lfuPolicy {
+	return &lfuPolicy{}
+}
+
+func (p *lfuPolicy) add(e *entry) {
+	p.ticks++
+	e.tick = p.ticks
+	heap.Push(&p.entries, e)
+}
+
+func (p *lfuPolicy) touch(e *entry) {
+	p.ticks++
+	e.hits++
+	e.tick = p.ticks
+	heap.Fix(&p.entries, e.index)
+
+	if p.samples++; p.samples >= lfuAgingSamples*len(p.entries) {
+		p.age()
+	}
+}
+
+// age halves the hits of every entry.
+func (p *lfuPolicy) age() {
+	for _, e := range p.entries {
+		e.hits /= 2
+	}
+	heap.Init(&p.entries)
+	p.samples = 0
+}
+
+func (p *lfuPolicy) remove(e *entry) {
+	heap.Remove(&p.entries, e.index)
+}
+
+func (p *lfuPolicy) victim() *entry {
+	return p.entries[0]
+}
+
+func (p *lfuPolicy) reset() {
+	p.entries = nil
+	p.samples = 0
+}
+
+// lfuHeap orders entries by hits, then by last use.
+type lfuHeap []*entry
+
+func (h lfuHeap) Len() int {
+	return len(h)
+}
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].hits != h[j].hits {
+		return h[i].hits < h[j].hits
+	}
+	return h[i].tick < h[j].tick
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x any) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	c := NewLRUCache(Config{MaxEntries: 2})
+
+	assert.NoError(t, c.Set("a", []byte("1")))
+	assert.NoError(t, c.Set("b", []byte("2")))
+	_, err := c.Get("a")
+	assert.NoError(t, err)
+
+	// b is the least recently used entry
+	assert.NoError(t, c.Set("c", []byte("3")))
+	_, err = c.Get("b")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	value, err := c.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1"), value)
+	value, err = c.Get("c")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("3"), value)
+
+	stats := c.Stats()
+	assert.Equal(t, int64(3), stats.Hits)
+	assert.Equal(t, int64(1), stats.Misses)
+	assert.Equal(t, 2, stats.Entries)
+}
+
+func TestLFUCache(t *testing.T) {
+	c := NewLFUCache(Config{MaxEntries: 2})
+
+	assert.NoError(t, c.Set("a", []byte("1")))
+	assert.NoError(t, c.Set("b", []byte("2")))
+	for i := 0; i < 3; i++ {
+		_, err := c.Get("a")
+		assert.NoError(t, err)
+	}
+	_, err := c.Get("b")
+	assert.NoError(t, err)
+
+	// b is used less often than a even though it was used last
+	assert.NoError(t, c.Set("c", []byte("3")))
+	_, err = c.Get("b")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = c.Get("a")
+	assert.NoError(t, err)
+
+	// the new entry has the fewest hits and goes first
+	assert.NoError(t, c.Set("d", []byte("4")))
+	_, err = c.Get("c")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestLFUCache_aging(t *testing.T) {
+	c := NewLFUCache(Config{MaxEntries: 4})
+	read := func(key string, times int) {
+		for i := 0; i < times; i++ {
+			_, err := c.Get(key)
+			assert.NoError(t, err)
+		}
+	}
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		assert.NoError(t, c.Set(key, []byte(key)))
+	}
+	read("a", 1000)
+	read("b", 1000)
+
+	// the reads move to c, d and then new entries, which must push out a and
+	// b rather than each other
+	read("c", 100)
+	read("d", 100)
+	assert.NoError(t, c.Set("e", []byte("e")))
+	read("e", 100)
+	assert.NoError(t, c.Set("f", []byte("f")))
+
+	for _, key := range []string{"a", "b"} {
+		_, err := c.Get(key)
+		assert.ErrorIs(t, err, ErrNotFound, key)
+	}
+	for _, key := range []string{"c", "d", "e", "f"} {
+		_, err := c.Get(key)
+		assert.NoError(t, err, key)
+	}
+}
+
+func Test_memoryCache_size(t *testing.T) {
+	c := newMemoryCache(Config{}, newLRUPolicy())
+	c.maxBytes = 10
+
+	assert.NoError(t, c.Set("a", []byte("1234")))
+	assert.NoError(t, c.Set("b", []byte("1234")))
+	assert.NoError(t, c.Set("c", []byte("1234")))
+	_, err := c.Get("a")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 10, c.Stats().Capacity)
+
+	assert.ErrorIs(t, c.Set("d", []byte("0123456789")), ErrEntryTooLarge)
+}
+
+func Test_memoryCache_lifeWindow(t *testing.T) {
+	now := time.Now()
+	c := newMemoryCache(Config{LifeWindow: time.Minute}, newLRUPolicy())
+	c.now = func() time.Time { return now }
+
+	assert.NoError(t, c.Set("a", []byte("1")))
+	_, err := c.Get("a")
+	assert.NoError(t, err)
+
+	now = now.Add(2 * time.Minute)
+	_, err = c.Get("a")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 0, c.Stats().Entries)
+}
+
+func Test_memoryCache_DeleteReset(t *testing.T) {
+	c := NewLRUCache(Config{})
+
+	assert.NoError(t, c.Set("a", []byte("1")))
+	assert.NoError(t, c.Set("b", []byte("2")))
+	assert.NoError(t, c.Delete("a"))
+	assert.NoError(t, c.Delete("a"))
+	_, err := c.Get("a")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	stats := c.Stats()
+	assert.Equal(t, int64(1), stats.DelHits)
+	assert.Equal(t, int64(1), stats.DelMisses)
+
+	assert.NoError(t, c.Reset())
+	_, err = c.Get("b")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, c.Set("b", []byte("2")))
+	assert.Equal(t, 1, c.Stats().Entries)
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rule decides whether keys starting with Prefix are cached. When several
+// rules match a key the one with the longest prefix wins.
+type Rule struct {
+	Prefix string
+	Cache  bool
+}
+
+// ParseRules parses rules written as comma separated prefix=on|off pairs,
+// e.g. "secret/=off,secret/public/=on".
+func ParseRules(s string) ([]Rule, error) {
+	var rules []Rule
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		prefix, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid cache rule %q: expected prefix=on|off", part)
+		}
+		switch strings.TrimSpace(value) {
+		case "on":
+			rules = append(rules, Rule{Prefix: prefix, Cache: true})
+		case "off":
+			rules = append(rules, Rule{Prefix: prefix, Cache: false})
+		default:
+			return nil, fmt.Errorf("invalid cache rule %q: expected prefix=on|off", part)
+		}
+	}
+	return rules, nil
+}
+
+type ruleCache struct {
+	Cache
+	rules []Rule
+}
+
+// WithRules makes c skip the keys its rules exclude. Deletes always reach
+// c, so excluding a prefix never leaves stale entries behind.
+func WithRules(c Cache, rules []Rule) Cache {
+	return &ruleCache{Cache: c, rules: rules}
+}
+
+func (c *ruleCache) cacheable(key string) bool {
+	cacheable, longest := true, -1
+	for _, rule := range c.rules {
+		if len(rule.Prefix) > longest && strings.HasPrefix(key, rule.Prefix) {
+			cacheable, longest = rule.Cache, len(rule.Prefix)
+		}
+	}
+	return cacheable
+}
+
+func (c *ruleCache) Get(key string) ([]byte, error) {
+	if !c.cacheable(key) {
+		return nil, ErrNotFound
+	}
+	return c.Cache.Get(key)
+}
+
+func (c *ruleCache) Set(key string, value []byte) error {
+	if !c.cacheable(key) {
+		return nil
+	}
+	return c.Cache.Set(key, value)
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("secret/=off, secret/public/=on")
+	assert.NoError(t, err)
+	assert.Equal(t, []Rule{
+		{Prefix: "secret/", Cache: false},
+		{Prefix: "secret/public/", Cache: true},
+	}, rules)
+
+	rules, err = ParseRules("")
+	assert.NoError(t, err)
+	assert.Empty(t, rules)
+
+	_, err = ParseRules("secret/")
+	assert.Error(t, err)
+	_, err = ParseRules("secret/=never")
+	assert.Error(t, err)
+}
+
+func TestWithRules(t *testing.T) {
+	inner := NewLRUCache(Config{})
+	c := WithRules(inner, []Rule{
+		{Prefix: "secret/", Cache: false},
+		{Prefix: "secret/public/", Cache: true},
+	})
+
+	for _, key := range []string{"key", "secret/password", "secret/public/motd"} {
+		assert.NoError(t, c.Set(key, []byte("value")))
+	}
+
+	_, err := c.Get("key")
+	assert.NoError(t, err)
+	_, err = c.Get("secret/password")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = c.Get("secret/public/motd")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, inner.Stats().Entries)
+}
+
+func TestNew(t *testing.T) {
+	for _, typ := range []string{TypeBigCache, TypeLRU, TypeLFU, TypeNone} {
+		config := DefaultConfig()
+		config.Type = typ
+		c, err := New(config)
+		assert.NoError(t, err, typ)
+		assert.NoError(t, c.Close())
+	}
+
+	config := DefaultConfig()
+	config.Type = "redis"
+	_, err := New(config)
+	assert.Error(t, err)
+}
@@ -0,0 +1,59 @@
+// Package compression implements the algorithms record values can be
+// stored with.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"storage/domain"
+)
+
+// the zstd encoder and decoder are safe for concurrent EncodeAll and
+// DecodeAll calls and expensive to create
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+func Compress(c domain.Compression, b []byte) ([]byte, error) {
+	switch c {
+	case domain.CompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case domain.CompressionZstd:
+		return zstdEncoder.EncodeAll(b, nil), nil
+	case domain.CompressionSnappy:
+		return snappy.Encode(nil, b), nil
+	default:
+		return nil, fmt.Errorf("unknown compression %q", c)
+	}
+}
+
+func Decompress(c domain.Compression, b []byte) ([]byte, error) {
+	switch c {
+	case domain.CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case domain.CompressionZstd:
+		return zstdDecoder.DecodeAll(b, nil)
+	case domain.CompressionSnappy:
+		return snappy.Decode(nil, b)
+	default:
+		return nil, fmt.Errorf("unknown compression %q", c)
+	}
+}
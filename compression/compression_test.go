@@ -0,0 +1,36 @@
+package compression
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"storage/domain"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	value := bytes.Repeat([]byte(`{"name":"value","tags":["a","b"]}`), 100)
+
+	for _, c := range []domain.Compression{domain.CompressionGzip, domain.CompressionZstd, domain.CompressionSnappy} {
+		t.Run(string(c), func(t *testing.T) {
+			compressed, err := Compress(c, value)
+			assert.NoError(t, err)
+			assert.Less(t, len(compressed), len(value))
+
+			b, err := Decompress(c, compressed)
+			assert.NoError(t, err)
+			assert.Equal(t, value, b)
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := Compress("lz4", value)
+		assert.EqualError(t, err, `unknown compression "lz4"`)
+		_, err = Decompress(domain.CompressionNone, value)
+		assert.Error(t, err)
+	})
+
+	t.Run("corrupt", func(t *testing.T) {
+		_, err := Decompress(domain.CompressionZstd, []byte("not zstd"))
+		assert.Error(t, err)
+	})
+}
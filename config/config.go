@@ -0,0 +1,316 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"storage/cache"
+	"storage/domain"
+	"storage/encryption"
+	"storage/invalidation"
+	"storage/leader"
+	"storage/record"
+	"storage/tracing"
+	"strings"
+	"time"
+)
+
+const (
+	EnvironmentDev  = "dev"
+	EnvironmentProd = "prod"
+)
+
+// Config is the configuration of the whole service. Every field can be set
+// in the config file under its config key, through the environment variable
+// in its env tag and through the flag named after its config key, e.g.
+// --postgres.host; later sources override earlier ones.
+type Config struct {
+	Environment    string      `config:"environment" env:"ENVIRONMENT" usage:"deployment environment: dev, which reads .env and enables sql logging, or prod, which enables release mode"`
+	Port           int         `config:"port" env:"PORT" usage:"http port"`
+	JwtSecret      string      `config:"jwt_secret" env:"JWT_SECRET" secret:"true" usage:"secret used to sign login tokens"`
+	AdminUserIds   []int       `config:"admin_user_ids" env:"ADMIN_USER_IDS" usage:"comma separated ids of the users allowed to use the admin endpoints"`
+	TracesExporter string      `config:"traces_exporter" env:"TRACES_EXPORTER" usage:"trace exporter: none, stdout or otlp"`
+	AutoMigrate    bool        `config:"auto_migrate" env:"AUTO_MIGRATE" usage:"apply pending migrations on startup instead of refusing to start"`
+	MaxValueSizeMB int         `config:"max_value_size_mb" env:"MAX_VALUE_SIZE_MB" usage:"largest record value in megabytes, streamed values included"`
+	Postgres       Postgres    `config:"postgres"`
+	Cache          Cache       `config:"cache"`
+	Compression    Compression `config:"compression"`
+	Encryption     Encryption  `config:"encryption"`
+	Expiry         Expiry      `config:"expiry"`
+	Stream         Stream      `config:"stream"`
+	Cluster        Cluster     `config:"cluster"`
+}
+
+type Postgres struct {
+	Host     string `config:"host" env:"POSTGRES_HOST" usage:"postgres host"`
+	Port     int    `config:"port" env:"POSTGRES_PORT" usage:"postgres port"`
+	User     string `config:"user" env:"POSTGRES_USER" usage:"postgres user"`
+	Password string `config:"password" env:"POSTGRES_PASSWORD" secret:"true" usage:"postgres password"`
+	Database string `config:"database" env:"POSTGRES_DATABASE" usage:"postgres database"`
+}
+
+type Cache struct {
+	Type                   string        `config:"type" env:"CACHE_TYPE" usage:"record cache: bigcache, lru, lfu or none"`
+	LifeWindow             time.Duration `config:"life_window" env:"CACHE_LIFE_WINDOW" usage:"how long cache entries are kept"`
+	MaxEntries             int           `config:"max_entries" env:"CACHE_MAX_ENTRIES" usage:"maximum number of cache entries"`
+	MaxSizeMB              int           `config:"max_size_mb" env:"CACHE_MAX_SIZE_MB" usage:"maximum cache size in megabytes"`
+	MaxValueSizeKB         int           `config:"max_value_size_kb" env:"CACHE_MAX_VALUE_SIZE_KB" usage:"largest value in kilobytes that is cached, larger ones are always read from the database"`
+	Rules                  string        `config:"rules" env:"CACHE_RULES" usage:"comma separated prefix=on|off caching rules"`
+	Freshness              time.Duration `config:"freshness" env:"CACHE_FRESHNESS" usage:"how long a cached record is served before it is reloaded, 0 for the life window"`
+	StaleWhileRevalidate   time.Duration `config:"stale_while_revalidate" env:"CACHE_STALE_WHILE_REVALIDATE" usage:"how long a stale record is served while it is reloaded"`
+	NegativeTtl            time.Duration `config:"negative_ttl" env:"NEGATIVE_CACHE_TTL" usage:"how long missing keys are cached, 0 disables it"`
+	WarmUpKeys             int           `config:"warmup_keys" env:"CACHE_WARMUP_KEYS" usage:"number of most read keys loaded on startup, 0 disables it"`
+	WarmUpTimeout          time.Duration `config:"warmup_timeout" env:"CACHE_WARMUP_TIMEOUT" usage:"time limit of the warm-up"`
+	AccessLogFlushInterval time.Duration `config:"access_log_flush_interval" env:"CACHE_ACCESS_LOG_FLUSH_INTERVAL" usage:"how often read counts are written to the access log"`
+	AccessLogMaxKeys       int           `config:"access_log_max_keys" env:"CACHE_ACCESS_LOG_MAX_KEYS" usage:"maximum number of keys counted between two flushes"`
+	AccessLogRetention     time.Duration `config:"access_log_retention" env:"CACHE_ACCESS_LOG_RETENTION" usage:"how long unread keys stay in the access log"`
+}
+
+type Compression struct {
+	Algorithm string `config:"algorithm" env:"COMPRESSION_ALGORITHM" usage:"algorithm large values are compressed with: gzip, zstd or snappy"`
+	MinSize   int    `config:"min_size" env:"COMPRESSION_MIN_SIZE" usage:"size in bytes from which string and bytes values are compressed, 0 only compresses on request"`
+	Json      bool   `config:"json" env:"COMPRESSION_JSON" usage:"compress json values from min_size too; compressed json cannot be patched in place or found by queries"`
+}
+
+type Encryption struct {
+	MasterKey             string        `config:"master_key" env:"ENCRYPTION_MASTER_KEY" secret:"true" usage:"base64 encoded 32 byte key wrapping the data keys, enables encryption"`
+	MasterKeyFile         string        `config:"master_key_file" env:"ENCRYPTION_MASTER_KEY_FILE" usage:"file holding the master key, read instead of master_key"`
+	PreviousMasterKey     string        `config:"previous_master_key" env:"ENCRYPTION_PREVIOUS_MASTER_KEY" secret:"true" usage:"master key being replaced, whose data keys are wrapped again by master_key"`
+	PreviousMasterKeyFile string        `config:"previous_master_key_file" env:"ENCRYPTION_PREVIOUS_MASTER_KEY_FILE" usage:"file holding the previous master key"`
+	Prefixes              string        `config:"prefixes" env:"ENCRYPTION_PREFIXES" usage:"comma separated key prefixes whose values are encrypted, every key when empty"`
+	CachePlaintext        bool          `config:"cache_plaintext" env:"ENCRYPTION_CACHE_PLAINTEXT" usage:"cache decrypted values instead of encrypted ones"`
+	KeyRotationInterval   time.Duration `config:"key_rotation_interval" env:"ENCRYPTION_KEY_ROTATION_INTERVAL" usage:"age at which a new data key is created and values are re-encrypted with it, 0 disables rotation"`
+	ReEncryptInterval     time.Duration `config:"reencrypt_interval" env:"ENCRYPTION_REENCRYPT_INTERVAL" usage:"how often data keys are reloaded and values sealed with older keys re-encrypted"`
+	ReEncryptBatchSize    int           `config:"reencrypt_batch_size" env:"ENCRYPTION_REENCRYPT_BATCH_SIZE" usage:"values re-encrypted per statement"`
+}
+
+// MasterKeys loads the master key and the previous one, nil when unset.
+func (e *Encryption) MasterKeys() (master, previous *encryption.MasterKey, err error) {
+	if master, err = encryption.LoadMasterKey(e.MasterKeyFile, e.MasterKey); err != nil {
+		return nil, nil, fmt.Errorf("master_key: %w", err)
+	}
+	if previous, err = encryption.LoadMasterKey(e.PreviousMasterKeyFile, e.PreviousMasterKey); err != nil {
+		return nil, nil, fmt.Errorf("previous_master_key: %w", err)
+	}
+	return master, previous, nil
+}
+
+type Expiry struct {
+	Strategy        string        `config:"strategy" env:"EXPIRATION_STRATEGY" usage:"active expiration: sweep, sampled or hybrid"`
+	SweepInterval   time.Duration `config:"sweep_interval" env:"EXPIRY_SWEEP_INTERVAL" usage:"how often expired records are swept"`
+	SweepBatchSize  int           `config:"sweep_batch_size" env:"EXPIRY_SWEEP_BATCH_SIZE" usage:"records deleted per sweep statement"`
+	SweepBudget     time.Duration `config:"sweep_budget" env:"EXPIRY_SWEEP_BUDGET" usage:"time limit of a sweep"`
+	SampleInterval  time.Duration `config:"sample_interval" env:"EXPIRY_SAMPLE_INTERVAL" usage:"longest pause between sampling cycles"`
+	SampleSize      int           `config:"sample_size" env:"EXPIRY_SAMPLE_SIZE" usage:"records with a ttl probed per sampling round"`
+	SampleThreshold float64       `config:"sample_threshold" env:"EXPIRY_SAMPLE_THRESHOLD" usage:"expired share of a sample that starts another round"`
+	SampleBudget    time.Duration `config:"sample_budget" env:"EXPIRY_SAMPLE_BUDGET" usage:"time limit of a sampling cycle"`
+}
+
+type Stream struct {
+	MaxBlock     time.Duration `config:"max_block" env:"STREAM_MAX_BLOCK" usage:"longest time a stream read waits for new entries"`
+	PollInterval time.Duration `config:"poll_interval" env:"STREAM_POLL_INTERVAL" usage:"how often waiting stream reads look for entries added on other instances"`
+}
+
+type Cluster struct {
+	InvalidationBus        string        `config:"invalidation_bus" env:"INVALIDATION_BUS" usage:"cache invalidation between instances: postgres or none"`
+	LeaderElectionInterval time.Duration `config:"leader_election_interval" env:"LEADER_ELECTION_INTERVAL" usage:"how often instances campaign for leadership"`
+}
+
+// Default returns the configuration used for anything left unset.
+func Default() *Config {
+	r := record.DefaultConfig()
+	return &Config{
+		Port:           8080,
+		TracesExporter: tracing.ExporterNone,
+		MaxValueSizeMB: int(r.MaxValueSize >> 20),
+		Postgres: Postgres{
+			Host:     "localhost",
+			Port:     5432,
+			Database: "storage",
+		},
+		Cache: Cache{
+			Type:                   r.Cache.Type,
+			LifeWindow:             r.Cache.LifeWindow,
+			MaxEntries:             r.Cache.MaxEntries,
+			MaxSizeMB:              r.Cache.MaxSizeMB,
+			MaxValueSizeKB:         r.CacheMaxValueSize >> 10,
+			Freshness:              r.CacheFreshness,
+			StaleWhileRevalidate:   r.StaleWhileRevalidate,
+			NegativeTtl:            r.NegativeCacheTtl,
+			WarmUpKeys:             r.WarmUpKeys,
+			WarmUpTimeout:          r.WarmUpTimeout,
+			AccessLogFlushInterval: r.AccessLogFlushInterval,
+			AccessLogMaxKeys:       r.AccessLogMaxKeys,
+			AccessLogRetention:     r.AccessLogRetention,
+		},
+		Compression: Compression{
+			Algorithm: string(r.Compression),
+			MinSize:   r.CompressionMinSize,
+			Json:      r.CompressJson,
+		},
+		Encryption: Encryption{
+			KeyRotationInterval: r.KeyRotationInterval,
+			ReEncryptInterval:   r.ReEncryptInterval,
+			ReEncryptBatchSize:  r.ReEncryptBatchSize,
+		},
+		Expiry: Expiry{
+			Strategy:        r.ExpirationStrategy,
+			SweepInterval:   r.ExpirySweepInterval,
+			SweepBatchSize:  r.ExpirySweepBatchSize,
+			SweepBudget:     r.ExpirySweepBudget,
+			SampleInterval:  r.ExpirySampleInterval,
+			SampleSize:      r.ExpirySampleSize,
+			SampleThreshold: r.ExpirySampleThreshold,
+			SampleBudget:    r.ExpirySampleBudget,
+		},
+		Stream: Stream{
+			MaxBlock:     r.StreamMaxBlock,
+			PollInterval: r.StreamPollInterval,
+		},
+		Cluster: Cluster{
+			InvalidationBus:        invalidation.BusPostgres,
+			LeaderElectionInterval: leader.DefaultInterval,
+		},
+	}
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []string
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(oneOf(c.Environment, "", EnvironmentDev, EnvironmentProd), "environment must be %s or %s", EnvironmentDev, EnvironmentProd)
+	check(c.Environment != EnvironmentProd || c.JwtSecret != "", "jwt_secret must be set in %s", EnvironmentProd)
+	check(c.Port > 0 && c.Port < 65536, "port must be between 1 and 65535")
+	check(c.Postgres.Port > 0 && c.Postgres.Port < 65536, "postgres.port must be between 1 and 65535")
+	check(oneOf(c.TracesExporter, "", tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOtlp),
+		"traces_exporter must be none, stdout or otlp")
+	check(c.MaxValueSizeMB > 0, "max_value_size_mb must be positive")
+
+	check(oneOf(c.Cache.Type, cache.TypeBigCache, cache.TypeLRU, cache.TypeLFU, cache.TypeNone), "cache.type must be bigcache, lru, lfu or none")
+	check(c.Cache.LifeWindow > 0, "cache.life_window must be positive")
+	check(c.Cache.MaxEntries > 0, "cache.max_entries must be positive")
+	check(c.Cache.MaxSizeMB > 0, "cache.max_size_mb must be positive")
+	check(c.Cache.MaxValueSizeKB >= 0, "cache.max_value_size_kb must not be negative")
+	if _, err := cache.ParseRules(c.Cache.Rules); err != nil {
+		errs = append(errs, "cache.rules: "+err.Error())
+	}
+	check(c.Cache.Freshness >= 0 && c.Cache.StaleWhileRevalidate >= 0 && c.Cache.NegativeTtl >= 0,
+		"cache.freshness, cache.stale_while_revalidate and cache.negative_ttl must not be negative")
+	check(c.Cache.Freshness+c.Cache.StaleWhileRevalidate <= c.Cache.LifeWindow,
+		"cache.freshness and cache.stale_while_revalidate must fit in cache.life_window")
+	check(c.Cache.WarmUpKeys >= 0, "cache.warmup_keys must not be negative")
+	if c.Cache.WarmUpKeys > 0 {
+		check(c.Cache.WarmUpTimeout > 0, "cache.warmup_timeout must be positive")
+	}
+	check(c.Cache.AccessLogFlushInterval > 0, "cache.access_log_flush_interval must be positive")
+	check(c.Cache.AccessLogMaxKeys > 0, "cache.access_log_max_keys must be positive")
+	check(c.Cache.AccessLogRetention > 0, "cache.access_log_retention must be positive")
+
+	check(oneOf(c.Compression.Algorithm, string(domain.CompressionGzip), string(domain.CompressionZstd), string(domain.CompressionSnappy)),
+		"compression.algorithm must be gzip, zstd or snappy")
+	check(c.Compression.MinSize >= 0, "compression.min_size must not be negative")
+
+	master, previous, err := c.Encryption.MasterKeys()
+	if err != nil {
+		errs = append(errs, "encryption."+err.Error())
+	}
+	check(master != nil || previous == nil, "encryption.previous_master_key requires encryption.master_key")
+	check(c.Encryption.KeyRotationInterval >= 0, "encryption.key_rotation_interval must not be negative")
+	check(c.Encryption.ReEncryptInterval > 0, "encryption.reencrypt_interval must be positive")
+	check(c.Encryption.ReEncryptBatchSize > 0, "encryption.reencrypt_batch_size must be positive")
+
+	check(oneOf(c.Expiry.Strategy, record.ExpirationSweep, record.ExpirationSampled, record.ExpirationHybrid),
+		"expiry.strategy must be sweep, sampled or hybrid")
+	check(c.Expiry.SweepInterval > 0 && c.Expiry.SweepBudget > 0 && c.Expiry.SampleInterval > 0 && c.Expiry.SampleBudget > 0,
+		"expiry intervals and budgets must be positive")
+	check(c.Expiry.SweepBatchSize > 0 && c.Expiry.SampleSize > 0, "expiry.sweep_batch_size and expiry.sample_size must be positive")
+	check(c.Expiry.SampleThreshold > 0 && c.Expiry.SampleThreshold <= 1, "expiry.sample_threshold must be in (0, 1]")
+
+	check(c.Stream.MaxBlock >= 0, "stream.max_block must not be negative")
+	check(c.Stream.PollInterval > 0, "stream.poll_interval must be positive")
+
+	check(oneOf(c.Cluster.InvalidationBus, invalidation.BusPostgres, invalidation.BusNone), "cluster.invalidation_bus must be postgres or none")
+	check(c.Cluster.LeaderElectionInterval > 0, "cluster.leader_election_interval must be positive")
+
+	if len(errs) > 0 {
+		return errors.New("invalid config:\n  " + strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+func oneOf(s string, values ...string) bool {
+	for _, v := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Record returns the settings of the record service.
+func (c *Config) Record() record.Config {
+	r := record.DefaultConfig()
+	rules, _ := cache.ParseRules(c.Cache.Rules)
+	r.Cache = cache.Config{
+		Type:       c.Cache.Type,
+		LifeWindow: c.Cache.LifeWindow,
+		MaxEntries: c.Cache.MaxEntries,
+		MaxSizeMB:  c.Cache.MaxSizeMB,
+		Rules:      rules,
+	}
+	r.MaxValueSize = int64(c.MaxValueSizeMB) << 20
+	r.CacheMaxValueSize = c.Cache.MaxValueSizeKB << 10
+	r.CacheFreshness = c.Cache.Freshness
+	r.StaleWhileRevalidate = c.Cache.StaleWhileRevalidate
+	r.NegativeCacheTtl = c.Cache.NegativeTtl
+	r.WarmUpKeys = c.Cache.WarmUpKeys
+	r.WarmUpTimeout = c.Cache.WarmUpTimeout
+	r.AccessLogFlushInterval = c.Cache.AccessLogFlushInterval
+	r.AccessLogMaxKeys = c.Cache.AccessLogMaxKeys
+	r.AccessLogRetention = c.Cache.AccessLogRetention
+
+	r.Compression = domain.Compression(c.Compression.Algorithm)
+	r.CompressionMinSize = c.Compression.MinSize
+	r.CompressJson = c.Compression.Json
+
+	r.MasterKey, r.PreviousMasterKey, _ = c.Encryption.MasterKeys()
+	r.EncryptPrefixes = splitList(c.Encryption.Prefixes)
+	r.CachePlaintext = c.Encryption.CachePlaintext
+	r.KeyRotationInterval = c.Encryption.KeyRotationInterval
+	r.ReEncryptInterval = c.Encryption.ReEncryptInterval
+	r.ReEncryptBatchSize = c.Encryption.ReEncryptBatchSize
+
+	r.ExpirationStrategy = c.Expiry.Strategy
+	r.ExpirySweepInterval = c.Expiry.SweepInterval
+	r.ExpirySweepBatchSize = c.Expiry.SweepBatchSize
+	r.ExpirySweepBudget = c.Expiry.SweepBudget
+	r.ExpirySampleInterval = c.Expiry.SampleInterval
+	r.ExpirySampleSize = c.Expiry.SampleSize
+	r.ExpirySampleThreshold = c.Expiry.SampleThreshold
+	r.ExpirySampleBudget = c.Expiry.SampleBudget
+
+	r.StreamMaxBlock = c.Stream.MaxBlock
+	r.StreamPollInterval = c.Stream.PollInterval
+	return r
+}
+
+// Dsn is the postgres connection string.
+func (p *Postgres) Dsn() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		p.Host, p.Port, p.User, p.Password, p.Database)
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"storage/domain"
+	"storage/record"
+	"testing"
+	"time"
+)
+
+// masterKey is the base64 encoding of 32 zero bytes.
+const masterKey = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+
+func TestConfig_Validate(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		assert.NoError(t, Default().Validate())
+	})
+
+	t.Run("jwt secret is required in prod", func(t *testing.T) {
+		c := Default()
+		c.Environment = EnvironmentProd
+		err := c.Validate()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "jwt_secret")
+		}
+
+		c.JwtSecret = "secret"
+		assert.NoError(t, c.Validate())
+	})
+
+	t.Run("unknown environment", func(t *testing.T) {
+		c := Default()
+		c.Environment = "production"
+		err := c.Validate()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "environment")
+		}
+
+		c.Environment = EnvironmentDev
+		assert.NoError(t, c.Validate())
+	})
+
+	t.Run("reports every error", func(t *testing.T) {
+		c := Default()
+		c.Port = 0
+		c.Cache.Type = "redis"
+		c.Cache.Rules = "secret/"
+		c.Expiry.SampleThreshold = 2
+		c.Compression.Algorithm = "lz4"
+		c.Encryption.MasterKey = "c2hvcnQ="
+
+		err := c.Validate()
+		if assert.Error(t, err) {
+			for _, key := range []string{"port", "cache.type", "cache.rules", "expiry.sample_threshold", "compression.algorithm", "encryption.master_key"} {
+				assert.Contains(t, err.Error(), key)
+			}
+		}
+	})
+
+	t.Run("previous master key requires a master key", func(t *testing.T) {
+		c := Default()
+		c.Encryption.PreviousMasterKey = masterKey
+		assert.Error(t, c.Validate())
+
+		c.Encryption.MasterKey = masterKey
+		assert.NoError(t, c.Validate())
+	})
+
+	t.Run("stale window must fit in the life window", func(t *testing.T) {
+		c := Default()
+		c.Cache.Freshness = 5 * time.Minute
+		c.Cache.StaleWhileRevalidate = 10 * time.Minute
+		assert.Error(t, c.Validate())
+	})
+}
+
+func TestConfig_Record(t *testing.T) {
+	c := Default()
+	c.Cache.Type = "lru"
+	c.Cache.Rules = "secret/=off"
+	c.Expiry.SweepInterval = time.Minute
+	c.MaxValueSizeMB = 8
+	c.Compression.Algorithm = "snappy"
+	c.Compression.MinSize = 1024
+	c.Compression.Json = true
+	c.Encryption.MasterKey = masterKey
+	c.Encryption.Prefixes = "secret/, tokens/,"
+	c.Stream.MaxBlock = 5 * time.Second
+
+	r := c.Record()
+	assert.Equal(t, "lru", r.Cache.Type)
+	if assert.Len(t, r.Cache.Rules, 1) {
+		assert.Equal(t, "secret/", r.Cache.Rules[0].Prefix)
+	}
+	assert.Equal(t, time.Minute, r.ExpirySweepInterval)
+	assert.Equal(t, c.Cache.NegativeTtl, r.NegativeCacheTtl)
+	assert.Equal(t, int64(8<<20), r.MaxValueSize)
+	assert.Equal(t, domain.CompressionSnappy, r.Compression)
+	assert.Equal(t, 1024, r.CompressionMinSize)
+	assert.True(t, r.CompressJson)
+	assert.Equal(t, record.DefaultConfig().CacheMaxValueSize, r.CacheMaxValueSize)
+	assert.NotNil(t, r.MasterKey)
+	assert.Nil(t, r.PreviousMasterKey)
+	assert.Equal(t, []string{"secret/", "tokens/"}, r.EncryptPrefixes)
+	assert.Equal(t, c.Encryption.ReEncryptInterval, r.ReEncryptInterval)
+	assert.Equal(t, 5*time.Second, r.StreamMaxBlock)
+	assert.Equal(t, record.DefaultConfig().StreamPollInterval, r.StreamPollInterval)
+}
+
+func TestPostgres_Dsn(t *testing.T) {
+	p := Postgres{Host: "db", Port: 5432, User: "u", Password: "p", Database: "storage"}
+	assert.Equal(t, "host=db port=5432 user=u password=p dbname=storage sslmode=disable", p.Dsn())
+}
@@ -0,0 +1,305 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const redacted = "<redacted>"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// dotEnvFile holds environment variables for local development.
+var dotEnvFile = ".env"
+
+// field is a single setting of Config.
+type field struct {
+	key    string
+	env    string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+// fields lists the settings of c, keyed by their dotted config key.
+func fields(c *Config) []*field {
+	var list []*field
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			key := prefix + sf.Tag.Get("config")
+			if sf.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), key+".")
+				continue
+			}
+			list = append(list, &field{
+				key:    key,
+				env:    sf.Tag.Get("env"),
+				usage:  sf.Tag.Get("usage"),
+				secret: sf.Tag.Get("secret") == "true",
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "")
+	return list
+}
+
+func (f *field) set(s string) error {
+	s = strings.TrimSpace(s)
+	v := f.value
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		var ids []int
+		for _, part := range strings.Split(s, ",") {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			id, err := strconv.Atoi(part)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		v.Set(reflect.ValueOf(ids))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func (f *field) String() string {
+	switch v := f.value.Interface().(type) {
+	case []int:
+		parts := make([]string, len(v))
+		for i, id := range v {
+			parts[i] = strconv.Itoa(id)
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// flagValue records a flag so that it can be applied after the file and
+// the environment, which are only known once the flags are parsed.
+type flagValue struct {
+	field *field
+	set   *[]func() error
+}
+
+func (f flagValue) String() string {
+	if f.field == nil {
+		return ""
+	}
+	return f.field.String()
+}
+
+// IsBoolFlag lets bool settings be given as a bare flag.
+func (f flagValue) IsBoolFlag() bool {
+	return f.field != nil && f.field.value.Kind() == reflect.Bool
+}
+
+func (f flagValue) Set(s string) error {
+	*f.set = append(*f.set, func() error {
+		if err := f.field.set(s); err != nil {
+			return fmt.Errorf("flag --%s: %w", f.field.key, err)
+		}
+		return nil
+	})
+	return nil
+}
+
+// Load builds the configuration from, in increasing precedence, the
+// defaults, the config file given by --config or CONFIG_FILE, the
+// environment and the flags in args. Flags of fs other than the config
+// flags are parsed as well. Unless the environment turns out to be other
+// than dev, variables missing from the environment are read from the .env
+// file. The result is not validated.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	c := Default()
+	list := fields(c)
+
+	var flagged []func() error
+	for _, f := range list {
+		fs.Var(flagValue{field: f, set: &flagged}, f.key, f.usage)
+	}
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "yaml or toml config file")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *file != "" {
+		if err := loadFile(list, *file); err != nil {
+			return nil, err
+		}
+	}
+	if err := loadEnv(list, flagged); err != nil {
+		return nil, err
+	}
+
+	if c.Environment == "" || c.Environment == EnvironmentDev {
+		err := godotenv.Load(dotEnvFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s: %w", dotEnvFile, err)
+		}
+		// the flags are applied again so that they still override the
+		// variables read from the file
+		if err == nil {
+			if err = loadEnv(list, flagged); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return c, nil
+}
+
+// loadEnv applies the environment and then the flags to list.
+func loadEnv(list []*field, flagged []func() error) error {
+	for _, f := range list {
+		if s, ok := os.LookupEnv(f.env); ok && f.env != "" {
+			if err := f.set(s); err != nil {
+				return fmt.Errorf("%s: %w", f.env, err)
+			}
+		}
+	}
+
+	for _, set := range flagged {
+		if err := set(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadFile(list []*field, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("%s: unsupported config format %s", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	flat := map[string]string{}
+	flatten(values, "", flat)
+
+	byKey := make(map[string]*field, len(list))
+	for _, f := range list {
+		byKey[f.key] = f
+	}
+	for key, s := range flat {
+		f, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("%s: unknown setting %s", path, key)
+		}
+		if err = f.set(s); err != nil {
+			return fmt.Errorf("%s: %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+// flatten turns nested tables into dotted keys and lists into comma
+// separated values.
+func flatten(values map[string]any, prefix string, flat map[string]string) {
+	for k, v := range values {
+		switch v := v.(type) {
+		case map[string]any:
+			flatten(v, prefix+k+".", flat)
+		case []any:
+			parts := make([]string, len(v))
+			for i, item := range v {
+				parts[i] = fmt.Sprint(item)
+			}
+			flat[prefix+k] = strings.Join(parts, ",")
+		default:
+			flat[prefix+k] = fmt.Sprint(v)
+		}
+	}
+}
+
+// Print writes c as yaml, with secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	root := map[string]any{}
+	for _, f := range fields(c) {
+		value := f.value.Interface()
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+		if f.secret && !f.value.IsZero() {
+			value = redacted
+		}
+
+		node := root
+		parts := strings.Split(f.key, ".")
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node[part].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				node[part] = child
+			}
+			node = child
+		}
+		node[parts[len(parts)-1]] = value
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	yamlFile := writeFile(t, "config.yaml", `
+port: 9000
+admin_user_ids: [1, 2]
+postgres:
+  host: db
+  user: storage
+cache:
+  type: lfu
+  life_window: 5m
+`)
+
+	t.Run("precedence", func(t *testing.T) {
+		t.Setenv("POSTGRES_USER", "from-env")
+		t.Setenv("PORT", " 9001")
+
+		c, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{
+			"--config", yamlFile,
+			"--port", "9002",
+		})
+		assert.NoError(t, err)
+
+		assert.Equal(t, 9002, c.Port)
+		assert.Equal(t, "from-env", c.Postgres.User)
+		assert.Equal(t, "db", c.Postgres.Host)
+		assert.Equal(t, []int{1, 2}, c.AdminUserIds)
+		assert.Equal(t, "lfu", c.Cache.Type)
+		assert.Equal(t, 5*time.Minute, c.Cache.LifeWindow)
+		// untouched settings keep their defaults
+		assert.Equal(t, Default().Expiry, c.Expiry)
+	})
+
+	t.Run("toml", func(t *testing.T) {
+		tomlFile := writeFile(t, "config.toml", `
+jwt_secret = "secret"
+
+[expiry]
+strategy = "hybrid"
+sample_threshold = 0.5
+`)
+		t.Setenv("CONFIG_FILE", tomlFile)
+
+		c, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "secret", c.JwtSecret)
+		assert.Equal(t, "hybrid", c.Expiry.Strategy)
+		assert.Equal(t, 0.5, c.Expiry.SampleThreshold)
+	})
+
+	t.Run("unknown setting", func(t *testing.T) {
+		file := writeFile(t, "config.yaml", "cache:\n  tpye: lru\n")
+		_, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"--config", file})
+		assert.ErrorContains(t, err, "cache.tpye")
+	})
+
+	t.Run("dot env", func(t *testing.T) {
+		dotEnvFile = writeFile(t, ".env", "POSTGRES_HOST=from-dot-env\nPOSTGRES_USER=from-dot-env\n")
+		t.Cleanup(func() {
+			dotEnvFile = ".env"
+			os.Unsetenv("POSTGRES_HOST")
+			os.Unsetenv("POSTGRES_USER")
+		})
+		t.Setenv("POSTGRES_USER", "from-env")
+
+		c, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"--environment", EnvironmentProd})
+		assert.NoError(t, err)
+		assert.Equal(t, "localhost", c.Postgres.Host, "prod must not read .env")
+
+		c, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"--postgres.user", "from-flag"})
+		assert.NoError(t, err)
+		assert.Equal(t, "from-dot-env", c.Postgres.Host)
+		assert.Equal(t, "from-flag", c.Postgres.User, "flags override .env")
+
+		c, err = Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"--environment", EnvironmentDev})
+		assert.NoError(t, err)
+		assert.Equal(t, "from-env", c.Postgres.User, ".env does not override the environment")
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		t.Setenv("EXPIRY_SWEEP_INTERVAL", "often")
+		_, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+		assert.ErrorContains(t, err, "EXPIRY_SWEEP_INTERVAL")
+	})
+}
+
+func TestConfig_Print(t *testing.T) {
+	c := Default()
+	c.JwtSecret = "top-secret"
+	c.Postgres.Password = "hunter2"
+	c.AdminUserIds = []int{1}
+
+	var buf bytes.Buffer
+	assert.NoError(t, c.Print(&buf))
+
+	out := buf.String()
+	assert.NotContains(t, out, "top-secret")
+	assert.NotContains(t, out, "hunter2")
+	assert.Contains(t, out, "jwt_secret: "+redacted)
+	assert.Contains(t, out, "sweep_interval: 10m0s")
+
+	// the printed config can be loaded again
+	file := writeFile(t, "printed.yaml", out)
+	loaded, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"--config", file})
+	assert.NoError(t, err)
+	assert.Equal(t, c.Cache, loaded.Cache)
+	assert.Equal(t, c.AdminUserIds, loaded.AdminUserIds)
+}
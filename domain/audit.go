@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	AuditActionRecordSet         = "record.set"
+	AuditActionRecordSetTtl      = "record.set_ttl"
+	AuditActionRecordRestore     = "record.restore"
+	AuditActionRecordIncr        = "record.incr"
+	AuditActionRecordPatch       = "record.patch"
+	AuditActionHashSet           = "hash.set"
+	AuditActionHashDelete        = "hash.delete"
+	AuditActionListPush          = "list.push"
+	AuditActionListPop           = "list.pop"
+	AuditActionSetAdd            = "set.add"
+	AuditActionSetRemove         = "set.remove"
+	AuditActionZSetAdd           = "zset.add"
+	AuditActionZSetIncr          = "zset.incr"
+	AuditActionZSetPop           = "zset.pop"
+	AuditActionZSetRemove        = "zset.remove"
+	AuditActionStreamAdd         = "stream.add"
+	AuditActionStreamAck         = "stream.ack"
+	AuditActionStreamClaim       = "stream.claim"
+	AuditActionStreamTrim        = "stream.trim"
+	AuditActionStreamGroupCreate = "stream.group_create"
+	AuditActionStreamGroupDelete = "stream.group_delete"
+	AuditActionIndexCreate       = "index.create"
+	AuditActionIndexDrop         = "index.drop"
+	AuditActionUserRegister      = "user.register"
+	AuditActionUserLogin         = "user.login"
+)
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// AuditEvent is a single entry of the append-only audit log.
+type AuditEvent struct {
+	Id        int
+	UserId    int
+	Action    string
+	Key       string
+	Detail    string
+	SourceIp  string
+	Outcome   string
+	CreatedAt time.Time
+}
+
+// AuditFilter narrows down audit events; zero values are ignored.
+// AfterId is used for keyset pagination.
+type AuditFilter struct {
+	UserId  int
+	Action  string
+	Key     string
+	Outcome string
+	From    time.Time
+	To      time.Time
+	AfterId int
+	Limit   int
+}
+
+type AuditService interface {
+	Record(ctx context.Context, event *AuditEvent)
+	List(ctx context.Context, filter *AuditFilter) ([]*AuditEvent, error)
+}
+
+type AuditRepository interface {
+	Create(ctx context.Context, event *AuditEvent) error
+	List(ctx context.Context, filter *AuditFilter) ([]*AuditEvent, error)
+}
+
+type clientIpKey struct{}
+
+// ContextWithClientIp returns a copy of ctx carrying the source ip of the request.
+func ContextWithClientIp(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIpKey{}, ip)
+}
+
+// ClientIpFromContext returns the source ip of the request, or "" when unknown.
+func ClientIpFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIpKey{}).(string)
+	return ip
+}
@@ -0,0 +1,128 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrFieldNotFound  = errors.New("field not found")
+	ErrMemberNotFound = errors.New("member not found")
+)
+
+// ListEnd is the end of a list that values are pushed to or popped from.
+type ListEnd string
+
+const (
+	ListLeft  ListEnd = "left"
+	ListRight ListEnd = "right"
+)
+
+func ParseListEnd(s string) (ListEnd, error) {
+	switch e := ListEnd(s); e {
+	case ListLeft, ListRight:
+		return e, nil
+	case "":
+		return ListRight, nil
+	default:
+		return "", fmt.Errorf("unknown list end %q", s)
+	}
+}
+
+// ScoredMember is a member of a sorted set along with its score.
+type ScoredMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// ScoreRange selects the members of a sorted set by score, from Min to Max
+// unless the bounds are exclusive, then skips Offset of them and returns up
+// to Limit, all when zero.
+type ScoreRange struct {
+	Min          float64
+	Max          float64
+	MinExclusive bool
+	MaxExclusive bool
+	Desc         bool
+	Offset       int
+	Limit        int
+}
+
+// CollectionService operates on the elements of hash, list, set and sorted
+// set records. Writes create the record of a missing or expired key and
+// removing the last element deletes it, so reads of a missing key see an
+// empty collection. Operations on a key holding another type fail with
+// ErrWrongType.
+type CollectionService interface {
+	// HashSet sets the given fields and returns the number of new ones.
+	HashSet(ctx context.Context, key string, fields map[string]string) (int, error)
+	HashGet(ctx context.Context, key, field string) (string, error)
+	HashGetAll(ctx context.Context, key string) (map[string]string, error)
+	// HashDelete deletes the given fields and returns the number of deleted ones.
+	HashDelete(ctx context.Context, key string, fields ...string) (int, error)
+	// ListPush adds values to one end of a list, in order, and returns its length.
+	ListPush(ctx context.Context, key string, end ListEnd, values ...string) (int, error)
+	// ListPop removes up to count values from one end of a list and returns
+	// them, nearest to that end first.
+	ListPop(ctx context.Context, key string, end ListEnd, count int) ([]string, error)
+	// ListRange returns the values from start to stop, both included; negative
+	// indexes count from the right end, -1 being the last value.
+	ListRange(ctx context.Context, key string, start, stop int) ([]string, error)
+	ListLength(ctx context.Context, key string) (int, error)
+	// SetAdd adds members to a set and returns the number of new ones.
+	SetAdd(ctx context.Context, key string, members ...string) (int, error)
+	// SetRemove removes members from a set and returns the number of removed ones.
+	SetRemove(ctx context.Context, key string, members ...string) (int, error)
+	SetMembers(ctx context.Context, key string) ([]string, error)
+	SetContains(ctx context.Context, key, member string) (bool, error)
+	// ZSetAdd sets the scores of members of a sorted set and returns the
+	// number of new ones.
+	ZSetAdd(ctx context.Context, key string, members ...ScoredMember) (int, error)
+	// ZSetIncr adds by to the score of member, zero when it is new, and
+	// returns the new score.
+	ZSetIncr(ctx context.Context, key, member string, by float64) (float64, error)
+	// ZSetRank returns the score of member and its rank: the number of
+	// members before it, ordered by score then member, descending when desc
+	// is set.
+	ZSetRank(ctx context.Context, key, member string, desc bool) (ScoredMember, int, error)
+	// ZSetRange returns the members from rank start to stop, both included;
+	// negative ranks count from the end.
+	ZSetRange(ctx context.Context, key string, start, stop int, desc bool) ([]ScoredMember, error)
+	ZSetRangeByScore(ctx context.Context, key string, r ScoreRange) ([]ScoredMember, error)
+	// ZSetPop removes up to count members with the lowest scores, or the
+	// highest ones when max is set, and returns them in that order.
+	ZSetPop(ctx context.Context, key string, max bool, count int) ([]ScoredMember, error)
+	// ZSetRemove removes members and returns the number of removed ones.
+	ZSetRemove(ctx context.Context, key string, members ...string) (int, error)
+	ZSetLength(ctx context.Context, key string) (int, error)
+}
+
+// CollectionRepository runs every operation in a transaction holding the
+// row lock of the record, which writes create with the collection type.
+type CollectionRepository interface {
+	HashSet(ctx context.Context, key string, fields map[string]string) (int, error)
+	HashGet(ctx context.Context, key, field string) (string, error)
+	HashGetAll(ctx context.Context, key string) (map[string]string, error)
+	HashDelete(ctx context.Context, key string, fields ...string) (int, error)
+	ListPush(ctx context.Context, key string, end ListEnd, values ...string) (int, error)
+	ListPop(ctx context.Context, key string, end ListEnd, count int) ([]string, error)
+	ListRange(ctx context.Context, key string, start, stop int) ([]string, error)
+	ListLength(ctx context.Context, key string) (int, error)
+	SetAdd(ctx context.Context, key string, members ...string) (int, error)
+	SetRemove(ctx context.Context, key string, members ...string) (int, error)
+	SetMembers(ctx context.Context, key string) ([]string, error)
+	SetContains(ctx context.Context, key, member string) (bool, error)
+	ZSetAdd(ctx context.Context, key string, members ...ScoredMember) (int, error)
+	ZSetIncr(ctx context.Context, key, member string, by float64) (float64, error)
+	ZSetRank(ctx context.Context, key, member string, desc bool) (ScoredMember, int, error)
+	ZSetRange(ctx context.Context, key string, start, stop int, desc bool) ([]ScoredMember, error)
+	ZSetRangeByScore(ctx context.Context, key string, r ScoreRange) ([]ScoredMember, error)
+	ZSetPop(ctx context.Context, key string, max bool, count int) ([]ScoredMember, error)
+	ZSetRemove(ctx context.Context, key string, members ...string) (int, error)
+	ZSetLength(ctx context.Context, key string) (int, error)
+	// ExpireCollection sets the ttl of the live collection record of key,
+	// zero removing it, and returns the record.
+	ExpireCollection(ctx context.Context, key string, ttl time.Duration) (*Record, error)
+}
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Compression is an algorithm record values are compressed with.
+type Compression string
+
+const (
+	// CompressionNone opts a record out of compression.
+	CompressionNone   Compression = "none"
+	CompressionGzip   Compression = "gzip"
+	CompressionZstd   Compression = "zstd"
+	CompressionSnappy Compression = "snappy"
+)
+
+var ErrCompressed = errors.New("operation not supported on compressed values")
+
+// ParseCompression accepts the known algorithms, none and the empty string,
+// which leaves the choice to the service.
+func ParseCompression(s string) (Compression, error) {
+	switch c := Compression(s); c {
+	case "", CompressionNone, CompressionGzip, CompressionZstd, CompressionSnappy:
+		return c, nil
+	default:
+		return "", fmt.Errorf("unknown compression %q", s)
+	}
+}
+
+// Compressible reports whether values of t may be stored compressed.
+// Numbers and booleans are too small to gain anything.
+func (t ValueType) Compressible() bool {
+	return t == TypeString || t == TypeJson || t == TypeBytes
+}
@@ -0,0 +1,17 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrEncrypted = errors.New("operation not supported on encrypted values")
+
+// DataKey is a key record values are encrypted with. It is only stored
+// wrapped by the master key MasterKeyId.
+type DataKey struct {
+	Id          int
+	WrappedKey  []byte
+	MasterKeyId string
+	CreatedAt   time.Time
+}
@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrIndexNotFound = errors.New("index not found")
+	ErrIndexExists   = errors.New("an index with that name, or on that prefix and path, already exists")
+	ErrNotIndexed    = errors.New("path is not indexed for the prefix")
+)
+
+var (
+	indexNamePattern   = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+	indexPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9_:./-]{1,100}$`)
+	indexTokenPattern  = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+// RecordIndex is a secondary index on a path of the json records whose key
+// starts with Prefix. Path is a json pointer.
+type RecordIndex struct {
+	Name      string
+	Prefix    string
+	Path      string
+	CreatedAt time.Time
+}
+
+// Validate checks the index definition. Names, prefixes and path tokens
+// are limited to characters that are safe inside sql identifiers and
+// literals, since they end up in the index expression.
+func (i *RecordIndex) Validate() error {
+	if !indexNamePattern.MatchString(i.Name) {
+		return fmt.Errorf("invalid index name %q: use up to 40 lowercase letters, digits and underscores", i.Name)
+	}
+	if !indexPrefixPattern.MatchString(i.Prefix) {
+		return fmt.Errorf("invalid index prefix %q: use letters, digits and _:./-", i.Prefix)
+	}
+	_, err := IndexPathTokens(i.Path)
+	return err
+}
+
+// IndexPathTokens parses an indexable json pointer.
+func IndexPathTokens(path string) ([]string, error) {
+	tokens, err := ParseJsonPointer(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("cannot index the whole document")
+	}
+	for _, t := range tokens {
+		if !indexTokenPattern.MatchString(t) {
+			return nil, fmt.Errorf("invalid index path %q: use letters, digits, _ and -", path)
+		}
+	}
+	return tokens, nil
+}
+
+// FilterOp compares an indexed path with a value.
+type FilterOp string
+
+const (
+	FilterEq  FilterOp = "eq"
+	FilterLt  FilterOp = "lt"
+	FilterLte FilterOp = "lte"
+	FilterGt  FilterOp = "gt"
+	FilterGte FilterOp = "gte"
+)
+
+// QueryFilter matches the records whose value at Path compares to Value,
+// a json value. Range filters only match values of the same json type.
+type QueryFilter struct {
+	Path  string
+	Op    FilterOp
+	Value json.RawMessage
+}
+
+// QueryCursor is the position after which the next page of a query starts.
+type QueryCursor struct {
+	Key   string          `json:"k"`
+	Value json.RawMessage `json:"v,omitempty"`
+}
+
+// RecordQuery finds json records of a key prefix by their indexed paths.
+// Without SortBy records are ordered by key; with it, records that do not
+// have the sort path are left out.
+type RecordQuery struct {
+	Prefix  string
+	Filters []QueryFilter
+	SortBy  string
+	Desc    bool
+	Limit   int
+	After   *QueryCursor
+	// Metadata further narrows the results down.
+	Metadata MetadataFilter
+}
@@ -0,0 +1,14 @@
+package domain
+
+import "context"
+
+// InvalidationBus broadcasts cache invalidations between the replicas
+// sharing a database.
+type InvalidationBus interface {
+	// Publish tells the other replicas that the given keys changed.
+	Publish(ctx context.Context, keys ...string) error
+	// Subscribe registers handler for invalidations published by other
+	// replicas. An empty keys slice means any key may be stale, e.g. after
+	// invalidations were missed while reconnecting.
+	Subscribe(handler func(keys []string))
+}
@@ -0,0 +1,138 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PatchType is the format of a patch applied to a json record.
+type PatchType string
+
+const (
+	// PatchMerge is a JSON Merge Patch (RFC 7396).
+	PatchMerge PatchType = "merge"
+	// PatchJson is a JSON Patch (RFC 6902).
+	PatchJson PatchType = "json"
+)
+
+var (
+	ErrPatchFailed  = errors.New("patch cannot be applied")
+	ErrPathNotFound = errors.New("path not found")
+)
+
+// JsonPatchOperation is a single operation of a JSON Patch.
+type JsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// ValidatePatch checks that patch is a well-formed patch of type t. Whether
+// it applies to a document is only known once it is applied.
+func ValidatePatch(t PatchType, patch string) error {
+	switch t {
+	case PatchMerge:
+		if !json.Valid([]byte(patch)) {
+			return errors.New("invalid merge patch: not a json document")
+		}
+		return nil
+	case PatchJson:
+		var ops []JsonPatchOperation
+		if err := json.Unmarshal([]byte(patch), &ops); err != nil {
+			return fmt.Errorf("invalid json patch: %w", err)
+		}
+		for i, op := range ops {
+			if err := op.validate(); err != nil {
+				return fmt.Errorf("invalid json patch operation %d: %w", i, err)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown patch type %q", t)
+	}
+}
+
+func (o *JsonPatchOperation) validate() error {
+	if _, err := ParseJsonPointer(o.Path); err != nil {
+		return err
+	}
+
+	switch o.Op {
+	case "add", "replace", "test":
+		if len(o.Value) == 0 {
+			return fmt.Errorf("%s requires a value", o.Op)
+		}
+	case "remove":
+		if o.Path == "" {
+			return errors.New("cannot remove the whole document")
+		}
+	case "move", "copy":
+		if _, err := ParseJsonPointer(o.From); err != nil {
+			return err
+		}
+		if o.Op == "move" && strings.HasPrefix(o.Path+"/", o.From+"/") {
+			return errors.New("cannot move a value into itself")
+		}
+	default:
+		return fmt.Errorf("unknown op %q", o.Op)
+	}
+	return nil
+}
+
+// ParseJsonPointer splits a JSON Pointer (RFC 6901) into its unescaped
+// reference tokens. The empty pointer refers to the whole document.
+func ParseJsonPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid json pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+// JsonPointerGet returns the part of the json document doc that pointer
+// refers to.
+func JsonPointerGet(doc, pointer string) (json.RawMessage, error) {
+	tokens, err := ParseJsonPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	d := json.NewDecoder(bytes.NewReader([]byte(doc)))
+	d.UseNumber()
+	var v any
+	if err = d.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	for _, t := range tokens {
+		switch node := v.(type) {
+		case map[string]any:
+			var ok bool
+			if v, ok = node[t]; !ok {
+				return nil, ErrPathNotFound
+			}
+		case []any:
+			i, err := strconv.Atoi(t)
+			if err != nil || i < 0 || i >= len(node) || (len(t) > 1 && t[0] == '0') {
+				return nil, ErrPathNotFound
+			}
+			v = node[i]
+		default:
+			return nil, ErrPathNotFound
+		}
+	}
+
+	return json.Marshal(v)
+}
@@ -0,0 +1,7 @@
+package domain
+
+// Leader tells whether this instance currently leads a background job that
+// only one replica should run at a time.
+type Leader interface {
+	IsLeader() bool
+}
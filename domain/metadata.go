@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"time"
+)
+
+const (
+	maxTags      = 32
+	maxTagLength = 128
+)
+
+// Metadata describes a record. Owner, Tags and ContentType are set on
+// write and kept by writes that leave them out, a nil Tags included; an
+// empty Tags clears them. The timestamps are maintained by the repository.
+type Metadata struct {
+	// Owner is the id of the user owning the record, by default the one
+	// who created it.
+	Owner int
+	Tags  []string
+	// ContentType is the media type raw reads serve the value with instead
+	// of the one of its value type.
+	ContentType string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	// LastAccessedAt is the last read, as of the last flush of the access log.
+	LastAccessedAt time.Time
+}
+
+// Validate checks the metadata a write sets.
+func (m *Metadata) Validate() error {
+	if m.Owner < 0 {
+		return errors.New("invalid owner")
+	}
+	if len(m.Tags) > maxTags {
+		return fmt.Errorf("at most %d tags are allowed", maxTags)
+	}
+	for _, t := range m.Tags {
+		if t == "" || len(t) > maxTagLength {
+			return fmt.Errorf("invalid tag %q: use 1 to %d characters", t, maxTagLength)
+		}
+	}
+	if m.ContentType != "" {
+		if _, _, err := mime.ParseMediaType(m.ContentType); err != nil {
+			return fmt.Errorf("invalid content type %q", m.ContentType)
+		}
+	}
+	return nil
+}
+
+// MetadataFilter narrows a list of records down by their metadata. Zero
+// fields match every record.
+type MetadataFilter struct {
+	// Tags matches the records carrying all of them.
+	Tags          []string
+	Owner         int
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+	// AccessedBefore matches the records not read since, never read ones included.
+	AccessedBefore time.Time
+}
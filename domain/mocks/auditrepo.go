@@ -0,0 +1,26 @@
+package mocks
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"storage/domain"
+)
+
+type MockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditRepository) Create(ctx context.Context, event *domain.AuditEvent) error {
+	ret := m.Called(ctx, event)
+	return ret.Error(0)
+}
+
+func (m *MockAuditRepository) List(ctx context.Context, filter *domain.AuditFilter) ([]*domain.AuditEvent, error) {
+	ret := m.Called(ctx, filter)
+
+	err := ret.Error(1)
+	if events, ok := ret.Get(0).([]*domain.AuditEvent); ok {
+		return events, err
+	}
+	return nil, err
+}
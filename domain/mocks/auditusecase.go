@@ -0,0 +1,25 @@
+package mocks
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"storage/domain"
+)
+
+type MockAuditService struct {
+	mock.Mock
+}
+
+func (m *MockAuditService) Record(ctx context.Context, event *domain.AuditEvent) {
+	_ = m.Called(ctx, event)
+}
+
+func (m *MockAuditService) List(ctx context.Context, filter *domain.AuditFilter) ([]*domain.AuditEvent, error) {
+	ret := m.Called(ctx, filter)
+
+	err := ret.Error(1)
+	if events, ok := ret.Get(0).([]*domain.AuditEvent); ok {
+		return events, err
+	}
+	return nil, err
+}
@@ -0,0 +1,18 @@
+package mocks
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockInvalidationBus struct {
+	mock.Mock
+}
+
+func (m *MockInvalidationBus) Publish(ctx context.Context, keys ...string) error {
+	return m.Called(ctx, keys).Error(0)
+}
+
+func (m *MockInvalidationBus) Subscribe(handler func(keys []string)) {
+	_ = m.Called(handler)
+}
@@ -0,0 +1,11 @@
+package mocks
+
+import "github.com/stretchr/testify/mock"
+
+type MockLeader struct {
+	mock.Mock
+}
+
+func (m *MockLeader) IsLeader() bool {
+	return m.Called().Bool(0)
+}
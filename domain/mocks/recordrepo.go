@@ -3,7 +3,9 @@ package mocks
 import (
 	"context"
 	"github.com/stretchr/testify/mock"
+	"io"
 	"storage/domain"
+	"time"
 )
 
 type MockRecordRepository struct {
@@ -26,8 +28,109 @@ func (m *MockRecordRepository) Get(ctx context.Context, key string) (*domain.Rec
 	}
 }
 
-func (m *MockRecordRepository) GetAll(ctx context.Context) []*domain.Record {
+// Update applies fn to the record returned by the expectation, like the
+// real repository does, unless the expectation returns an error.
+func (m *MockRecordRepository) Update(ctx context.Context, key string, fn func(*domain.Record) error) (*domain.Record, error) {
+	ret := m.Called(ctx, key, fn)
+
+	err := ret.Error(1)
+	r, ok := ret.Get(0).(*domain.Record)
+	if !ok || err != nil {
+		return nil, err
+	}
+	if err = fn(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (m *MockRecordRepository) PatchJson(ctx context.Context, key string, patchType domain.PatchType, patch string) (*domain.Record, error) {
+	ret := m.Called(ctx, key, patchType, patch)
+
+	err := ret.Error(1)
+	if r, ok := ret.Get(0).(*domain.Record); ok {
+		return r, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) CreateIndex(ctx context.Context, index *domain.RecordIndex) error {
+	ret := m.Called(ctx, index)
+	return ret.Error(0)
+}
+
+func (m *MockRecordRepository) DropIndex(ctx context.Context, name string) error {
+	ret := m.Called(ctx, name)
+	return ret.Error(0)
+}
+
+func (m *MockRecordRepository) GetIndexes(ctx context.Context) ([]*domain.RecordIndex, error) {
+	ret := m.Called(ctx)
+
+	err := ret.Error(1)
+	if r, ok := ret.Get(0).([]*domain.RecordIndex); ok {
+		return r, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) Query(ctx context.Context, query *domain.RecordQuery) ([]*domain.Record, error) {
+	ret := m.Called(ctx, query)
+
+	err := ret.Error(1)
+	if r, ok := ret.Get(0).([]*domain.Record); ok {
+		return r, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) PutBlob(ctx context.Context, record *domain.Record, body io.Reader, maxSize int64) error {
+	ret := m.Called(ctx, record, body, maxSize)
+	return ret.Error(0)
+}
+
+func (m *MockRecordRepository) GetChunk(ctx context.Context, blobId string, seq int) ([]byte, error) {
+	ret := m.Called(ctx, blobId, seq)
+
+	err := ret.Error(1)
+	if b, ok := ret.Get(0).([]byte); ok {
+		return b, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) GetDataKeys(ctx context.Context) ([]*domain.DataKey, error) {
 	ret := m.Called(ctx)
+
+	err := ret.Error(1)
+	if keys, ok := ret.Get(0).([]*domain.DataKey); ok {
+		return keys, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) AddDataKey(ctx context.Context, key *domain.DataKey) error {
+	return m.Called(ctx, key).Error(0)
+}
+
+func (m *MockRecordRepository) UpdateDataKey(ctx context.Context, key *domain.DataKey) error {
+	return m.Called(ctx, key).Error(0)
+}
+
+func (m *MockRecordRepository) ReEncrypt(ctx context.Context, keyId, limit int, fn func(*domain.Record) error) ([]string, int, error) {
+	ret := m.Called(ctx, keyId, limit, fn)
+
+	keys, _ := ret.Get(0).([]string)
+	return keys, ret.Int(1), ret.Error(2)
+}
+
+func (m *MockRecordRepository) DeleteDataKeys(ctx context.Context, keepId int, supersededBefore time.Time) (int64, error) {
+	ret := m.Called(ctx, keepId, supersededBefore)
+	return ret.Get(0).(int64), ret.Error(1)
+}
+
+func (m *MockRecordRepository) GetAll(ctx context.Context, filter *domain.MetadataFilter) []*domain.Record {
+	ret := m.Called(ctx, filter)
 	if records, ok := ret.Get(0).([]*domain.Record); ok {
 		return records
 	} else {
@@ -38,3 +141,287 @@ func (m *MockRecordRepository) GetAll(ctx context.Context) []*domain.Record {
 func (m *MockRecordRepository) Delete(ctx context.Context, keys ...string) {
 	_ = m.Called(ctx, keys)
 }
+
+func (m *MockRecordRepository) GetHistory(ctx context.Context, key string) ([]*domain.RecordVersion, error) {
+	ret := m.Called(ctx, key)
+
+	err := ret.Error(1)
+	if versions, ok := ret.Get(0).([]*domain.RecordVersion); ok {
+		return versions, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) GetVersion(ctx context.Context, key string, version int) (*domain.RecordVersion, error) {
+	ret := m.Called(ctx, key, version)
+
+	err := ret.Error(1)
+	if v, ok := ret.Get(0).(*domain.RecordVersion); ok {
+		return v, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) GetVersionAt(ctx context.Context, key string, at time.Time) (*domain.RecordVersion, error) {
+	ret := m.Called(ctx, key, at)
+
+	err := ret.Error(1)
+	if v, ok := ret.Get(0).(*domain.RecordVersion); ok {
+		return v, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) DeleteExpired(ctx context.Context, now time.Time, limit int) (int64, error) {
+	ret := m.Called(ctx, now, limit)
+	return ret.Get(0).(int64), ret.Error(1)
+}
+
+func (m *MockRecordRepository) DeleteIfExpired(ctx context.Context, now time.Time, keys ...string) (int64, error) {
+	ret := m.Called(ctx, now, keys)
+	return ret.Get(0).(int64), ret.Error(1)
+}
+
+func (m *MockRecordRepository) SampleVolatile(ctx context.Context, limit int) ([]*domain.Record, error) {
+	ret := m.Called(ctx, limit)
+
+	err := ret.Error(1)
+	if records, ok := ret.Get(0).([]*domain.Record); ok {
+		return records, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) TrackAccesses(ctx context.Context, accesses []*domain.RecordAccess) error {
+	return m.Called(ctx, accesses).Error(0)
+}
+
+func (m *MockRecordRepository) DeleteAccessesBefore(ctx context.Context, before time.Time) (int64, error) {
+	ret := m.Called(ctx, before)
+	return ret.Get(0).(int64), ret.Error(1)
+}
+
+func (m *MockRecordRepository) GetHot(ctx context.Context, since time.Time, limit int) ([]*domain.Record, error) {
+	ret := m.Called(ctx, since, limit)
+
+	err := ret.Error(1)
+	if records, ok := ret.Get(0).([]*domain.Record); ok {
+		return records, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) HashSet(ctx context.Context, key string, fields map[string]string) (int, error) {
+	ret := m.Called(ctx, key, fields)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordRepository) HashGet(ctx context.Context, key, field string) (string, error) {
+	ret := m.Called(ctx, key, field)
+	return ret.String(0), ret.Error(1)
+}
+
+func (m *MockRecordRepository) HashGetAll(ctx context.Context, key string) (map[string]string, error) {
+	ret := m.Called(ctx, key)
+
+	err := ret.Error(1)
+	if fields, ok := ret.Get(0).(map[string]string); ok {
+		return fields, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) HashDelete(ctx context.Context, key string, fields ...string) (int, error) {
+	ret := m.Called(ctx, key, fields)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordRepository) ListPush(ctx context.Context, key string, end domain.ListEnd, values ...string) (int, error) {
+	ret := m.Called(ctx, key, end, values)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordRepository) ListPop(ctx context.Context, key string, end domain.ListEnd, count int) ([]string, error) {
+	ret := m.Called(ctx, key, end, count)
+
+	err := ret.Error(1)
+	if values, ok := ret.Get(0).([]string); ok {
+		return values, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) ListRange(ctx context.Context, key string, start, stop int) ([]string, error) {
+	ret := m.Called(ctx, key, start, stop)
+
+	err := ret.Error(1)
+	if values, ok := ret.Get(0).([]string); ok {
+		return values, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) ListLength(ctx context.Context, key string) (int, error) {
+	ret := m.Called(ctx, key)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordRepository) SetAdd(ctx context.Context, key string, members ...string) (int, error) {
+	ret := m.Called(ctx, key, members)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordRepository) SetRemove(ctx context.Context, key string, members ...string) (int, error) {
+	ret := m.Called(ctx, key, members)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordRepository) SetMembers(ctx context.Context, key string) ([]string, error) {
+	ret := m.Called(ctx, key)
+
+	err := ret.Error(1)
+	if members, ok := ret.Get(0).([]string); ok {
+		return members, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) SetContains(ctx context.Context, key, member string) (bool, error) {
+	ret := m.Called(ctx, key, member)
+	return ret.Bool(0), ret.Error(1)
+}
+
+func (m *MockRecordRepository) ZSetAdd(ctx context.Context, key string, members ...domain.ScoredMember) (int, error) {
+	ret := m.Called(ctx, key, members)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordRepository) ZSetIncr(ctx context.Context, key, member string, by float64) (float64, error) {
+	ret := m.Called(ctx, key, member, by)
+	return ret.Get(0).(float64), ret.Error(1)
+}
+
+func (m *MockRecordRepository) ZSetRank(ctx context.Context, key, member string, desc bool) (domain.ScoredMember, int, error) {
+	ret := m.Called(ctx, key, member, desc)
+	return ret.Get(0).(domain.ScoredMember), ret.Int(1), ret.Error(2)
+}
+
+func (m *MockRecordRepository) ZSetRange(ctx context.Context, key string, start, stop int, desc bool) ([]domain.ScoredMember, error) {
+	ret := m.Called(ctx, key, start, stop, desc)
+
+	err := ret.Error(1)
+	if members, ok := ret.Get(0).([]domain.ScoredMember); ok {
+		return members, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) ZSetRangeByScore(ctx context.Context, key string, r domain.ScoreRange) ([]domain.ScoredMember, error) {
+	ret := m.Called(ctx, key, r)
+
+	err := ret.Error(1)
+	if members, ok := ret.Get(0).([]domain.ScoredMember); ok {
+		return members, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) ZSetPop(ctx context.Context, key string, max bool, count int) ([]domain.ScoredMember, error) {
+	ret := m.Called(ctx, key, max, count)
+
+	err := ret.Error(1)
+	if members, ok := ret.Get(0).([]domain.ScoredMember); ok {
+		return members, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) ZSetRemove(ctx context.Context, key string, members ...string) (int, error) {
+	ret := m.Called(ctx, key, members)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordRepository) ZSetLength(ctx context.Context, key string) (int, error) {
+	ret := m.Called(ctx, key)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordRepository) StreamAdd(ctx context.Context, key string, values ...string) ([]int64, error) {
+	ret := m.Called(ctx, key, values)
+
+	err := ret.Error(1)
+	if ids, ok := ret.Get(0).([]int64); ok {
+		return ids, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) StreamRead(ctx context.Context, key string, after int64, count int) ([]domain.StreamEntry, error) {
+	ret := m.Called(ctx, key, after, count)
+
+	err := ret.Error(1)
+	if entries, ok := ret.Get(0).([]domain.StreamEntry); ok {
+		return entries, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) StreamCreateGroup(ctx context.Context, key, group string, after int64) error {
+	ret := m.Called(ctx, key, group, after)
+	return ret.Error(0)
+}
+
+func (m *MockRecordRepository) StreamDeleteGroup(ctx context.Context, key, group string) error {
+	ret := m.Called(ctx, key, group)
+	return ret.Error(0)
+}
+
+func (m *MockRecordRepository) StreamReadGroup(ctx context.Context, key, group, consumer string, count int) ([]domain.StreamEntry, error) {
+	ret := m.Called(ctx, key, group, consumer, count)
+
+	err := ret.Error(1)
+	if entries, ok := ret.Get(0).([]domain.StreamEntry); ok {
+		return entries, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) StreamAck(ctx context.Context, key, group string, ids ...int64) (int, error) {
+	ret := m.Called(ctx, key, group, ids)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordRepository) StreamPending(ctx context.Context, key, group string, count int) ([]domain.PendingEntry, error) {
+	ret := m.Called(ctx, key, group, count)
+
+	err := ret.Error(1)
+	if pending, ok := ret.Get(0).([]domain.PendingEntry); ok {
+		return pending, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) StreamClaim(ctx context.Context, key, group, consumer string, minIdle time.Duration, count int) ([]domain.StreamEntry, error) {
+	ret := m.Called(ctx, key, group, consumer, minIdle, count)
+
+	err := ret.Error(1)
+	if entries, ok := ret.Get(0).([]domain.StreamEntry); ok {
+		return entries, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordRepository) StreamTrim(ctx context.Context, key string, maxLength int, before time.Time) (int, error) {
+	ret := m.Called(ctx, key, maxLength, before)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordRepository) ExpireCollection(ctx context.Context, key string, ttl time.Duration) (*domain.Record, error) {
+	ret := m.Called(ctx, key, ttl)
+
+	err := ret.Error(1)
+	if r, ok := ret.Get(0).(*domain.Record); ok {
+		return r, err
+	}
+	return nil, err
+}
@@ -2,8 +2,11 @@ package mocks
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/stretchr/testify/mock"
+	"io"
 	"storage/domain"
+	"time"
 )
 
 type MockRecordService struct {
@@ -25,8 +28,8 @@ func (m *MockRecordService) Get(ctx context.Context, key string) (*domain.Record
 	return nil, err
 }
 
-func (m *MockRecordService) GetAll(ctx context.Context) []*domain.Record {
-	ret := m.Called(ctx)
+func (m *MockRecordService) GetAll(ctx context.Context, filter *domain.MetadataFilter) []*domain.Record {
+	ret := m.Called(ctx, filter)
 	if r, ok := ret.Get(0).([]*domain.Record); ok {
 		return r
 	}
@@ -42,3 +45,316 @@ func (m *MockRecordService) SetTtl(ctx context.Context, req *domain.Record) (*do
 	}
 	return nil, err
 }
+
+func (m *MockRecordService) GetAt(ctx context.Context, key string, at time.Time) (*domain.Record, error) {
+	ret := m.Called(ctx, key, at)
+
+	err := ret.Error(1)
+	if r, ok := ret.Get(0).(*domain.Record); ok {
+		return r, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordService) History(ctx context.Context, key string) ([]*domain.RecordVersion, error) {
+	ret := m.Called(ctx, key)
+
+	err := ret.Error(1)
+	if v, ok := ret.Get(0).([]*domain.RecordVersion); ok {
+		return v, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordService) Restore(ctx context.Context, key string, version int) (*domain.Record, error) {
+	ret := m.Called(ctx, key, version)
+
+	err := ret.Error(1)
+	if r, ok := ret.Get(0).(*domain.Record); ok {
+		return r, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordService) Incr(ctx context.Context, key string, delta float64) (*domain.Record, error) {
+	ret := m.Called(ctx, key, delta)
+
+	err := ret.Error(1)
+	if r, ok := ret.Get(0).(*domain.Record); ok {
+		return r, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordService) GetPath(ctx context.Context, key, path string) (json.RawMessage, error) {
+	ret := m.Called(ctx, key, path)
+
+	err := ret.Error(1)
+	if r, ok := ret.Get(0).(json.RawMessage); ok {
+		return r, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordService) Patch(ctx context.Context, key string, patchType domain.PatchType, patch string) (*domain.Record, error) {
+	ret := m.Called(ctx, key, patchType, patch)
+
+	err := ret.Error(1)
+	if r, ok := ret.Get(0).(*domain.Record); ok {
+		return r, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordService) CreateIndex(ctx context.Context, index *domain.RecordIndex) error {
+	ret := m.Called(ctx, index)
+	return ret.Error(0)
+}
+
+func (m *MockRecordService) DropIndex(ctx context.Context, name string) error {
+	ret := m.Called(ctx, name)
+	return ret.Error(0)
+}
+
+func (m *MockRecordService) Indexes(ctx context.Context) ([]*domain.RecordIndex, error) {
+	ret := m.Called(ctx)
+
+	err := ret.Error(1)
+	if r, ok := ret.Get(0).([]*domain.RecordIndex); ok {
+		return r, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordService) Query(ctx context.Context, query *domain.RecordQuery) ([]*domain.Record, *domain.QueryCursor, error) {
+	ret := m.Called(ctx, query)
+
+	err := ret.Error(2)
+	records, _ := ret.Get(0).([]*domain.Record)
+	next, _ := ret.Get(1).(*domain.QueryCursor)
+	return records, next, err
+}
+
+func (m *MockRecordService) PutBlob(ctx context.Context, record *domain.Record, body io.Reader) error {
+	ret := m.Called(ctx, record, body)
+	return ret.Error(0)
+}
+
+func (m *MockRecordService) OpenBlob(ctx context.Context, key string) (*domain.Record, io.ReadSeeker, error) {
+	ret := m.Called(ctx, key)
+
+	err := ret.Error(2)
+	r, _ := ret.Get(0).(*domain.Record)
+	body, _ := ret.Get(1).(io.ReadSeeker)
+	return r, body, err
+}
+
+func (m *MockRecordService) HashSet(ctx context.Context, key string, fields map[string]string) (int, error) {
+	ret := m.Called(ctx, key, fields)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordService) HashGet(ctx context.Context, key, field string) (string, error) {
+	ret := m.Called(ctx, key, field)
+	return ret.String(0), ret.Error(1)
+}
+
+func (m *MockRecordService) HashGetAll(ctx context.Context, key string) (map[string]string, error) {
+	ret := m.Called(ctx, key)
+
+	err := ret.Error(1)
+	if fields, ok := ret.Get(0).(map[string]string); ok {
+		return fields, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordService) HashDelete(ctx context.Context, key string, fields ...string) (int, error) {
+	ret := m.Called(ctx, key, fields)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordService) ListPush(ctx context.Context, key string, end domain.ListEnd, values ...string) (int, error) {
+	ret := m.Called(ctx, key, end, values)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordService) ListPop(ctx context.Context, key string, end domain.ListEnd, count int) ([]string, error) {
+	ret := m.Called(ctx, key, end, count)
+
+	err := ret.Error(1)
+	if values, ok := ret.Get(0).([]string); ok {
+		return values, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordService) ListRange(ctx context.Context, key string, start, stop int) ([]string, error) {
+	ret := m.Called(ctx, key, start, stop)
+
+	err := ret.Error(1)
+	if values, ok := ret.Get(0).([]string); ok {
+		return values, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordService) ListLength(ctx context.Context, key string) (int, error) {
+	ret := m.Called(ctx, key)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordService) SetAdd(ctx context.Context, key string, members ...string) (int, error) {
+	ret := m.Called(ctx, key, members)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordService) SetRemove(ctx context.Context, key string, members ...string) (int, error) {
+	ret := m.Called(ctx, key, members)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordService) SetMembers(ctx context.Context, key string) ([]string, error) {
+	ret := m.Called(ctx, key)
+
+	err := ret.Error(1)
+	if members, ok := ret.Get(0).([]string); ok {
+		return members, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordService) SetContains(ctx context.Context, key, member string) (bool, error) {
+	ret := m.Called(ctx, key, member)
+	return ret.Bool(0), ret.Error(1)
+}
+
+func (m *MockRecordService) ZSetAdd(ctx context.Context, key string, members ...domain.ScoredMember) (int, error) {
+	ret := m.Called(ctx, key, members)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordService) ZSetIncr(ctx context.Context, key, member string, by float64) (float64, error) {
+	ret := m.Called(ctx, key, member, by)
+	return ret.Get(0).(float64), ret.Error(1)
+}
+
+func (m *MockRecordService) ZSetRank(ctx context.Context, key, member string, desc bool) (domain.ScoredMember, int, error) {
+	ret := m.Called(ctx, key, member, desc)
+	return ret.Get(0).(domain.ScoredMember), ret.Int(1), ret.Error(2)
+}
+
+func (m *MockRecordService) ZSetRange(ctx context.Context, key string, start, stop int, desc bool) ([]domain.ScoredMember, error) {
+	ret := m.Called(ctx, key, start, stop, desc)
+
+	err := ret.Error(1)
+	if members, ok := ret.Get(0).([]domain.ScoredMember); ok {
+		return members, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordService) ZSetRangeByScore(ctx context.Context, key string, r domain.ScoreRange) ([]domain.ScoredMember, error) {
+	ret := m.Called(ctx, key, r)
+
+	err := ret.Error(1)
+	if members, ok := ret.Get(0).([]domain.ScoredMember); ok {
+		return members, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordService) ZSetPop(ctx context.Context, key string, max bool, count int) ([]domain.ScoredMember, error) {
+	ret := m.Called(ctx, key, max, count)
+
+	err := ret.Error(1)
+	if members, ok := ret.Get(0).([]domain.ScoredMember); ok {
+		return members, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordService) ZSetRemove(ctx context.Context, key string, members ...string) (int, error) {
+	ret := m.Called(ctx, key, members)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordService) ZSetLength(ctx context.Context, key string) (int, error) {
+	ret := m.Called(ctx, key)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordService) StreamAdd(ctx context.Context, key string, values ...string) ([]int64, error) {
+	ret := m.Called(ctx, key, values)
+
+	err := ret.Error(1)
+	if ids, ok := ret.Get(0).([]int64); ok {
+		return ids, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordService) StreamRead(ctx context.Context, key string, after int64, count int, block time.Duration) ([]domain.StreamEntry, error) {
+	ret := m.Called(ctx, key, after, count, block)
+
+	err := ret.Error(1)
+	if entries, ok := ret.Get(0).([]domain.StreamEntry); ok {
+		return entries, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordService) StreamCreateGroup(ctx context.Context, key, group string, after int64) error {
+	ret := m.Called(ctx, key, group, after)
+	return ret.Error(0)
+}
+
+func (m *MockRecordService) StreamDeleteGroup(ctx context.Context, key, group string) error {
+	ret := m.Called(ctx, key, group)
+	return ret.Error(0)
+}
+
+func (m *MockRecordService) StreamReadGroup(ctx context.Context, key, group, consumer string, count int, block time.Duration) ([]domain.StreamEntry, error) {
+	ret := m.Called(ctx, key, group, consumer, count, block)
+
+	err := ret.Error(1)
+	if entries, ok := ret.Get(0).([]domain.StreamEntry); ok {
+		return entries, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordService) StreamAck(ctx context.Context, key, group string, ids ...int64) (int, error) {
+	ret := m.Called(ctx, key, group, ids)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordService) StreamPending(ctx context.Context, key, group string, count int) ([]domain.PendingEntry, error) {
+	ret := m.Called(ctx, key, group, count)
+
+	err := ret.Error(1)
+	if pending, ok := ret.Get(0).([]domain.PendingEntry); ok {
+		return pending, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordService) StreamClaim(ctx context.Context, key, group, consumer string, minIdle time.Duration, count int) ([]domain.StreamEntry, error) {
+	ret := m.Called(ctx, key, group, consumer, minIdle, count)
+
+	err := ret.Error(1)
+	if entries, ok := ret.Get(0).([]domain.StreamEntry); ok {
+		return entries, err
+	}
+	return nil, err
+}
+
+func (m *MockRecordService) StreamTrim(ctx context.Context, key string, maxLength int, before time.Time) (int, error) {
+	ret := m.Called(ctx, key, maxLength, before)
+	return ret.Int(0), ret.Error(1)
+}
+
+func (m *MockRecordService) Close() error {
+	ret := m.Called()
+	return ret.Error(0)
+}
@@ -2,27 +2,146 @@ package domain
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"time"
 )
 
+var (
+	ErrRecordNotFound = errors.New("record not found")
+	ErrValueTooLarge  = errors.New("value too large")
+	ErrBlobReplaced   = errors.New("value was replaced while it was read")
+)
+
 type Record struct {
 	Key   string
 	Value string
+	Type  ValueType
 	Ttl   time.Duration
+	// Compression is the algorithm the value is stored with, or the one
+	// asked for on writes. Between the service and the repository a
+	// compressed Value holds the compressed bytes, base64 encoded.
+	Compression Compression
+	// KeyId is the data key the value is encrypted with. It is only set
+	// between the service and the repository, where an encrypted Value
+	// holds the sealed bytes, base64 encoded.
+	KeyId int
+	// Blob is set for bytes values that were streamed in and are stored in
+	// chunks; Value is empty then.
+	Blob *Blob
+	Metadata
+}
+
+// Blob describes a value stored in chunks of ChunkSize bytes.
+type Blob struct {
+	Id        string
+	Size      int64
+	Checksum  string
+	ChunkSize int
+}
+
+// RecordVersion is a value that a record held at some point, together with
+// who wrote it and when.
+type RecordVersion struct {
+	Key         string
+	Value       string
+	Type        ValueType
+	Compression Compression
+	KeyId       int
+	Version     int
+	ChangedBy   int
+	ChangedAt   time.Time
+}
+
+// RecordAccess counts the reads of a key, used to pick the keys to preload
+// into the cache on startup.
+type RecordAccess struct {
+	Key          string
+	Hits         int64
+	LastAccessAt time.Time
 }
 
 type RecordService interface {
+	CollectionService
+	StreamService
 	Set(ctx context.Context, record *Record) error
 	Get(ctx context.Context, key string) (*Record, error)
-	GetAll(ctx context.Context) []*Record
+	GetAt(ctx context.Context, key string, at time.Time) (*Record, error)
+	// GetAll returns the live records matching filter, every one when it is nil.
+	GetAll(ctx context.Context, filter *MetadataFilter) []*Record
 	SetTtl(ctx context.Context, req *Record) (*Record, error)
+	History(ctx context.Context, key string) ([]*RecordVersion, error)
+	Restore(ctx context.Context, key string, version int) (*Record, error)
+	// Incr adds delta to a numeric record and returns the updated record.
+	Incr(ctx context.Context, key string, delta float64) (*Record, error)
+	// GetPath returns the part of a json record that the json pointer path refers to.
+	GetPath(ctx context.Context, key, path string) (json.RawMessage, error)
+	// Patch applies a merge patch or json patch to a json record.
+	Patch(ctx context.Context, key string, patchType PatchType, patch string) (*Record, error)
+	CreateIndex(ctx context.Context, index *RecordIndex) error
+	DropIndex(ctx context.Context, name string) error
+	Indexes(ctx context.Context) ([]*RecordIndex, error)
+	// Query returns a page of matching records and the cursor of the next
+	// page, nil on the last page.
+	Query(ctx context.Context, query *RecordQuery) ([]*Record, *QueryCursor, error)
+	// PutBlob streams body into a bytes record stored in chunks and sets
+	// record.Blob.
+	PutBlob(ctx context.Context, record *Record, body io.Reader) error
+	// OpenBlob returns the record of key together with a reader of its raw
+	// value, which fetches chunked values a chunk at a time.
+	OpenBlob(ctx context.Context, key string) (*Record, io.ReadSeeker, error)
+	Close() error
 }
 
 type RecordRepository interface {
+	CollectionRepository
+	StreamRepository
 	Set(ctx context.Context, record *Record) error
 	Get(ctx context.Context, key string) (*Record, error)
-	GetAll(ctx context.Context) []*Record
+	// Update applies fn to the live record of key while holding a row lock
+	// and stores the result, so concurrent updates do not overwrite each other.
+	Update(ctx context.Context, key string, fn func(*Record) error) (*Record, error)
+	// PatchJson applies patch to the live json record of key inside the
+	// database and returns the patched record.
+	PatchJson(ctx context.Context, key string, patchType PatchType, patch string) (*Record, error)
+	// CreateIndex creates the expression index of index and records its definition.
+	CreateIndex(ctx context.Context, index *RecordIndex) error
+	DropIndex(ctx context.Context, name string) error
+	GetIndexes(ctx context.Context) ([]*RecordIndex, error)
+	// Query runs a query whose paths are all indexed.
+	Query(ctx context.Context, query *RecordQuery) ([]*Record, error)
+	// PutBlob stores body in chunks, failing with ErrValueTooLarge once it
+	// exceeds maxSize, and replaces the record with one pointing to them.
+	PutBlob(ctx context.Context, record *Record, body io.Reader, maxSize int64) error
+	GetChunk(ctx context.Context, blobId string, seq int) ([]byte, error)
+	GetDataKeys(ctx context.Context) ([]*DataKey, error)
+	// AddDataKey stores a new data key and sets its Id and CreatedAt.
+	AddDataKey(ctx context.Context, key *DataKey) error
+	// UpdateDataKey stores a data key wrapped by another master key.
+	UpdateDataKey(ctx context.Context, key *DataKey) error
+	// ReEncrypt passes up to limit records and versions encrypted with
+	// another data key than keyId to fn, which encrypts them again, and
+	// stores them without adding versions. It returns the keys of the
+	// records and the number of rows it changed.
+	ReEncrypt(ctx context.Context, keyId, limit int, fn func(*Record) error) ([]string, int, error)
+	// DeleteDataKeys deletes the data keys other than keepId that no value
+	// uses anymore and that were superseded before the given time.
+	DeleteDataKeys(ctx context.Context, keepId int, supersededBefore time.Time) (int64, error)
+	GetAll(ctx context.Context, filter *MetadataFilter) []*Record
 	Delete(ctx context.Context, keys ...string)
+	DeleteExpired(ctx context.Context, now time.Time, limit int) (int64, error)
+	DeleteIfExpired(ctx context.Context, now time.Time, keys ...string) (int64, error)
+	SampleVolatile(ctx context.Context, limit int) ([]*Record, error)
+	GetHistory(ctx context.Context, key string) ([]*RecordVersion, error)
+	GetVersion(ctx context.Context, key string, version int) (*RecordVersion, error)
+	GetVersionAt(ctx context.Context, key string, at time.Time) (*RecordVersion, error)
+	// TrackAccesses adds the given hits to the access counts of the keys and
+	// stamps the last access time of their records.
+	TrackAccesses(ctx context.Context, accesses []*RecordAccess) error
+	DeleteAccessesBefore(ctx context.Context, before time.Time) (int64, error)
+	// GetHot returns the live records read most often since the given time.
+	GetHot(ctx context.Context, since time.Time, limit int) ([]*Record, error)
 }
 
 func (r *Record) IsExpired() bool {
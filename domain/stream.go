@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrGroupNotFound = errors.New("consumer group not found")
+	ErrGroupExists   = errors.New("consumer group already exists")
+)
+
+// StreamLatest makes a consumer group start after the last entry of the
+// stream instead of after a given id.
+const StreamLatest int64 = -1
+
+// StreamEntry is an entry of a stream. Ids grow with every entry added to
+// a stream and are never reused, not even once the entry is trimmed.
+type StreamEntry struct {
+	Id        int64     `json:"id"`
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PendingEntry is an entry delivered to a consumer of a group that has not
+// acknowledged it yet.
+type PendingEntry struct {
+	Id          int64     `json:"id"`
+	Consumer    string    `json:"consumer"`
+	DeliveredAt time.Time `json:"delivered_at"`
+	Deliveries  int       `json:"deliveries"`
+}
+
+// StreamService appends entries to stream records and reads them, either
+// by id or through consumer groups: each entry is delivered to one consumer
+// of a group and stays pending until that consumer acknowledges it, or is
+// claimed by another one once it has been pending for too long. Unlike
+// other collections, streams are kept once empty.
+type StreamService interface {
+	// StreamAdd appends values to a stream and returns their ids.
+	StreamAdd(ctx context.Context, key string, values ...string) ([]int64, error)
+	// StreamRead returns up to count entries after the given id, waiting up
+	// to block for new ones when there are none.
+	StreamRead(ctx context.Context, key string, after int64, count int, block time.Duration) ([]StreamEntry, error)
+	// StreamCreateGroup creates a consumer group delivering the entries
+	// after the given id, or StreamLatest.
+	StreamCreateGroup(ctx context.Context, key, group string, after int64) error
+	StreamDeleteGroup(ctx context.Context, key, group string) error
+	// StreamReadGroup delivers to consumer up to count entries that the
+	// group has not delivered yet, waiting up to block for new ones when
+	// there are none.
+	StreamReadGroup(ctx context.Context, key, group, consumer string, count int, block time.Duration) ([]StreamEntry, error)
+	// StreamAck acknowledges pending entries and returns the number of them.
+	StreamAck(ctx context.Context, key, group string, ids ...int64) (int, error)
+	StreamPending(ctx context.Context, key, group string, count int) ([]PendingEntry, error)
+	// StreamClaim delivers to consumer up to count entries that have been
+	// pending for at least minIdle.
+	StreamClaim(ctx context.Context, key, group, consumer string, minIdle time.Duration, count int) ([]StreamEntry, error)
+	// StreamTrim deletes the oldest entries beyond maxLength and the entries
+	// added before the given time, zero values disabling either bound, and
+	// returns the number of deleted entries.
+	StreamTrim(ctx context.Context, key string, maxLength int, before time.Time) (int, error)
+}
+
+type StreamRepository interface {
+	StreamAdd(ctx context.Context, key string, values ...string) ([]int64, error)
+	StreamRead(ctx context.Context, key string, after int64, count int) ([]StreamEntry, error)
+	StreamCreateGroup(ctx context.Context, key, group string, after int64) error
+	StreamDeleteGroup(ctx context.Context, key, group string) error
+	StreamReadGroup(ctx context.Context, key, group, consumer string, count int) ([]StreamEntry, error)
+	StreamAck(ctx context.Context, key, group string, ids ...int64) (int, error)
+	StreamPending(ctx context.Context, key, group string, count int) ([]PendingEntry, error)
+	StreamClaim(ctx context.Context, key, group, consumer string, minIdle time.Duration, count int) ([]StreamEntry, error)
+	StreamTrim(ctx context.Context, key string, maxLength int, before time.Time) (int, error)
+}
@@ -11,7 +11,7 @@ type User struct {
 type UserService interface {
 	Register(ctx context.Context, req *User) (*User, error)
 	Login(ctx context.Context, req *User) (string, error)
-	VerifyToken(token string) bool
+	VerifyToken(token string) (int, bool)
 }
 
 type UserRepository interface {
@@ -21,5 +21,18 @@ type UserRepository interface {
 
 type TokenGenerator interface {
 	Generate(id int) (string, error)
-	Verify(token string) bool
+	Verify(token string) (int, bool)
+}
+
+type userIdKey struct{}
+
+// ContextWithUserId returns a copy of ctx carrying the id of the authenticated user.
+func ContextWithUserId(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, userIdKey{}, id)
+}
+
+// UserIdFromContext returns the id of the authenticated user, or 0 when the request is anonymous.
+func UserIdFromContext(ctx context.Context) int {
+	id, _ := ctx.Value(userIdKey{}).(int)
+	return id
 }
@@ -0,0 +1,168 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ValueType is the type of the value of a record. Values are always carried
+// as text in their canonical form: numbers and booleans as their JSON
+// literal, JSON documents compacted and binary blobs base64 encoded.
+type ValueType string
+
+const (
+	TypeString ValueType = "string"
+	TypeInt    ValueType = "int"
+	TypeFloat  ValueType = "float"
+	TypeBool   ValueType = "bool"
+	TypeJson   ValueType = "json"
+	TypeBytes  ValueType = "bytes"
+
+	// Collection types hold string elements stored apart from the record,
+	// whose value stays empty.
+	TypeHash   ValueType = "hash"
+	TypeList   ValueType = "list"
+	TypeSet    ValueType = "set"
+	TypeZSet   ValueType = "zset"
+	TypeStream ValueType = "stream"
+)
+
+var ErrWrongType = errors.New("operation not supported for the value type")
+
+func ParseValueType(s string) (ValueType, error) {
+	switch t := ValueType(s); t {
+	case TypeString, TypeInt, TypeFloat, TypeBool, TypeJson, TypeBytes:
+		return t, nil
+	case "":
+		return TypeString, nil
+	default:
+		return "", fmt.Errorf("unknown value type %q", s)
+	}
+}
+
+// IsCollection reports whether t is a collection type, written and read
+// through the operations of its elements.
+func (t ValueType) IsCollection() bool {
+	switch t {
+	case TypeHash, TypeList, TypeSet, TypeZSet, TypeStream:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsNumeric reports whether values of t support arithmetic.
+func (t ValueType) IsNumeric() bool {
+	return t == TypeInt || t == TypeFloat
+}
+
+// NormalizeValue checks that value is a valid value of type t and returns
+// its canonical form.
+func NormalizeValue(t ValueType, value string) (string, error) {
+	switch t {
+	case TypeString, "":
+		return value, nil
+	case TypeInt:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid int value %q", value)
+		}
+		return strconv.FormatInt(i, 10), nil
+	case TypeFloat:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", fmt.Errorf("invalid float value %q", value)
+		}
+		return FormatFloat(f), nil
+	case TypeBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid bool value %q", value)
+		}
+		return strconv.FormatBool(b), nil
+	case TypeJson:
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(value)); err != nil {
+			return "", fmt.Errorf("invalid json value: %w", err)
+		}
+		return buf.String(), nil
+	case TypeBytes:
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid bytes value: %w", err)
+		}
+		return base64.StdEncoding.EncodeToString(b), nil
+	default:
+		return "", fmt.Errorf("unknown value type %q", t)
+	}
+}
+
+// FormatFloat returns the canonical form of a float value.
+func FormatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Normalize defaults the type of r to string and brings its value into
+// canonical form.
+func (r *Record) Normalize() error {
+	if r.Type == "" {
+		r.Type = TypeString
+	}
+	value, err := NormalizeValue(r.Type, r.Value)
+	if err != nil {
+		return err
+	}
+	r.Value = value
+	return nil
+}
+
+// Bytes returns the raw content of r: the decoded blob for bytes values and
+// the canonical text for every other type but collections, which have none.
+func (r *Record) Bytes() ([]byte, error) {
+	if r.Type.IsCollection() {
+		return nil, ErrWrongType
+	}
+	if r.Type == TypeBytes {
+		return base64.StdEncoding.DecodeString(r.Value)
+	}
+	return []byte(r.Value), nil
+}
+
+// Incr adds delta to the numeric value of r. Int values only accept whole
+// deltas that keep them within range.
+func (r *Record) Incr(delta float64) error {
+	switch r.Type {
+	case TypeInt:
+		i, err := strconv.ParseInt(r.Value, 10, 64)
+		if err != nil {
+			return err
+		}
+		// float64(math.MaxInt64) rounds up to 1<<63, which int64 cannot hold
+		if delta != math.Trunc(delta) || math.Abs(delta) >= 1<<63 {
+			return fmt.Errorf("cannot add %v to an int value", delta)
+		}
+		d := int64(delta)
+		if (d > 0 && i > math.MaxInt64-d) || (d < 0 && i < math.MinInt64-d) {
+			return errors.New("increment would overflow")
+		}
+		r.Value = strconv.FormatInt(i+d, 10)
+	case TypeFloat:
+		f, err := strconv.ParseFloat(r.Value, 64)
+		if err != nil {
+			return err
+		}
+		f += delta
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return errors.New("increment would overflow")
+		}
+		r.Value = FormatFloat(f)
+	default:
+		return ErrWrongType
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Package encryption implements the envelope encryption of record values:
+// values are sealed with AES-256-GCM data keys, which are only stored
+// wrapped by a master key that stays in the service configuration.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeySize is the size of master and data keys, for AES-256.
+const KeySize = 32
+
+// Key seals and opens values with AES-256-GCM.
+type Key struct {
+	aead cipher.AEAD
+}
+
+func NewKey(key []byte) (*Key, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("keys must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{aead: aead}, nil
+}
+
+// Seal encrypts plaintext under a random nonce, which is prepended to the
+// result. aad is authenticated but not encrypted; Open needs it again.
+func (k *Key) Seal(plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize(), k.aead.NonceSize()+len(plaintext)+k.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return k.aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func (k *Key) Open(sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < k.aead.NonceSize() {
+		return nil, errors.New("sealed value too short")
+	}
+	nonce, ciphertext := sealed[:k.aead.NonceSize()], sealed[k.aead.NonceSize():]
+	return k.aead.Open(nil, nonce, ciphertext, aad)
+}
+
+// NewDataKey generates a random data key.
+func NewDataKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	_, err := rand.Read(key)
+	return key, err
+}
+
+// MasterKey wraps data keys. Id identifies it without revealing it, so
+// that wrapped keys tell which master key they need.
+type MasterKey struct {
+	*Key
+	Id string
+}
+
+func NewMasterKey(key []byte) (*MasterKey, error) {
+	k, err := NewKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	sum := sha256.Sum256(key)
+	return &MasterKey{Key: k, Id: hex.EncodeToString(sum[:8])}, nil
+}
+
+// LoadMasterKey reads a base64 encoded master key from file, or takes it
+// from value when no file is given. It returns nil when neither is set.
+func LoadMasterKey(file, value string) (*MasterKey, error) {
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		value = string(b)
+	}
+	if value == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	return NewMasterKey(key)
+}
+
+func (m *MasterKey) Wrap(dataKey []byte) ([]byte, error) {
+	return m.Seal(dataKey, []byte(m.Id))
+}
+
+func (m *MasterKey) Unwrap(wrapped []byte) ([]byte, error) {
+	return m.Open(wrapped, []byte(m.Id))
+}
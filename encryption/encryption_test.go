@@ -0,0 +1,78 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	raw, err := NewDataKey()
+	assert.NoError(t, err)
+	key, err := NewKey(raw)
+	assert.NoError(t, err)
+
+	sealed, err := key.Seal([]byte("secret"), []byte("key"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(sealed), "secret")
+
+	again, err := key.Seal([]byte("secret"), []byte("key"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "every seal uses a new nonce")
+
+	plain, err := key.Open(sealed, []byte("key"))
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", string(plain))
+
+	_, err = key.Open(sealed, []byte("other key"))
+	assert.Error(t, err, "values are bound to their aad")
+	_, err = key.Open(sealed[:4], []byte("key"))
+	assert.Error(t, err)
+
+	_, err = NewKey([]byte("short"))
+	assert.EqualError(t, err, "keys must be 32 bytes, got 5")
+}
+
+func TestMasterKey(t *testing.T) {
+	raw := bytes.Repeat([]byte{7}, KeySize)
+	encoded := base64.StdEncoding.EncodeToString(raw)
+
+	file := filepath.Join(t.TempDir(), "master.key")
+	assert.NoError(t, os.WriteFile(file, []byte(encoded+"\n"), 0o600))
+
+	fromFile, err := LoadMasterKey(file, "")
+	assert.NoError(t, err)
+	fromValue, err := LoadMasterKey("", encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, fromFile.Id, fromValue.Id)
+	assert.Len(t, fromFile.Id, 16)
+
+	dataKey, err := NewDataKey()
+	assert.NoError(t, err)
+	wrapped, err := fromFile.Wrap(dataKey)
+	assert.NoError(t, err)
+	unwrapped, err := fromValue.Unwrap(wrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, dataKey, unwrapped)
+
+	other, err := NewMasterKey(bytes.Repeat([]byte{8}, KeySize))
+	assert.NoError(t, err)
+	_, err = other.Unwrap(wrapped)
+	assert.Error(t, err)
+
+	t.Run("not set", func(t *testing.T) {
+		m, err := LoadMasterKey("", "")
+		assert.NoError(t, err)
+		assert.Nil(t, m)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := LoadMasterKey("", "not base64!")
+		assert.Error(t, err)
+		_, err = LoadMasterKey("", base64.StdEncoding.EncodeToString([]byte("short")))
+		assert.EqualError(t, err, "invalid master key: keys must be 32 bytes, got 5")
+	})
+}
@@ -0,0 +1,28 @@
+package health
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+// DatabaseCheck pings the database behind db.
+func DatabaseCheck(db *gorm.DB) Check {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// CacheChecker is implemented by services that own a cache.
+type CacheChecker interface {
+	CheckCache(ctx context.Context) error
+}
+
+// WarmUpChecker is implemented by services that preload data before they
+// should receive traffic.
+type WarmUpChecker interface {
+	CheckWarmUp(ctx context.Context) error
+}
@@ -0,0 +1,76 @@
+package health
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type handler struct {
+	service *Service
+}
+
+func NewHealthController(r gin.IRoutes, s *Service) {
+	h := &handler{service: s}
+
+	r.GET("/livez", h.live)
+	r.GET("/healthz", h.health)
+	r.GET("/readyz", h.ready)
+}
+
+// live reports that the process is up and serving requests.
+func (h *handler) live(c *gin.Context) {
+	c.JSON(http.StatusOK, reportResponse{Status: StatusOk})
+}
+
+// health reports the status of every dependency.
+func (h *handler) health(c *gin.Context) {
+	report := h.service.Report(c.Request.Context())
+
+	status := http.StatusOK
+	if report.Status != StatusOk {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, toReportResponse(report))
+}
+
+// ready fails while a dependency is down or the service is shutting down.
+func (h *handler) ready(c *gin.Context) {
+	report := h.service.Report(c.Request.Context())
+	if report.ShuttingDown {
+		report.Status = StatusFail
+	}
+
+	status := http.StatusOK
+	if report.Status != StatusOk {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, toReportResponse(report))
+}
+
+type checkResponse struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type reportResponse struct {
+	Status       string                    `json:"status"`
+	ShuttingDown bool                      `json:"shutting_down,omitempty"`
+	Checks       map[string]*checkResponse `json:"checks,omitempty"`
+}
+
+func toReportResponse(r *Report) *reportResponse {
+	res := &reportResponse{
+		Status:       r.Status,
+		ShuttingDown: r.ShuttingDown,
+		Checks:       make(map[string]*checkResponse, len(r.Checks)),
+	}
+	for name, check := range r.Checks {
+		res.Checks[name] = &checkResponse{
+			Status:   check.Status,
+			Error:    check.Error,
+			Duration: check.Duration.String(),
+		}
+	}
+	return res
+}
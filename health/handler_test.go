@@ -0,0 +1,71 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"storage/util"
+	"testing"
+)
+
+func Test_handler_health(t *testing.T) {
+	t.Run("all checks pass", func(t *testing.T) {
+		s := NewHealthService()
+		s.AddCheck("database", func(context.Context) error { return nil })
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		h := handler{service: s}
+		h.health(ctx)
+
+		var res reportResponse
+		err := json.Unmarshal(w.Body.Bytes(), &res)
+
+		assert.Equal(t, 200, w.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, StatusOk, res.Status)
+		assert.Equal(t, StatusOk, res.Checks["database"].Status)
+	})
+
+	t.Run("failing check", func(t *testing.T) {
+		s := NewHealthService()
+		s.AddCheck("database", func(context.Context) error { return nil })
+		s.AddCheck("cache", func(context.Context) error { return errors.New("cache disabled") })
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		h := handler{service: s}
+		h.health(ctx)
+
+		var res reportResponse
+		err := json.Unmarshal(w.Body.Bytes(), &res)
+
+		assert.Equal(t, 503, w.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, StatusFail, res.Status)
+		assert.Equal(t, "cache disabled", res.Checks["cache"].Error)
+		assert.Equal(t, StatusOk, res.Checks["database"].Status)
+	})
+}
+
+func Test_handler_ready(t *testing.T) {
+	s := NewHealthService()
+	s.AddCheck("database", func(context.Context) error { return nil })
+	h := handler{service: s}
+
+	w := httptest.NewRecorder()
+	h.ready(util.GetTestGinContext(w))
+	assert.Equal(t, 200, w.Code)
+
+	s.SetShuttingDown()
+
+	w = httptest.NewRecorder()
+	h.ready(util.GetTestGinContext(w))
+	assert.Equal(t, 503, w.Code)
+
+	w = httptest.NewRecorder()
+	h.live(util.GetTestGinContext(w))
+	assert.Equal(t, 200, w.Code)
+}
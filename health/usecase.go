@@ -0,0 +1,109 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOk   = "ok"
+	StatusFail = "fail"
+)
+
+const checkTimeout = 2 * time.Second
+
+// Check reports whether a dependency is usable; a nil error means healthy.
+type Check func(ctx context.Context) error
+
+type CheckResult struct {
+	Status   string
+	Error    string
+	Duration time.Duration
+}
+
+type Report struct {
+	Status       string
+	ShuttingDown bool
+	Checks       map[string]*CheckResult
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+type Service struct {
+	mu           sync.RWMutex
+	checks       []namedCheck
+	shuttingDown atomic.Bool
+}
+
+func NewHealthService() *Service {
+	return &Service{}
+}
+
+func (s *Service) AddCheck(name string, check Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks = append(s.checks, namedCheck{name: name, check: check})
+}
+
+// SetShuttingDown makes readiness fail from now on, so that the
+// orchestrator stops routing traffic while in-flight requests drain.
+func (s *Service) SetShuttingDown() {
+	s.shuttingDown.Store(true)
+}
+
+func (s *Service) ShuttingDown() bool {
+	return s.shuttingDown.Load()
+}
+
+// Report runs all checks concurrently, each bounded by checkTimeout.
+func (s *Service) Report(ctx context.Context) *Report {
+	s.mu.RLock()
+	checks := append([]namedCheck(nil), s.checks...)
+	s.mu.RUnlock()
+
+	results := make([]*CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c namedCheck) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c.check)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := &Report{
+		Status:       StatusOk,
+		ShuttingDown: s.ShuttingDown(),
+		Checks:       make(map[string]*CheckResult, len(checks)),
+	}
+	for i, c := range checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusOk {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func runCheck(ctx context.Context, check Check) *CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := &CheckResult{
+		Status:   StatusOk,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,25 @@
+package invalidation
+
+import (
+	"context"
+	"storage/domain"
+)
+
+const (
+	BusPostgres = "postgres"
+	BusNone     = "none"
+)
+
+type noopBus struct{}
+
+// NewNoopBus creates a bus for single instance deployments that drops
+// every invalidation.
+func NewNoopBus() domain.InvalidationBus {
+	return noopBus{}
+}
+
+func (noopBus) Publish(context.Context, ...string) error {
+	return nil
+}
+
+func (noopBus) Subscribe(func(keys []string)) {}
@@ -0,0 +1,186 @@
+package invalidation
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/jackc/pgx/v5/stdlib"
+	"log"
+	"storage/domain"
+	"storage/metrics"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultChannel = "record_invalidation"
+
+	// maxPayload stays below the 8000 bytes postgres accepts for a notification.
+	maxPayload = 7900
+	// retryDelay is how long to wait before listening again after the
+	// listening connection failed.
+	retryDelay = time.Second
+)
+
+type message struct {
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys"`
+}
+
+// PostgresBus broadcasts invalidations with postgres NOTIFY and receives
+// them on a connection that LISTENs on the channel. Invalidations published
+// by the bus itself are ignored.
+type PostgresBus struct {
+	db      *sql.DB
+	channel string
+	origin  string
+
+	mu       sync.RWMutex
+	handlers []func(keys []string)
+}
+
+func NewPostgresBus(db *sql.DB, channel string) *PostgresBus {
+	return &PostgresBus{
+		db:      db,
+		channel: channel,
+		origin:  newOrigin(),
+	}
+}
+
+var _ domain.InvalidationBus = (*PostgresBus)(nil)
+
+func newOrigin() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Publish notifies the other replicas, splitting keys over as many
+// notifications as needed to respect the payload limit.
+func (b *PostgresBus) Publish(ctx context.Context, keys ...string) error {
+	for _, payload := range b.encode(keys) {
+		if _, err := b.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", b.channel, payload); err != nil {
+			return err
+		}
+	}
+	metrics.CacheInvalidations.WithLabelValues("published").Add(float64(len(keys)))
+	return nil
+}
+
+// encode packs keys into as few payloads as possible. No keys, or a key too
+// long for any payload, invalidate everything.
+func (b *PostgresBus) encode(keys []string) []string {
+	overhead := len(b.marshal(nil))
+	var payloads, batch []string
+	size := overhead
+	for _, key := range keys {
+		quoted, _ := json.Marshal(key)
+		if overhead+len(quoted) > maxPayload {
+			return []string{b.marshal(nil)}
+		}
+		if len(batch) > 0 && size+len(quoted)+1 > maxPayload {
+			payloads = append(payloads, b.marshal(batch))
+			batch, size = nil, overhead
+		}
+		batch = append(batch, key)
+		size += len(quoted) + 1
+	}
+
+	if len(batch) > 0 || len(payloads) == 0 {
+		payloads = append(payloads, b.marshal(batch))
+	}
+	return payloads
+}
+
+func (b *PostgresBus) marshal(keys []string) string {
+	payload, _ := json.Marshal(message{Origin: b.origin, Keys: keys})
+	return string(payload)
+}
+
+func (b *PostgresBus) Subscribe(handler func(keys []string)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// Run listens for invalidations until ctx is cancelled, reconnecting when
+// the connection fails. Since notifications sent while reconnecting are
+// lost, subscribers are told to drop everything once listening again.
+func (b *PostgresBus) Run(ctx context.Context) {
+	reconnect := false
+	for {
+		err := b.listen(ctx, reconnect)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("invalidation bus: %v\n", err)
+		reconnect = true
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+func (b *PostgresBus) listen(ctx context.Context, reconnect bool) error {
+	conn, err := b.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.New("listening requires the pgx driver")
+		}
+
+		pgConn := c.Conn()
+		if _, err := pgConn.Exec(ctx, "LISTEN "+quoteIdentifier(b.channel)); err != nil {
+			return err
+		}
+		if reconnect {
+			b.dispatch(nil)
+		}
+
+		for {
+			n, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			b.receive(n.Payload)
+		}
+	})
+}
+
+func (b *PostgresBus) receive(payload string) {
+	var msg message
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		log.Printf("invalidation bus: invalid payload: %v\n", err)
+		return
+	}
+	if msg.Origin == b.origin {
+		return
+	}
+	b.dispatch(msg.Keys)
+}
+
+func (b *PostgresBus) dispatch(keys []string) {
+	metrics.CacheInvalidations.WithLabelValues("received").Add(float64(len(keys)))
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, handler := range b.handlers {
+		handler(keys)
+	}
+}
+
+func quoteIdentifier(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
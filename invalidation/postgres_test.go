@@ -0,0 +1,65 @@
+package invalidation
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestPostgresBus_Publish(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	b := NewPostgresBus(db, DefaultChannel)
+	payload := `{"origin":"` + b.origin + `","keys":["a","b"]}`
+	mock.ExpectExec(`SELECT pg_notify\(\$1, \$2\)`).
+		WithArgs(DefaultChannel, payload).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, b.Publish(context.TODO(), "a", "b"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresBus_encode(t *testing.T) {
+	b := NewPostgresBus(nil, DefaultChannel)
+
+	t.Run("splits large invalidations", func(t *testing.T) {
+		keys := make([]string, 1000)
+		for i := range keys {
+			keys[i] = strings.Repeat("k", 20)
+		}
+
+		payloads := b.encode(keys)
+		assert.Greater(t, len(payloads), 1)
+
+		var decoded []string
+		for _, payload := range payloads {
+			assert.LessOrEqual(t, len(payload), maxPayload)
+			var msg message
+			assert.NoError(t, json.Unmarshal([]byte(payload), &msg))
+			decoded = append(decoded, msg.Keys...)
+		}
+		assert.Equal(t, keys, decoded)
+	})
+
+	t.Run("key too long invalidates everything", func(t *testing.T) {
+		payloads := b.encode([]string{"a", strings.Repeat("k", maxPayload)})
+		assert.Equal(t, []string{b.marshal(nil)}, payloads)
+	})
+}
+
+func TestPostgresBus_receive(t *testing.T) {
+	b := NewPostgresBus(nil, DefaultChannel)
+	var received [][]string
+	b.Subscribe(func(keys []string) { received = append(received, keys) })
+
+	b.receive(`{"origin":"other","keys":["a"]}`)
+	b.receive(`{"origin":"other","keys":null}`)
+	b.receive(b.marshal([]string{"own"}))
+	b.receive(`not json`)
+
+	assert.Equal(t, [][]string{{"a"}, nil}, received)
+}
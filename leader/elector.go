@@ -0,0 +1,137 @@
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"hash/fnv"
+	"log"
+	"storage/domain"
+	"storage/lifecycle"
+	"storage/metrics"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultInterval = 5 * time.Second
+
+// Elector elects a single leader among the replicas sharing a postgres
+// database using a session level advisory lock. The leader keeps a dedicated
+// connection open to hold the lock; when its process or connection dies
+// postgres releases the lock and another replica takes over on its next
+// attempt.
+type Elector struct {
+	db       *sql.DB
+	name     string
+	key      int64
+	interval time.Duration
+
+	conn   *sql.Conn
+	leader atomic.Bool
+}
+
+// NewPostgresElector creates an elector for the election called name. Every
+// interval the leader checks that it still holds the lock and followers try
+// to acquire it.
+func NewPostgresElector(db *sql.DB, name string, interval time.Duration) *Elector {
+	metrics.Leader.WithLabelValues(name).Set(0)
+	return &Elector{
+		db:       db,
+		name:     name,
+		key:      lockKey(name),
+		interval: interval,
+	}
+}
+
+var _ domain.Leader = (*Elector)(nil)
+
+// lockKey maps an election name to an advisory lock key.
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run takes part in the election until ctx is cancelled, then gives up the
+// leadership so that another replica can take over right away.
+func (e *Elector) Run(ctx context.Context) {
+	e.campaign(ctx)
+	lifecycle.Every(ctx, e.interval, e.campaign)
+
+	if e.conn != nil {
+		e.release()
+	}
+}
+
+func (e *Elector) campaign(ctx context.Context) {
+	if e.conn != nil {
+		if err := e.check(ctx); err == nil {
+			return
+		} else if ctx.Err() == nil {
+			log.Printf("leader election %s: lost connection: %v\n", e.name, err)
+		}
+		// campaign again on the next round, giving the other replicas a
+		// chance to take over
+		e.release()
+		return
+	}
+
+	conn, err := e.db.Conn(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("leader election %s: %v\n", e.name, err)
+		}
+		return
+	}
+
+	var acquired bool
+	if err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", e.key).Scan(&acquired); err != nil || !acquired {
+		if err != nil && ctx.Err() == nil {
+			log.Printf("leader election %s: %v\n", e.name, err)
+		}
+		_ = conn.Close()
+		return
+	}
+
+	e.conn = conn
+	e.setLeader(true)
+}
+
+// check verifies that the session holding the lock is still alive.
+func (e *Elector) check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, e.interval)
+	defer cancel()
+
+	var one int
+	return e.conn.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+}
+
+// release steps down and closes the session holding the lock. The
+// connection is reported as broken so that the pool discards it instead of
+// keeping the session, and with it the lock, alive.
+func (e *Elector) release() {
+	e.setLeader(false)
+	_ = e.conn.Raw(func(any) error { return driver.ErrBadConn })
+	_ = e.conn.Close()
+	e.conn = nil
+}
+
+func (e *Elector) setLeader(leader bool) {
+	if e.leader.Swap(leader) == leader {
+		return
+	}
+
+	state := "follower"
+	value := 0.0
+	if leader {
+		state = "leader"
+		value = 1
+	}
+	log.Printf("leader election %s: became %s\n", e.name, state)
+	metrics.Leader.WithLabelValues(e.name).Set(value)
+	metrics.LeaderTransitions.WithLabelValues(e.name, state).Inc()
+}
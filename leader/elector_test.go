@@ -0,0 +1,83 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestElector_campaign(t *testing.T) {
+	lock := `SELECT pg_try_advisory_lock\(\$1\)`
+
+	t.Run("acquires the lock", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+
+		e := NewPostgresElector(db, "test", time.Second)
+		mock.ExpectQuery(lock).WithArgs(lockKey("test")).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+		mock.ExpectQuery(`SELECT 1`).
+			WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+
+		e.campaign(context.TODO())
+		assert.True(t, e.IsLeader())
+
+		// the leader only checks its session afterwards
+		e.campaign(context.TODO())
+		assert.True(t, e.IsLeader())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("lock held by another replica", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+
+		e := NewPostgresElector(db, "test", time.Second)
+		mock.ExpectQuery(lock).WithArgs(lockKey("test")).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+
+		e.campaign(context.TODO())
+		assert.False(t, e.IsLeader())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("steps down when the session is lost", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+
+		e := NewPostgresElector(db, "test", time.Second)
+		mock.ExpectQuery(lock).WithArgs(lockKey("test")).
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+		e.campaign(context.TODO())
+		assert.True(t, e.IsLeader())
+
+		mock.ExpectQuery(`SELECT 1`).WillReturnError(errors.New("connection reset"))
+		e.campaign(context.TODO())
+		assert.False(t, e.IsLeader())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestElector_Run(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	e := NewPostgresElector(db, "test", time.Hour)
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).WithArgs(lockKey("test")).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, e.IsLeader, time.Second, time.Millisecond)
+	cancel()
+	<-done
+	assert.False(t, e.IsLeader())
+}
@@ -0,0 +1,146 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type closer struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Manager owns the http server, background workers and resources of the
+// process and tears them down in order when a termination signal arrives:
+// shutdown hooks run first (e.g. failing readiness), then in-flight requests
+// drain, workers are cancelled and awaited, and finally closers run in
+// reverse registration order.
+type Manager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	jobs   sync.WaitGroup
+
+	mu         sync.Mutex
+	hooks      []func()
+	closers    []closer
+	DrainDelay time.Duration
+	Timeout    time.Duration
+}
+
+func NewManager() *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		ctx:        ctx,
+		cancel:     cancel,
+		DrainDelay: 5 * time.Second,
+		Timeout:    30 * time.Second,
+	}
+}
+
+// Go runs job in the background; its context is cancelled on shutdown.
+func (m *Manager) Go(name string, job func(ctx context.Context)) {
+	m.jobs.Add(1)
+	go func() {
+		defer m.jobs.Done()
+		job(m.ctx)
+		log.Printf("lifecycle: %s stopped\n", name)
+	}()
+}
+
+// OnShutdown registers fn to run as soon as shutdown starts, before the
+// server stops accepting requests.
+func (m *Manager) OnShutdown(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, fn)
+}
+
+// OnClose registers fn to release a resource once the server and workers stopped.
+func (m *Manager) OnClose(name string, fn func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closers = append(m.closers, closer{name: name, fn: fn})
+}
+
+// Serve runs srv until SIGINT or SIGTERM is received or the server fails,
+// then shuts everything down.
+func (m *Manager) Serve(srv *http.Server) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	select {
+	case err := <-errCh:
+		// the server never started or died, there are no requests to drain
+		_ = m.Shutdown(nil)
+		return err
+	case sig := <-quit:
+		log.Printf("lifecycle: received %s, shutting down\n", sig)
+	}
+
+	return m.Shutdown(srv)
+}
+
+// Shutdown stops srv, if given, and everything registered on m.
+func (m *Manager) Shutdown(srv *http.Server) error {
+	m.mu.Lock()
+	hooks := append([]func(){}, m.hooks...)
+	closers := append([]closer{}, m.closers...)
+	m.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.Timeout)
+	defer cancel()
+
+	var firstErr error
+	if srv != nil {
+		// give load balancers time to observe the failing readiness probe
+		time.Sleep(m.DrainDelay)
+		if err := srv.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			firstErr = err
+		}
+	}
+
+	m.cancel()
+	m.jobs.Wait()
+
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].fn(ctx); err != nil {
+			log.Printf("lifecycle: closing %s: %v\n", closers[i].name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// Every calls job every interval until ctx is cancelled.
+func Every(ctx context.Context, interval time.Duration, job func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job(ctx)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestManager_Shutdown(t *testing.T) {
+	m := NewManager()
+
+	var order []string
+	m.OnShutdown(func() { order = append(order, "hook") })
+	m.OnClose("first", func(context.Context) error {
+		order = append(order, "first")
+		return nil
+	})
+	m.OnClose("second", func(context.Context) error {
+		order = append(order, "second")
+		return errors.New("close failed")
+	})
+
+	stopped := make(chan struct{})
+	m.Go("worker", func(ctx context.Context) {
+		<-ctx.Done()
+		order = append(order, "worker")
+		close(stopped)
+	})
+
+	err := m.Shutdown(nil)
+	assert.EqualError(t, err, "close failed")
+	assert.Equal(t, []string{"hook", "worker", "second", "first"}, order)
+	<-stopped
+}
+
+func TestEvery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := 0
+	done := make(chan struct{})
+	go func() {
+		Every(ctx, time.Millisecond, func(context.Context) {
+			calls++
+			if calls == 3 {
+				cancel()
+			}
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		assert.Equal(t, 3, calls)
+	case <-time.After(time.Second):
+		t.Fatal("Every did not stop after cancellation")
+	}
+}
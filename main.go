@@ -1,19 +1,35 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"flag"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
 	swaggerfiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	"log"
+	"net/http"
 	"os"
+	"storage/audit"
+	"storage/config"
 	"storage/docs"
+	"storage/domain"
+	"storage/health"
+	"storage/invalidation"
+	"storage/leader"
+	"storage/lifecycle"
+	"storage/metrics"
+	"storage/migrations"
 	"storage/record"
+	"storage/tracing"
 	"storage/user"
+	"strconv"
 )
 
 func main() {
@@ -23,65 +39,141 @@ func main() {
 }
 
 func Run() error {
-	loadEnv()
-	postgresDB, err := initPostgresDB()
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	printConfig := fs.Bool("print-config", false, "print the effective config, with secrets redacted, and exit")
+	cfg, err := config.Load(fs, os.Args[1:])
 	if err != nil {
 		return err
 	}
+	if *printConfig {
+		return cfg.Print(os.Stdout)
+	}
+	if err = cfg.Validate(); err != nil {
+		return err
+	}
+	if args := fs.Args(); len(args) > 0 {
+		return runCommand(cfg, args)
+	}
+
+	lm := lifecycle.NewManager()
+
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.TracesExporter)
+	if err != nil {
+		return err
+	}
+	lm.OnClose("tracing", shutdownTracing)
+
+	postgresDB, err := initPostgresDB(cfg)
+	if err != nil {
+		return err
+	}
+	if err = postgresDB.Use(tracing.NewGormPlugin()); err != nil {
+		return err
+	}
+	sqlDB, err := postgresDB.DB()
+	if err != nil {
+		return err
+	}
+	lm.OnClose("database", func(context.Context) error { return sqlDB.Close() })
+
+	migrator, err := migrations.NewMigrator(sqlDB)
+	if err != nil {
+		return err
+	}
+	if cfg.AutoMigrate {
+		if _, err = migrator.Up(context.Background()); err != nil {
+			return err
+		}
+	}
+	if err = migrator.Check(context.Background()); err != nil {
+		return fmt.Errorf("refusing to serve: %w", err)
+	}
 
 	r := gin.Default()
-	environment := os.Getenv("ENVIRONMENT")
-	if environment == "prod" {
+	r.Use(otelgin.Middleware(tracing.ServiceName), metrics.Middleware())
+	if cfg.Environment == config.EnvironmentProd {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	basePath := "/api"
 	docs.SwaggerInfo.BasePath = basePath
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
+	r.GET("/metrics", metrics.Handler())
+
+	hService := health.NewHealthService()
+	hService.AddCheck("database", health.DatabaseCheck(postgresDB))
+	health.NewHealthController(r, hService)
+	lm.OnShutdown(hService.SetShuttingDown)
 
 	api := r.Group(basePath)
+	api.Use(audit.ClientIpMiddleware())
+
+	aRepo := audit.NewPostgresAuditRepository(postgresDB)
+	aService := audit.NewAuditService(aRepo)
 
 	uGroup := api.Group("user")
 	uRepo := user.NewPostgresUserRepository(postgresDB)
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	jwtTokenGenerator := user.NewJwtTokenGenerator(jwtSecret)
+	jwtTokenGenerator := user.NewJwtTokenGenerator(cfg.JwtSecret)
 
 	uService := user.NewUserService(uRepo, jwtTokenGenerator)
-	uHandler := user.NewUserController(uGroup, uService)
+	uHandler := user.NewUserController(uGroup, audit.NewAuditedUserService(metrics.NewInstrumentedUserService(uService), aService))
 
 	rGroup := api.Group("record")
 	rGroup.Use(uHandler.JwtAuthMiddleware())
 	rRepo := record.NewPostgresRecordRepository(postgresDB)
-	rService := record.NewRecordService(rRepo)
-	record.NewRecordController(rGroup, rService)
-
-	return r.Run()
-}
+	expirationLeader := leader.NewPostgresElector(sqlDB, "record-expiration", cfg.Cluster.LeaderElectionInterval)
+	lm.Go("record expiration election", expirationLeader.Run)
+	rConfig := cfg.Record()
+	rConfig.Leader = expirationLeader
+	rConfig.Invalidation = invalidationBus(lm, sqlDB, cfg.Cluster.InvalidationBus)
+	rService := record.NewRecordService(metrics.NewInstrumentedRecordRepository(rRepo), rConfig)
+	lm.OnClose("record service", func(context.Context) error { return rService.Close() })
+	if w, ok := rService.(record.StreamWaiter); ok {
+		// blocked stream reads would hold up the shutdown of the server
+		lm.OnShutdown(w.StopWaiting)
+	}
+	if p, ok := rService.(metrics.CacheStatsProvider); ok {
+		prometheus.MustRegister(metrics.NewCacheCollector(p))
+	}
+	if c, ok := rService.(health.CacheChecker); ok {
+		hService.AddCheck("cache", c.CheckCache)
+	}
+	if c, ok := rService.(health.WarmUpChecker); ok {
+		hService.AddCheck("cache warm-up", c.CheckWarmUp)
+	}
+	record.NewRecordController(rGroup, audit.NewAuditedRecordService(rService, aService))
 
-func initPostgresDB() (*gorm.DB, error) {
-	host := os.Getenv("POSTGRES_HOST")
-	port := os.Getenv("POSTGRES_PORT")
-	dbUser := os.Getenv("POSTGRES_USER")
-	password := os.Getenv("POSTGRES_PASSWORD")
-	database := os.Getenv("POSTGRES_DATABASE")
+	aGroup := api.Group("admin/audit")
+	aGroup.Use(uHandler.JwtAuthMiddleware(), uHandler.AdminMiddleware(cfg.AdminUserIds))
+	audit.NewAuditController(aGroup, aService)
 
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", host, port, dbUser, password, database)
+	hService.AddCheck("migrations", migrator.Check)
 
-	mode := os.Getenv("ENVIRONMENT")
+	return lm.Serve(&http.Server{
+		Addr:    ":" + strconv.Itoa(cfg.Port),
+		Handler: r,
+	})
+}
 
-	var config gorm.Config
-	if mode == "dev" {
-		config.Logger = logger.Default.LogMode(logger.Info)
+func initPostgresDB(cfg *config.Config) (*gorm.DB, error) {
+	var gormConfig gorm.Config
+	if cfg.Environment == config.EnvironmentDev {
+		gormConfig.Logger = logger.Default.LogMode(logger.Info)
 	}
 
-	return gorm.Open(postgres.Open(dsn), &config)
+	return gorm.Open(postgres.Open(cfg.Postgres.Dsn()), &gormConfig)
 }
 
-func loadEnv() {
-	env := os.Getenv("ENVIRONMENT")
-	println("env: ", env)
-	if env == "" {
-		_ = godotenv.Load()
+// invalidationBus creates the bus that keeps the record caches of all
+// replicas consistent.
+func invalidationBus(lm *lifecycle.Manager, db *sql.DB, bus string) domain.InvalidationBus {
+	switch bus {
+	case invalidation.BusNone:
+		return invalidation.NewNoopBus()
+	default:
+		b := invalidation.NewPostgresBus(db, invalidation.DefaultChannel)
+		lm.Go("cache invalidation", b.Run)
+		return b
 	}
 }
@@ -0,0 +1,63 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// CacheStats is a snapshot of the counters kept by a cache.
+type CacheStats struct {
+	Hits       int64
+	Misses     int64
+	DelHits    int64
+	DelMisses  int64
+	Collisions int64
+	Entries    int
+	Capacity   int
+}
+
+// CacheStatsProvider is implemented by services that own a cache.
+type CacheStatsProvider interface {
+	CacheStats() CacheStats
+}
+
+var (
+	cacheHitsDesc       = newCacheDesc("hits_total", "Number of cache hits.")
+	cacheMissesDesc     = newCacheDesc("misses_total", "Number of cache misses.")
+	cacheDelHitsDesc    = newCacheDesc("delete_hits_total", "Number of deletes of present keys.")
+	cacheDelMissesDesc  = newCacheDesc("delete_misses_total", "Number of deletes of missing keys.")
+	cacheCollisionsDesc = newCacheDesc("collisions_total", "Number of key hash collisions.")
+	cacheEntriesDesc    = newCacheDesc("entries", "Number of entries in the cache.")
+	cacheCapacityDesc   = newCacheDesc("capacity_bytes", "Bytes allocated by the cache.")
+)
+
+func newCacheDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", name), help, nil, nil)
+}
+
+type cacheCollector struct {
+	provider CacheStatsProvider
+}
+
+// NewCacheCollector exports the stats of p, read at scrape time.
+func NewCacheCollector(p CacheStatsProvider) prometheus.Collector {
+	return &cacheCollector{provider: p}
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheHitsDesc
+	ch <- cacheMissesDesc
+	ch <- cacheDelHitsDesc
+	ch <- cacheDelMissesDesc
+	ch <- cacheCollisionsDesc
+	ch <- cacheEntriesDesc
+	ch <- cacheCapacityDesc
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.provider.CacheStats()
+	ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(cacheDelHitsDesc, prometheus.CounterValue, float64(s.DelHits))
+	ch <- prometheus.MustNewConstMetric(cacheDelMissesDesc, prometheus.CounterValue, float64(s.DelMisses))
+	ch <- prometheus.MustNewConstMetric(cacheCollisionsDesc, prometheus.CounterValue, float64(s.Collisions))
+	ch <- prometheus.MustNewConstMetric(cacheEntriesDesc, prometheus.GaugeValue, float64(s.Entries))
+	ch <- prometheus.MustNewConstMetric(cacheCapacityDesc, prometheus.GaugeValue, float64(s.Capacity))
+}
@@ -0,0 +1,322 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"storage/domain"
+	"time"
+)
+
+type instrumentedRecordRepository struct {
+	domain.RecordRepository
+}
+
+// NewInstrumentedRecordRepository wraps repo so that the latency of every call is observed.
+func NewInstrumentedRecordRepository(repo domain.RecordRepository) domain.RecordRepository {
+	return &instrumentedRecordRepository{RecordRepository: repo}
+}
+
+func (i *instrumentedRecordRepository) Set(ctx context.Context, record *domain.Record) error {
+	defer observeQuery("Set", time.Now())
+	return i.RecordRepository.Set(ctx, record)
+}
+
+func (i *instrumentedRecordRepository) Get(ctx context.Context, key string) (*domain.Record, error) {
+	defer observeQuery("Get", time.Now())
+	return i.RecordRepository.Get(ctx, key)
+}
+
+func (i *instrumentedRecordRepository) Update(ctx context.Context, key string, fn func(*domain.Record) error) (*domain.Record, error) {
+	defer observeQuery("Update", time.Now())
+	return i.RecordRepository.Update(ctx, key, fn)
+}
+
+func (i *instrumentedRecordRepository) PatchJson(ctx context.Context, key string, patchType domain.PatchType, patch string) (*domain.Record, error) {
+	defer observeQuery("PatchJson", time.Now())
+	return i.RecordRepository.PatchJson(ctx, key, patchType, patch)
+}
+
+func (i *instrumentedRecordRepository) CreateIndex(ctx context.Context, index *domain.RecordIndex) error {
+	defer observeQuery("CreateIndex", time.Now())
+	return i.RecordRepository.CreateIndex(ctx, index)
+}
+
+func (i *instrumentedRecordRepository) DropIndex(ctx context.Context, name string) error {
+	defer observeQuery("DropIndex", time.Now())
+	return i.RecordRepository.DropIndex(ctx, name)
+}
+
+func (i *instrumentedRecordRepository) GetIndexes(ctx context.Context) ([]*domain.RecordIndex, error) {
+	defer observeQuery("GetIndexes", time.Now())
+	return i.RecordRepository.GetIndexes(ctx)
+}
+
+func (i *instrumentedRecordRepository) Query(ctx context.Context, query *domain.RecordQuery) ([]*domain.Record, error) {
+	defer observeQuery("Query", time.Now())
+	return i.RecordRepository.Query(ctx, query)
+}
+
+func (i *instrumentedRecordRepository) PutBlob(ctx context.Context, record *domain.Record, body io.Reader, maxSize int64) error {
+	defer observeQuery("PutBlob", time.Now())
+	return i.RecordRepository.PutBlob(ctx, record, body, maxSize)
+}
+
+func (i *instrumentedRecordRepository) GetChunk(ctx context.Context, blobId string, seq int) ([]byte, error) {
+	defer observeQuery("GetChunk", time.Now())
+	return i.RecordRepository.GetChunk(ctx, blobId, seq)
+}
+
+func (i *instrumentedRecordRepository) GetDataKeys(ctx context.Context) ([]*domain.DataKey, error) {
+	defer observeQuery("GetDataKeys", time.Now())
+	return i.RecordRepository.GetDataKeys(ctx)
+}
+
+func (i *instrumentedRecordRepository) AddDataKey(ctx context.Context, key *domain.DataKey) error {
+	defer observeQuery("AddDataKey", time.Now())
+	return i.RecordRepository.AddDataKey(ctx, key)
+}
+
+func (i *instrumentedRecordRepository) UpdateDataKey(ctx context.Context, key *domain.DataKey) error {
+	defer observeQuery("UpdateDataKey", time.Now())
+	return i.RecordRepository.UpdateDataKey(ctx, key)
+}
+
+func (i *instrumentedRecordRepository) ReEncrypt(ctx context.Context, keyId, limit int, fn func(*domain.Record) error) ([]string, int, error) {
+	defer observeQuery("ReEncrypt", time.Now())
+	return i.RecordRepository.ReEncrypt(ctx, keyId, limit, fn)
+}
+
+func (i *instrumentedRecordRepository) DeleteDataKeys(ctx context.Context, keepId int, supersededBefore time.Time) (int64, error) {
+	defer observeQuery("DeleteDataKeys", time.Now())
+	return i.RecordRepository.DeleteDataKeys(ctx, keepId, supersededBefore)
+}
+
+func (i *instrumentedRecordRepository) GetAll(ctx context.Context, filter *domain.MetadataFilter) []*domain.Record {
+	defer observeQuery("GetAll", time.Now())
+	return i.RecordRepository.GetAll(ctx, filter)
+}
+
+func (i *instrumentedRecordRepository) Delete(ctx context.Context, keys ...string) {
+	defer observeQuery("Delete", time.Now())
+	i.RecordRepository.Delete(ctx, keys...)
+}
+
+func (i *instrumentedRecordRepository) DeleteExpired(ctx context.Context, now time.Time, limit int) (int64, error) {
+	defer observeQuery("DeleteExpired", time.Now())
+	return i.RecordRepository.DeleteExpired(ctx, now, limit)
+}
+
+func (i *instrumentedRecordRepository) DeleteIfExpired(ctx context.Context, now time.Time, keys ...string) (int64, error) {
+	defer observeQuery("DeleteIfExpired", time.Now())
+	return i.RecordRepository.DeleteIfExpired(ctx, now, keys...)
+}
+
+func (i *instrumentedRecordRepository) SampleVolatile(ctx context.Context, limit int) ([]*domain.Record, error) {
+	defer observeQuery("SampleVolatile", time.Now())
+	return i.RecordRepository.SampleVolatile(ctx, limit)
+}
+
+func (i *instrumentedRecordRepository) TrackAccesses(ctx context.Context, accesses []*domain.RecordAccess) error {
+	defer observeQuery("TrackAccesses", time.Now())
+	return i.RecordRepository.TrackAccesses(ctx, accesses)
+}
+
+func (i *instrumentedRecordRepository) DeleteAccessesBefore(ctx context.Context, before time.Time) (int64, error) {
+	defer observeQuery("DeleteAccessesBefore", time.Now())
+	return i.RecordRepository.DeleteAccessesBefore(ctx, before)
+}
+
+func (i *instrumentedRecordRepository) GetHot(ctx context.Context, since time.Time, limit int) ([]*domain.Record, error) {
+	defer observeQuery("GetHot", time.Now())
+	return i.RecordRepository.GetHot(ctx, since, limit)
+}
+
+func (i *instrumentedRecordRepository) GetHistory(ctx context.Context, key string) ([]*domain.RecordVersion, error) {
+	defer observeQuery("GetHistory", time.Now())
+	return i.RecordRepository.GetHistory(ctx, key)
+}
+
+func (i *instrumentedRecordRepository) GetVersion(ctx context.Context, key string, version int) (*domain.RecordVersion, error) {
+	defer observeQuery("GetVersion", time.Now())
+	return i.RecordRepository.GetVersion(ctx, key, version)
+}
+
+func (i *instrumentedRecordRepository) GetVersionAt(ctx context.Context, key string, at time.Time) (*domain.RecordVersion, error) {
+	defer observeQuery("GetVersionAt", time.Now())
+	return i.RecordRepository.GetVersionAt(ctx, key, at)
+}
+
+func (i *instrumentedRecordRepository) HashSet(ctx context.Context, key string, fields map[string]string) (int, error) {
+	defer observeQuery("HashSet", time.Now())
+	return i.RecordRepository.HashSet(ctx, key, fields)
+}
+
+func (i *instrumentedRecordRepository) HashGet(ctx context.Context, key, field string) (string, error) {
+	defer observeQuery("HashGet", time.Now())
+	return i.RecordRepository.HashGet(ctx, key, field)
+}
+
+func (i *instrumentedRecordRepository) HashGetAll(ctx context.Context, key string) (map[string]string, error) {
+	defer observeQuery("HashGetAll", time.Now())
+	return i.RecordRepository.HashGetAll(ctx, key)
+}
+
+func (i *instrumentedRecordRepository) HashDelete(ctx context.Context, key string, fields ...string) (int, error) {
+	defer observeQuery("HashDelete", time.Now())
+	return i.RecordRepository.HashDelete(ctx, key, fields...)
+}
+
+func (i *instrumentedRecordRepository) ListPush(ctx context.Context, key string, end domain.ListEnd, values ...string) (int, error) {
+	defer observeQuery("ListPush", time.Now())
+	return i.RecordRepository.ListPush(ctx, key, end, values...)
+}
+
+func (i *instrumentedRecordRepository) ListPop(ctx context.Context, key string, end domain.ListEnd, count int) ([]string, error) {
+	defer observeQuery("ListPop", time.Now())
+	return i.RecordRepository.ListPop(ctx, key, end, count)
+}
+
+func (i *instrumentedRecordRepository) ListRange(ctx context.Context, key string, start, stop int) ([]string, error) {
+	defer observeQuery("ListRange", time.Now())
+	return i.RecordRepository.ListRange(ctx, key, start, stop)
+}
+
+func (i *instrumentedRecordRepository) ListLength(ctx context.Context, key string) (int, error) {
+	defer observeQuery("ListLength", time.Now())
+	return i.RecordRepository.ListLength(ctx, key)
+}
+
+func (i *instrumentedRecordRepository) SetAdd(ctx context.Context, key string, members ...string) (int, error) {
+	defer observeQuery("SetAdd", time.Now())
+	return i.RecordRepository.SetAdd(ctx, key, members...)
+}
+
+func (i *instrumentedRecordRepository) SetRemove(ctx context.Context, key string, members ...string) (int, error) {
+	defer observeQuery("SetRemove", time.Now())
+	return i.RecordRepository.SetRemove(ctx, key, members...)
+}
+
+func (i *instrumentedRecordRepository) SetMembers(ctx context.Context, key string) ([]string, error) {
+	defer observeQuery("SetMembers", time.Now())
+	return i.RecordRepository.SetMembers(ctx, key)
+}
+
+func (i *instrumentedRecordRepository) SetContains(ctx context.Context, key, member string) (bool, error) {
+	defer observeQuery("SetContains", time.Now())
+	return i.RecordRepository.SetContains(ctx, key, member)
+}
+
+func (i *instrumentedRecordRepository) ZSetAdd(ctx context.Context, key string, members ...domain.ScoredMember) (int, error) {
+	defer observeQuery("ZSetAdd", time.Now())
+	return i.RecordRepository.ZSetAdd(ctx, key, members...)
+}
+
+func (i *instrumentedRecordRepository) ZSetIncr(ctx context.Context, key, member string, by float64) (float64, error) {
+	defer observeQuery("ZSetIncr", time.Now())
+	return i.RecordRepository.ZSetIncr(ctx, key, member, by)
+}
+
+func (i *instrumentedRecordRepository) ZSetRank(ctx context.Context, key, member string, desc bool) (domain.ScoredMember, int, error) {
+	defer observeQuery("ZSetRank", time.Now())
+	return i.RecordRepository.ZSetRank(ctx, key, member, desc)
+}
+
+func (i *instrumentedRecordRepository) ZSetRange(ctx context.Context, key string, start, stop int, desc bool) ([]domain.ScoredMember, error) {
+	defer observeQuery("ZSetRange", time.Now())
+	return i.RecordRepository.ZSetRange(ctx, key, start, stop, desc)
+}
+
+func (i *instrumentedRecordRepository) ZSetRangeByScore(ctx context.Context, key string, r domain.ScoreRange) ([]domain.ScoredMember, error) {
+	defer observeQuery("ZSetRangeByScore", time.Now())
+	return i.RecordRepository.ZSetRangeByScore(ctx, key, r)
+}
+
+func (i *instrumentedRecordRepository) ZSetPop(ctx context.Context, key string, max bool, count int) ([]domain.ScoredMember, error) {
+	defer observeQuery("ZSetPop", time.Now())
+	return i.RecordRepository.ZSetPop(ctx, key, max, count)
+}
+
+func (i *instrumentedRecordRepository) ZSetRemove(ctx context.Context, key string, members ...string) (int, error) {
+	defer observeQuery("ZSetRemove", time.Now())
+	return i.RecordRepository.ZSetRemove(ctx, key, members...)
+}
+
+func (i *instrumentedRecordRepository) ZSetLength(ctx context.Context, key string) (int, error) {
+	defer observeQuery("ZSetLength", time.Now())
+	return i.RecordRepository.ZSetLength(ctx, key)
+}
+
+func (i *instrumentedRecordRepository) StreamAdd(ctx context.Context, key string, values ...string) ([]int64, error) {
+	defer observeQuery("StreamAdd", time.Now())
+	return i.RecordRepository.StreamAdd(ctx, key, values...)
+}
+
+func (i *instrumentedRecordRepository) StreamRead(ctx context.Context, key string, after int64, count int) ([]domain.StreamEntry, error) {
+	defer observeQuery("StreamRead", time.Now())
+	return i.RecordRepository.StreamRead(ctx, key, after, count)
+}
+
+func (i *instrumentedRecordRepository) StreamCreateGroup(ctx context.Context, key, group string, after int64) error {
+	defer observeQuery("StreamCreateGroup", time.Now())
+	return i.RecordRepository.StreamCreateGroup(ctx, key, group, after)
+}
+
+func (i *instrumentedRecordRepository) StreamDeleteGroup(ctx context.Context, key, group string) error {
+	defer observeQuery("StreamDeleteGroup", time.Now())
+	return i.RecordRepository.StreamDeleteGroup(ctx, key, group)
+}
+
+func (i *instrumentedRecordRepository) StreamReadGroup(ctx context.Context, key, group, consumer string, count int) ([]domain.StreamEntry, error) {
+	defer observeQuery("StreamReadGroup", time.Now())
+	return i.RecordRepository.StreamReadGroup(ctx, key, group, consumer, count)
+}
+
+func (i *instrumentedRecordRepository) StreamAck(ctx context.Context, key, group string, ids ...int64) (int, error) {
+	defer observeQuery("StreamAck", time.Now())
+	return i.RecordRepository.StreamAck(ctx, key, group, ids...)
+}
+
+func (i *instrumentedRecordRepository) StreamPending(ctx context.Context, key, group string, count int) ([]domain.PendingEntry, error) {
+	defer observeQuery("StreamPending", time.Now())
+	return i.RecordRepository.StreamPending(ctx, key, group, count)
+}
+
+func (i *instrumentedRecordRepository) StreamClaim(ctx context.Context, key, group, consumer string, minIdle time.Duration, count int) ([]domain.StreamEntry, error) {
+	defer observeQuery("StreamClaim", time.Now())
+	return i.RecordRepository.StreamClaim(ctx, key, group, consumer, minIdle, count)
+}
+
+func (i *instrumentedRecordRepository) StreamTrim(ctx context.Context, key string, maxLength int, before time.Time) (int, error) {
+	defer observeQuery("StreamTrim", time.Now())
+	return i.RecordRepository.StreamTrim(ctx, key, maxLength, before)
+}
+
+func (i *instrumentedRecordRepository) ExpireCollection(ctx context.Context, key string, ttl time.Duration) (*domain.Record, error) {
+	defer observeQuery("ExpireCollection", time.Now())
+	return i.RecordRepository.ExpireCollection(ctx, key, ttl)
+}
+
+func observeQuery(method string, start time.Time) {
+	RepositoryQueryDuration.WithLabelValues("record", method).Observe(time.Since(start).Seconds())
+}
+
+type instrumentedUserService struct {
+	domain.UserService
+}
+
+// NewInstrumentedUserService wraps us so that login attempts are counted by outcome.
+func NewInstrumentedUserService(us domain.UserService) domain.UserService {
+	return &instrumentedUserService{UserService: us}
+}
+
+func (i *instrumentedUserService) Login(ctx context.Context, req *domain.User) (string, error) {
+	token, err := i.UserService.Login(ctx, req)
+
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	LoginAttempts.WithLabelValues(outcome).Inc()
+
+	return token, err
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/api/record/:key", func(c *gin.Context) {
+		c.Status(http.StatusNotFound)
+	})
+
+	before := testutil.ToFloat64(HttpRequests.WithLabelValues("GET", "/api/record/:key", "404"))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/record/some-key", nil))
+
+	after := testutil.ToFloat64(HttpRequests.WithLabelValues("GET", "/api/record/:key", "404"))
+	assert.Equal(t, before+1, after)
+}
+
+type staticStats CacheStats
+
+func (s staticStats) CacheStats() CacheStats {
+	return CacheStats(s)
+}
+
+func TestCacheCollector(t *testing.T) {
+	c := NewCacheCollector(staticStats{Hits: 3, Misses: 1, Entries: 2})
+
+	expected := `
+# HELP storage_cache_hits_total Number of cache hits.
+# TYPE storage_cache_hits_total counter
+storage_cache_hits_total 3
+# HELP storage_cache_misses_total Number of cache misses.
+# TYPE storage_cache_misses_total counter
+storage_cache_misses_total 1
+# HELP storage_cache_entries Number of entries in the cache.
+# TYPE storage_cache_entries gauge
+storage_cache_entries 2
+`
+	err := testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"storage_cache_hits_total", "storage_cache_misses_total", "storage_cache_entries")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "storage"
+
+var (
+	HttpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of handled http requests.",
+	}, []string{"method", "route", "status"})
+
+	HttpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of handled http requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	RepositoryQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repository_query_duration_seconds",
+		Help:      "Latency of repository calls.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"repository", "method"})
+
+	CacheEvictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_evictions_total",
+		Help:      "Number of entries removed from the record cache, by reason.",
+	}, []string{"reason"})
+
+	CacheCoalescedRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_coalesced_requests_total",
+		Help:      "Number of cache misses that shared the repository call of a concurrent miss for the same key.",
+	})
+
+	CacheNegative = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_negative_total",
+		Help:      "Number of missing keys stored in and answered from the negative cache, by result.",
+	}, []string{"result"})
+
+	CacheStaleServed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_stale_served_total",
+		Help:      "Number of stale cache entries served while they were refreshed in the background.",
+	})
+
+	CacheBypassed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_bypassed_total",
+		Help:      "Number of records not cached because their value is too large.",
+	})
+
+	CacheWarmUpKeys = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cache_warmup_keys",
+		Help:      "Number of keys preloaded into the cache on startup.",
+	})
+
+	CacheInvalidations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_invalidations_total",
+		Help:      "Number of keys invalidated through the invalidation bus, by direction.",
+	}, []string{"direction"})
+
+	CompressionRatio = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "value_compression_ratio",
+		Help:      "Compressed size of record values relative to their size, by algorithm.",
+		Buckets:   []float64{.05, .1, .2, .3, .4, .5, .6, .7, .8, .9, 1},
+	}, []string{"algorithm"})
+
+	CompressionBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "value_compression_bytes_total",
+		Help:      "Size of the record values stored compressed before and after compression, by algorithm and stage.",
+	}, []string{"algorithm", "stage"})
+
+	DataKeyRotations = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "data_key_rotations_total",
+		Help:      "Number of data keys created to encrypt record values with.",
+	})
+
+	ReEncryptedValues = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reencrypted_values_total",
+		Help:      "Number of record values and versions encrypted again with the current data key.",
+	})
+
+	ExpirySweeps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "expiry_sweeps_total",
+		Help:      "Number of expiration cycles, by strategy.",
+	}, []string{"strategy"})
+
+	ExpirySweepDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "expiry_sweep_duration_seconds",
+		Help:      "Duration of expiration cycles, by strategy.",
+		Buckets:   prometheus.ExponentialBuckets(.005, 4, 8),
+	}, []string{"strategy"})
+
+	ExpiredKeysDeleted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "expired_keys_deleted_total",
+		Help:      "Number of expired records deleted, by strategy.",
+	}, []string{"strategy"})
+
+	ExpiryCycleReclaimed = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "expiry_cycle_reclaimed_keys",
+		Help:      "Number of expired records reclaimed by a single expiration cycle, by strategy.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+	}, []string{"strategy"})
+
+	ExpirySampledRatio = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "expiry_sampled_expired_ratio",
+		Help:      "Share of expired records in the last sample of the sampled expiration strategy.",
+	})
+
+	Leader = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "leader",
+		Help:      "Whether this instance holds the leadership of an election (1) or not (0).",
+	}, []string{"election"})
+
+	LeaderTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "leader_transitions_total",
+		Help:      "Number of times this instance gained or lost the leadership of an election.",
+	}, []string{"election", "state"})
+
+	LoginAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_attempts_total",
+		Help:      "Number of login attempts, by outcome.",
+	}, []string{"outcome"})
+)
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"storage/config"
+	"storage/migrations"
+	"strconv"
+)
+
+const migrateUsage = "usage: migrate [up | down [steps] | status | version]"
+
+// runCommand runs the command in args instead of serving.
+func runCommand(cfg *config.Config, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return migrate(cfg, args[1:])
+	default:
+		return fmt.Errorf("unknown command %s", args[0])
+	}
+}
+
+func migrate(cfg *config.Config, args []string) error {
+	postgresDB, err := initPostgresDB(cfg)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := postgresDB.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	m, err := migrations.NewMigrator(sqlDB)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "up":
+		n, err := m.Up(ctx)
+		fmt.Printf("applied %d migrations\n", n)
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid steps %q: %s", args[1], migrateUsage)
+			}
+		}
+		n, err := m.Down(ctx, steps)
+		fmt.Printf("reverted %d migrations\n", n)
+		return err
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%04d %-30s %s\n", s.Version, s.Name, state)
+		}
+		return nil
+	case "version":
+		version, err := m.Version(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("schema version %d, latest %d\n", version, m.Latest())
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate action %s: %s", action, migrateUsage)
+	}
+}
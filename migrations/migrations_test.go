@@ -0,0 +1,128 @@
+package migrations
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"testing/fstest"
+)
+
+func Test_load(t *testing.T) {
+	t.Run("embedded migrations", func(t *testing.T) {
+		migrations, err := load(files)
+		assert.NoError(t, err)
+		if assert.NotEmpty(t, migrations) {
+			assert.Equal(t, "create_users", migrations[0].Name)
+		}
+	})
+
+	t.Run("missing version", func(t *testing.T) {
+		_, err := load(fstest.MapFS{
+			"sql/0001_a.up.sql":   {Data: []byte("up")},
+			"sql/0001_a.down.sql": {Data: []byte("down")},
+			"sql/0003_c.up.sql":   {Data: []byte("up")},
+			"sql/0003_c.down.sql": {Data: []byte("down")},
+		})
+		assert.ErrorContains(t, err, "migration 2 is missing")
+	})
+
+	t.Run("missing down script", func(t *testing.T) {
+		_, err := load(fstest.MapFS{
+			"sql/0001_a.up.sql": {Data: []byte("up")},
+		})
+		assert.ErrorContains(t, err, "both an up and a down script")
+	})
+
+	t.Run("invalid name", func(t *testing.T) {
+		_, err := load(fstest.MapFS{
+			"sql/first.up.sql": {Data: []byte("up")},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func testMigrator(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	return &Migrator{db: db, migrations: []Migration{
+		{Version: 1, Name: "a", Up: "CREATE TABLE a ()", Down: "DROP TABLE a"},
+		{Version: 2, Name: "b", Up: "CREATE TABLE b ()", Down: "DROP TABLE b"},
+	}}, mock
+}
+
+func expectLocked(mock sqlmock.Sqlmock, version int) {
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(version\), 0\) FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(version))
+}
+
+func TestMigrator_Up(t *testing.T) {
+	m, mock := testMigrator(t)
+
+	expectLocked(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE b ()")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO schema_migrations`).WithArgs(2, "b").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	n, err := m.Up(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Up_failure(t *testing.T) {
+	m, mock := testMigrator(t)
+
+	expectLocked(mock, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE a ()")).WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	n, err := m.Up(context.TODO())
+	assert.ErrorContains(t, err, "migration 1 a")
+	assert.Equal(t, 0, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Down(t *testing.T) {
+	m, mock := testMigrator(t)
+
+	expectLocked(mock, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec(`DROP TABLE b`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM schema_migrations`).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	n, err := m.Down(context.TODO(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Check(t *testing.T) {
+	m, mock := testMigrator(t)
+	expectVersion := func(version int) {
+		mock.ExpectQuery(`SELECT to_regclass`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(`SELECT COALESCE`).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(version))
+	}
+
+	expectVersion(1)
+	assert.ErrorContains(t, m.Check(context.TODO()), "schema version 1 is behind 2")
+
+	expectVersion(2)
+	assert.NoError(t, m.Check(context.TODO()))
+
+	mock.ExpectQuery(`SELECT to_regclass`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	statuses, err := m.Status(context.TODO())
+	assert.NoError(t, err)
+	assert.False(t, statuses[0].Applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
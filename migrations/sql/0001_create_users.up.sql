@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS users (
+    id       bigserial PRIMARY KEY,
+    email    text UNIQUE,
+    password text
+);
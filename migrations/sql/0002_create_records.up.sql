@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS records (
+    key       text PRIMARY KEY,
+    value     text,
+    expire_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_records_expire_at ON records (expire_at);
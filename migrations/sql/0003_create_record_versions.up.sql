@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS record_versions (
+    id         bigserial PRIMARY KEY,
+    key        text,
+    version    bigint,
+    value      text,
+    changed_by bigint,
+    changed_at timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_record_versions_key_version ON record_versions (key, version);
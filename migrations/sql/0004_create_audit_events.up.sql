@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id         bigserial PRIMARY KEY,
+    user_id    bigint,
+    action     text,
+    key        text,
+    detail     text,
+    source_ip  text,
+    outcome    text,
+    created_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_user_id ON audit_events (user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action);
+CREATE INDEX IF NOT EXISTS idx_audit_events_key ON audit_events (key);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
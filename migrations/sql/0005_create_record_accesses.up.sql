@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS record_accesses (
+    key            text PRIMARY KEY,
+    hits           bigint,
+    last_access_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_record_accesses_last_access_at ON record_accesses (last_access_at);
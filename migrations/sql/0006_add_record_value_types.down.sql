@@ -0,0 +1,9 @@
+-- keep the values, as text, of records that are not plain strings
+UPDATE records SET value = value_json::text WHERE value_json IS NOT NULL;
+UPDATE records SET value = translate(encode(value_bytes, 'base64'), E'\n', '') WHERE value_bytes IS NOT NULL;
+
+ALTER TABLE records DROP COLUMN IF EXISTS value_bytes;
+ALTER TABLE records DROP COLUMN IF EXISTS value_json;
+ALTER TABLE records DROP COLUMN IF EXISTS type;
+
+ALTER TABLE record_versions DROP COLUMN IF EXISTS type;
@@ -0,0 +1,5 @@
+ALTER TABLE records ADD COLUMN IF NOT EXISTS type text NOT NULL DEFAULT 'string';
+ALTER TABLE records ADD COLUMN IF NOT EXISTS value_json jsonb;
+ALTER TABLE records ADD COLUMN IF NOT EXISTS value_bytes bytea;
+
+ALTER TABLE record_versions ADD COLUMN IF NOT EXISTS type text NOT NULL DEFAULT 'string';
@@ -0,0 +1,4 @@
+DROP FUNCTION IF EXISTS jsonb_patch(jsonb, jsonb);
+DROP FUNCTION IF EXISTS jsonb_patch_add(jsonb, text[], jsonb);
+DROP FUNCTION IF EXISTS jsonb_merge_patch(jsonb, jsonb);
+DROP FUNCTION IF EXISTS jsonb_pointer(text);
@@ -0,0 +1,121 @@
+-- jsonb_pointer splits a JSON Pointer (RFC 6901) into a jsonb path.
+CREATE OR REPLACE FUNCTION jsonb_pointer(pointer text) RETURNS text[] AS $$
+BEGIN
+    IF pointer = '' THEN
+        RETURN '{}';
+    END IF;
+    IF left(pointer, 1) <> '/' THEN
+        RAISE EXCEPTION 'invalid json pointer "%"', pointer;
+    END IF;
+    RETURN ARRAY(
+        SELECT replace(replace(token, '~1', '/'), '~0', '~')
+        FROM unnest(string_to_array(substr(pointer, 2), '/')) WITH ORDINALITY AS t(token, n)
+        ORDER BY n
+    );
+END
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- jsonb_merge_patch applies a JSON Merge Patch (RFC 7396).
+CREATE OR REPLACE FUNCTION jsonb_merge_patch(target jsonb, patch jsonb) RETURNS jsonb AS $$
+DECLARE
+    k text;
+    v jsonb;
+BEGIN
+    IF jsonb_typeof(patch) IS DISTINCT FROM 'object' THEN
+        RETURN patch;
+    END IF;
+    IF jsonb_typeof(target) IS DISTINCT FROM 'object' THEN
+        target := '{}';
+    END IF;
+    FOR k, v IN SELECT * FROM jsonb_each(patch) LOOP
+        IF jsonb_typeof(v) = 'null' THEN
+            target := target - k;
+        ELSE
+            target := jsonb_set(target, ARRAY[k], jsonb_merge_patch(target -> k, v));
+        END IF;
+    END LOOP;
+    RETURN target;
+END
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- jsonb_patch_add implements the add operation of JSON Patch: object
+-- members are added or replaced, array elements are inserted.
+CREATE OR REPLACE FUNCTION jsonb_patch_add(target jsonb, path text[], value jsonb) RETURNS jsonb AS $$
+DECLARE
+    parent jsonb;
+    idx    text;
+    n      int := cardinality(path);
+BEGIN
+    IF n = 0 THEN
+        RETURN value;
+    END IF;
+
+    parent := target #> path[1:n - 1];
+    idx := path[n];
+    CASE jsonb_typeof(parent)
+    WHEN 'object' THEN
+        RETURN jsonb_set(target, path, value, true);
+    WHEN 'array' THEN
+        IF idx = '-' THEN
+            idx := jsonb_array_length(parent)::text;
+        ELSIF idx !~ '^(0|[1-9][0-9]{0,8})$' THEN
+            RAISE EXCEPTION 'invalid array index "%"', idx;
+        ELSIF idx::int > jsonb_array_length(parent) THEN
+            RAISE EXCEPTION 'array index "%" out of range', idx;
+        END IF;
+        RETURN jsonb_insert(target, path[1:n - 1] || idx, value);
+    ELSE
+        RAISE EXCEPTION 'parent of "/%" does not exist', array_to_string(path, '/');
+    END CASE;
+END
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- jsonb_patch applies a JSON Patch (RFC 6902). A failing operation raises
+-- an exception, so either all operations are applied or none.
+CREATE OR REPLACE FUNCTION jsonb_patch(target jsonb, patch jsonb) RETURNS jsonb AS $$
+DECLARE
+    op        jsonb;
+    path      text[];
+    from_path text[];
+    v         jsonb;
+BEGIN
+    FOR op IN SELECT * FROM jsonb_array_elements(patch) LOOP
+        path := jsonb_pointer(op ->> 'path');
+        CASE op ->> 'op'
+        WHEN 'add' THEN
+            target := jsonb_patch_add(target, path, op -> 'value');
+        WHEN 'remove' THEN
+            IF cardinality(path) = 0 OR target #> path IS NULL THEN
+                RAISE EXCEPTION 'path "%" does not exist', op ->> 'path';
+            END IF;
+            target := target #- path;
+        WHEN 'replace' THEN
+            IF target #> path IS NULL THEN
+                RAISE EXCEPTION 'path "%" does not exist', op ->> 'path';
+            END IF;
+            IF cardinality(path) = 0 THEN
+                target := op -> 'value';
+            ELSE
+                target := jsonb_set(target, path, op -> 'value', false);
+            END IF;
+        WHEN 'move', 'copy' THEN
+            from_path := jsonb_pointer(op ->> 'from');
+            v := target #> from_path;
+            IF v IS NULL THEN
+                RAISE EXCEPTION 'path "%" does not exist', op ->> 'from';
+            END IF;
+            IF op ->> 'op' = 'move' THEN
+                target := target #- from_path;
+            END IF;
+            target := jsonb_patch_add(target, path, v);
+        WHEN 'test' THEN
+            IF target #> path IS DISTINCT FROM op -> 'value' THEN
+                RAISE EXCEPTION 'test of path "%" failed', op ->> 'path';
+            END IF;
+        ELSE
+            RAISE EXCEPTION 'unknown op "%"', op ->> 'op';
+        END CASE;
+    END LOOP;
+    RETURN target;
+END
+$$ LANGUAGE plpgsql IMMUTABLE;
@@ -0,0 +1,12 @@
+-- the expression indexes on records are created at runtime, one per definition
+DO $$
+DECLARE
+    index_name text;
+BEGIN
+    FOR index_name IN SELECT name FROM record_indexes LOOP
+        EXECUTE format('DROP INDEX IF EXISTS %I', 'idx_records_json_' || index_name);
+    END LOOP;
+END
+$$;
+
+DROP TABLE IF EXISTS record_indexes;
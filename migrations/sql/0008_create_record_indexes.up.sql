@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS record_indexes (
+    name       text PRIMARY KEY,
+    prefix     text NOT NULL,
+    path       text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_record_indexes_prefix_path ON record_indexes (prefix, path);
@@ -0,0 +1,13 @@
+DROP TRIGGER IF EXISTS records_replace_chunks ON records;
+DROP TRIGGER IF EXISTS records_delete_chunks ON records;
+DROP FUNCTION IF EXISTS delete_record_chunks();
+
+DROP TABLE IF EXISTS record_chunks;
+
+-- streamed values cannot be kept without their chunks
+DELETE FROM records WHERE blob_id IS NOT NULL;
+
+ALTER TABLE records DROP COLUMN IF EXISTS blob_chunk_size;
+ALTER TABLE records DROP COLUMN IF EXISTS blob_checksum;
+ALTER TABLE records DROP COLUMN IF EXISTS blob_size;
+ALTER TABLE records DROP COLUMN IF EXISTS blob_id;
@@ -0,0 +1,30 @@
+ALTER TABLE records ADD COLUMN IF NOT EXISTS blob_id text;
+ALTER TABLE records ADD COLUMN IF NOT EXISTS blob_size bigint NOT NULL DEFAULT 0;
+ALTER TABLE records ADD COLUMN IF NOT EXISTS blob_checksum text NOT NULL DEFAULT '';
+ALTER TABLE records ADD COLUMN IF NOT EXISTS blob_chunk_size int NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS record_chunks (
+    blob_id text  NOT NULL,
+    seq     int   NOT NULL,
+    data    bytea NOT NULL,
+    PRIMARY KEY (blob_id, seq)
+);
+
+-- the chunks of a value go away with the record, or once the record stops
+-- pointing to them because it was written again
+CREATE OR REPLACE FUNCTION delete_record_chunks() RETURNS trigger AS $$
+BEGIN
+    DELETE FROM record_chunks WHERE blob_id = OLD.blob_id;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS records_delete_chunks ON records;
+CREATE TRIGGER records_delete_chunks AFTER DELETE ON records
+    FOR EACH ROW WHEN (OLD.blob_id IS NOT NULL)
+    EXECUTE FUNCTION delete_record_chunks();
+
+DROP TRIGGER IF EXISTS records_replace_chunks ON records;
+CREATE TRIGGER records_replace_chunks AFTER UPDATE OF blob_id ON records
+    FOR EACH ROW WHEN (OLD.blob_id IS NOT NULL AND OLD.blob_id IS DISTINCT FROM NEW.blob_id)
+    EXECUTE FUNCTION delete_record_chunks();
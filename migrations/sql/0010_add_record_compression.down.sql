@@ -0,0 +1,14 @@
+-- compressed values cannot be decompressed in sql; they have to be written
+-- again without compression before reverting
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM records WHERE compression <> '') THEN
+        RAISE EXCEPTION 'records with compressed values exist';
+    END IF;
+END
+$$;
+
+DELETE FROM record_versions WHERE compression <> '';
+
+ALTER TABLE record_versions DROP COLUMN IF EXISTS compression;
+ALTER TABLE records DROP COLUMN IF EXISTS compression;
@@ -0,0 +1,3 @@
+-- compressed values of every type are stored in value_bytes
+ALTER TABLE records ADD COLUMN IF NOT EXISTS compression text NOT NULL DEFAULT '';
+ALTER TABLE record_versions ADD COLUMN IF NOT EXISTS compression text NOT NULL DEFAULT '';
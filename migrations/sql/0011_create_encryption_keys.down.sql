@@ -0,0 +1,16 @@
+-- encrypted values cannot be decrypted in sql; they have to be written
+-- again with encryption disabled before reverting
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM records WHERE key_id IS NOT NULL) THEN
+        RAISE EXCEPTION 'records with encrypted values exist';
+    END IF;
+END
+$$;
+
+DELETE FROM record_versions WHERE key_id IS NOT NULL;
+
+ALTER TABLE record_versions DROP COLUMN IF EXISTS key_id;
+ALTER TABLE records DROP COLUMN IF EXISTS key_id;
+
+DROP TABLE IF EXISTS encryption_keys;
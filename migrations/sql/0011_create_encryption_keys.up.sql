@@ -0,0 +1,16 @@
+-- data keys, stored wrapped by the master key master_key_id
+CREATE TABLE IF NOT EXISTS encryption_keys (
+    id            serial PRIMARY KEY,
+    wrapped_key   bytea NOT NULL,
+    master_key_id text NOT NULL,
+    created_at    timestamptz NOT NULL DEFAULT now()
+);
+
+-- encrypted values of every type are stored in value_bytes; the foreign
+-- keys keep a data key from being deleted while values still need it
+ALTER TABLE records ADD COLUMN IF NOT EXISTS key_id int REFERENCES encryption_keys (id);
+ALTER TABLE record_versions ADD COLUMN IF NOT EXISTS key_id int REFERENCES encryption_keys (id);
+
+-- finds the values left to re-encrypt after a key rotation
+CREATE INDEX IF NOT EXISTS idx_records_key_id ON records (key_id) WHERE key_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_record_versions_key_id ON record_versions (key_id) WHERE key_id IS NOT NULL;
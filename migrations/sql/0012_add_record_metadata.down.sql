@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_records_owner;
+DROP INDEX IF EXISTS idx_records_tags;
+
+ALTER TABLE records DROP COLUMN IF EXISTS last_accessed_at;
+ALTER TABLE records DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE records DROP COLUMN IF EXISTS created_at;
+ALTER TABLE records DROP COLUMN IF EXISTS content_type;
+ALTER TABLE records DROP COLUMN IF EXISTS tags;
+ALTER TABLE records DROP COLUMN IF EXISTS owner;
@@ -0,0 +1,11 @@
+-- existing records count as created when the columns are added
+ALTER TABLE records ADD COLUMN IF NOT EXISTS owner bigint NOT NULL DEFAULT 0;
+ALTER TABLE records ADD COLUMN IF NOT EXISTS tags jsonb NOT NULL DEFAULT '[]';
+ALTER TABLE records ADD COLUMN IF NOT EXISTS content_type text NOT NULL DEFAULT '';
+ALTER TABLE records ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL DEFAULT now();
+ALTER TABLE records ADD COLUMN IF NOT EXISTS updated_at timestamptz NOT NULL DEFAULT now();
+ALTER TABLE records ADD COLUMN IF NOT EXISTS last_accessed_at timestamptz;
+
+-- serves the tags @> filters of record lists
+CREATE INDEX IF NOT EXISTS idx_records_tags ON records USING gin (tags jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_records_owner ON records (owner) WHERE owner <> 0;
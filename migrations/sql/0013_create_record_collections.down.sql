@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS records_replace_elements ON records;
+DROP FUNCTION IF EXISTS delete_record_elements();
+
+DROP TABLE IF EXISTS record_set_members;
+DROP TABLE IF EXISTS record_list_items;
+DROP TABLE IF EXISTS record_hash_fields;
+
+-- collections cannot be kept without their elements
+DELETE FROM records WHERE type IN ('hash', 'list', 'set');
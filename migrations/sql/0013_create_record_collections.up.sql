@@ -0,0 +1,38 @@
+-- the elements of hash, list and set records; the record row carries the
+-- type, ttl and metadata and its lock serializes the writes of a key
+CREATE TABLE IF NOT EXISTS record_hash_fields (
+    key   text NOT NULL REFERENCES records (key) ON DELETE CASCADE,
+    field text NOT NULL,
+    value text NOT NULL,
+    PRIMARY KEY (key, field)
+);
+
+-- positions grow to the right and shrink to the left, so pushes to either
+-- end never move the other items
+CREATE TABLE IF NOT EXISTS record_list_items (
+    key   text   NOT NULL REFERENCES records (key) ON DELETE CASCADE,
+    pos   bigint NOT NULL,
+    value text   NOT NULL,
+    PRIMARY KEY (key, pos)
+);
+
+CREATE TABLE IF NOT EXISTS record_set_members (
+    key    text NOT NULL REFERENCES records (key) ON DELETE CASCADE,
+    member text NOT NULL,
+    PRIMARY KEY (key, member)
+);
+
+-- the elements of a collection go away once a plain value is written to its key
+CREATE OR REPLACE FUNCTION delete_record_elements() RETURNS trigger AS $$
+BEGIN
+    DELETE FROM record_hash_fields WHERE key = OLD.key;
+    DELETE FROM record_list_items WHERE key = OLD.key;
+    DELETE FROM record_set_members WHERE key = OLD.key;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS records_replace_elements ON records;
+CREATE TRIGGER records_replace_elements AFTER UPDATE OF type ON records
+    FOR EACH ROW WHEN (OLD.type IN ('hash', 'list', 'set') AND OLD.type IS DISTINCT FROM NEW.type)
+    EXECUTE FUNCTION delete_record_elements();
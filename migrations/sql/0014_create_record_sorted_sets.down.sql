@@ -0,0 +1,19 @@
+DROP TRIGGER IF EXISTS records_replace_elements ON records;
+
+CREATE OR REPLACE FUNCTION delete_record_elements() RETURNS trigger AS $$
+BEGIN
+    DELETE FROM record_hash_fields WHERE key = OLD.key;
+    DELETE FROM record_list_items WHERE key = OLD.key;
+    DELETE FROM record_set_members WHERE key = OLD.key;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER records_replace_elements AFTER UPDATE OF type ON records
+    FOR EACH ROW WHEN (OLD.type IN ('hash', 'list', 'set') AND OLD.type IS DISTINCT FROM NEW.type)
+    EXECUTE FUNCTION delete_record_elements();
+
+DROP TABLE IF EXISTS record_sorted_set_members;
+
+-- sorted sets cannot be kept without their members
+DELETE FROM records WHERE type = 'zset';
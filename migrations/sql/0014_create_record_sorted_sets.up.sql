@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS record_sorted_set_members (
+    key    text             NOT NULL REFERENCES records (key) ON DELETE CASCADE,
+    member text             NOT NULL,
+    score  double precision NOT NULL,
+    PRIMARY KEY (key, member)
+);
+
+-- ranges and ranks walk the members of a key in score order, ties broken
+-- by member
+CREATE INDEX IF NOT EXISTS idx_record_sorted_set_members_score
+    ON record_sorted_set_members (key, score, member);
+
+CREATE OR REPLACE FUNCTION delete_record_elements() RETURNS trigger AS $$
+BEGIN
+    DELETE FROM record_hash_fields WHERE key = OLD.key;
+    DELETE FROM record_list_items WHERE key = OLD.key;
+    DELETE FROM record_set_members WHERE key = OLD.key;
+    DELETE FROM record_sorted_set_members WHERE key = OLD.key;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS records_replace_elements ON records;
+CREATE TRIGGER records_replace_elements AFTER UPDATE OF type ON records
+    FOR EACH ROW WHEN (OLD.type IN ('hash', 'list', 'set', 'zset') AND OLD.type IS DISTINCT FROM NEW.type)
+    EXECUTE FUNCTION delete_record_elements();
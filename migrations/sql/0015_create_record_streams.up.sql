@@ -0,0 +1,59 @@
+-- the last id handed out by a stream, kept when its entries are trimmed so
+-- that ids are never reused; the row lives as long as the stream
+CREATE TABLE IF NOT EXISTS record_streams (
+    key     text   PRIMARY KEY REFERENCES records (key) ON DELETE CASCADE,
+    last_id bigint NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS record_stream_entries (
+    key        text        NOT NULL REFERENCES records (key) ON DELETE CASCADE,
+    id         bigint      NOT NULL,
+    value      text        NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (key, id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_record_stream_entries_created_at
+    ON record_stream_entries (key, created_at);
+
+CREATE TABLE IF NOT EXISTS record_stream_groups (
+    key               text   NOT NULL REFERENCES records (key) ON DELETE CASCADE,
+    name              text   NOT NULL,
+    last_delivered_id bigint NOT NULL,
+    PRIMARY KEY (key, name)
+);
+
+-- entries delivered to a consumer and not acknowledged yet; trimming an
+-- entry drops it from the pending lists too
+CREATE TABLE IF NOT EXISTS record_stream_pending (
+    key          text        NOT NULL,
+    group_name   text        NOT NULL,
+    entry_id     bigint      NOT NULL,
+    consumer     text        NOT NULL,
+    delivered_at timestamptz NOT NULL,
+    deliveries   integer     NOT NULL DEFAULT 1,
+    PRIMARY KEY (key, group_name, entry_id),
+    FOREIGN KEY (key, group_name) REFERENCES record_stream_groups (key, name) ON DELETE CASCADE,
+    FOREIGN KEY (key, entry_id) REFERENCES record_stream_entries (key, id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_record_stream_pending_delivered_at
+    ON record_stream_pending (key, group_name, delivered_at);
+
+CREATE OR REPLACE FUNCTION delete_record_elements() RETURNS trigger AS $$
+BEGIN
+    DELETE FROM record_hash_fields WHERE key = OLD.key;
+    DELETE FROM record_list_items WHERE key = OLD.key;
+    DELETE FROM record_set_members WHERE key = OLD.key;
+    DELETE FROM record_sorted_set_members WHERE key = OLD.key;
+    DELETE FROM record_stream_groups WHERE key = OLD.key;
+    DELETE FROM record_stream_entries WHERE key = OLD.key;
+    DELETE FROM record_streams WHERE key = OLD.key;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS records_replace_elements ON records;
+CREATE TRIGGER records_replace_elements AFTER UPDATE OF type ON records
+    FOR EACH ROW WHEN (OLD.type IN ('hash', 'list', 'set', 'zset', 'stream') AND OLD.type IS DISTINCT FROM NEW.type)
+    EXECUTE FUNCTION delete_record_elements();
@@ -0,0 +1,103 @@
+package record
+
+import (
+	"context"
+	"errors"
+	"log"
+	"storage/domain"
+	"storage/metrics"
+	"sync"
+	"time"
+)
+
+// accessLog counts reads per key between two flushes to the repository.
+type accessLog struct {
+	mu   sync.Mutex
+	hits map[string]int64
+	max  int
+}
+
+func newAccessLog(max int) *accessLog {
+	return &accessLog{hits: map[string]int64{}, max: max}
+}
+
+// track counts a read of key. Once max keys were counted, reads of other
+// keys are dropped until the next drain.
+func (l *accessLog) track(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.hits[key]; ok || len(l.hits) < l.max {
+		l.hits[key]++
+	}
+}
+
+// drain returns the counted reads and starts counting from zero.
+func (l *accessLog) drain(now time.Time) []*domain.RecordAccess {
+	l.mu.Lock()
+	hits := l.hits
+	l.hits = map[string]int64{}
+	l.mu.Unlock()
+
+	accesses := make([]*domain.RecordAccess, 0, len(hits))
+	for key, n := range hits {
+		accesses = append(accesses, &domain.RecordAccess{Key: key, Hits: n, LastAccessAt: now})
+	}
+	return accesses
+}
+
+func (s *service) flushAccesses(ctx context.Context) {
+	accesses := s.accesses.drain(time.Now())
+	if len(accesses) == 0 {
+		return
+	}
+	if err := s.repo.TrackAccesses(ctx, accesses); err != nil {
+		log.Printf("access log: %v\n", err)
+	}
+}
+
+// maintainAccessLog flushes the counted reads and, on the leader, forgets
+// keys that were not read for AccessLogRetention.
+func (s *service) maintainAccessLog(ctx context.Context) {
+	s.flushAccesses(ctx)
+
+	if s.config.Leader != nil && !s.config.Leader.IsLeader() {
+		return
+	}
+	if _, err := s.repo.DeleteAccessesBefore(ctx, time.Now().Add(-s.config.AccessLogRetention)); err != nil {
+		log.Printf("access log: %v\n", err)
+	}
+}
+
+// warmUp preloads the most read keys into the cache.
+func (s *service) warmUp(ctx context.Context) {
+	defer s.warm.Store(true)
+
+	ctx, cancel := context.WithTimeout(ctx, s.config.WarmUpTimeout)
+	defer cancel()
+
+	start := time.Now()
+	gens := s.gens.all()
+	records, err := s.repo.GetHot(ctx, start.Add(-s.config.AccessLogRetention), s.config.WarmUpKeys)
+	if err != nil {
+		log.Printf("cache warm-up: %v\n", err)
+		return
+	}
+	for _, r := range records {
+		// keys written since they were read are left to the next read
+		r := r
+		s.gens.ifCurrent(r.Key, gens[generationStripeOf(r.Key)], func() { s.cacheSet(ctx, r.Key, r) })
+	}
+
+	metrics.CacheWarmUpKeys.Set(float64(len(records)))
+	log.Printf("cache warm-up: loaded %d keys in %s\n", len(records), time.Since(start))
+}
+
+// CheckWarmUp fails until the cache warm-up is over, keeping the service
+// out of rotation while its cache is cold.
+func (s *service) CheckWarmUp(context.Context) error {
+	if !s.warm.Load() {
+		return errors.New("cache warm-up in progress")
+	}
+	return nil
+}
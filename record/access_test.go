@@ -0,0 +1,107 @@
+package record
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"storage/cache"
+	"storage/domain"
+	"storage/domain/mocks"
+	"testing"
+	"time"
+)
+
+func Test_accessLog(t *testing.T) {
+	l := newAccessLog(2)
+	l.track("a")
+	l.track("b")
+	l.track("a")
+	// the log is full, new keys are dropped
+	l.track("c")
+
+	now := time.Now()
+	accesses := l.drain(now)
+	assert.ElementsMatch(t, []*domain.RecordAccess{
+		{Key: "a", Hits: 2, LastAccessAt: now},
+		{Key: "b", Hits: 1, LastAccessAt: now},
+	}, accesses)
+	assert.Empty(t, l.drain(now))
+}
+
+func Test_service_warmUp(t *testing.T) {
+	records := []*domain.Record{
+		{Key: "a", Value: "1"},
+		{Key: "b", Value: "2"},
+	}
+	repo := new(mocks.MockRecordRepository)
+	repo.On("GetHot", mock.Anything, mock.Anything, 2).Return(records, nil).Once()
+
+	config := DefaultConfig()
+	config.WarmUpKeys = 2
+	s := &service{repo: repo, config: config, cache: cache.NewLRUCache(cache.Config{})}
+
+	assert.Error(t, s.CheckWarmUp(context.TODO()))
+	s.warmUp(context.TODO())
+	assert.NoError(t, s.CheckWarmUp(context.TODO()))
+
+	for _, r := range records {
+		entry := s.cacheGet(context.TODO(), r.Key)
+		if assert.NotNil(t, entry) {
+			assert.Equal(t, r, entry.toRecord())
+		}
+	}
+	repo.AssertExpectations(t)
+}
+
+func Test_service_warmUp_writeDuringLoad(t *testing.T) {
+	records := []*domain.Record{
+		{Key: "a", Value: "1"},
+		{Key: "b", Value: "2"},
+	}
+	repo := new(mocks.MockRecordRepository)
+
+	config := DefaultConfig()
+	config.WarmUpKeys = 2
+	s := &service{repo: repo, config: config, cache: cache.NewLRUCache(cache.Config{})}
+	// b is written after the warm-up read it
+	repo.On("GetHot", mock.Anything, mock.Anything, 2).
+		Run(func(mock.Arguments) { s.cacheDelete("b") }).
+		Return(records, nil).Once()
+
+	s.warmUp(context.TODO())
+
+	assert.NotNil(t, s.cacheGet(context.TODO(), "a"))
+	assert.Nil(t, s.cacheGet(context.TODO(), "b"))
+	repo.AssertExpectations(t)
+}
+
+func Test_service_maintainAccessLog(t *testing.T) {
+	t.Run("leader prunes the log", func(t *testing.T) {
+		repo := new(mocks.MockRecordRepository)
+		repo.
+			On("TrackAccesses", mock.Anything, mock.Anything).Return(nil).Once().
+			On("DeleteAccessesBefore", mock.Anything, mock.Anything).Return(int64(3), nil).Once()
+
+		s := &service{repo: repo, config: DefaultConfig(), accesses: newAccessLog(10)}
+		s.accesses.track("a")
+		s.maintainAccessLog(context.TODO())
+
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("followers only flush", func(t *testing.T) {
+		leader := new(mocks.MockLeader)
+		leader.On("IsLeader").Return(false)
+		repo := new(mocks.MockRecordRepository)
+		repo.On("TrackAccesses", mock.Anything, mock.Anything).Return(nil).Once()
+
+		config := DefaultConfig()
+		config.Leader = leader
+		s := &service{repo: repo, config: config, accesses: newAccessLog(10)}
+		s.accesses.track("a")
+		s.maintainAccessLog(context.TODO())
+
+		repo.AssertExpectations(t)
+		repo.AssertNotCalled(t, "DeleteAccessesBefore", mock.Anything, mock.Anything)
+	})
+}
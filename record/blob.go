@@ -0,0 +1,98 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"storage/domain"
+)
+
+// PutBlob streams body into the repository, which stores it in chunks, so
+// that large values are never held in memory as a whole. Chunks are not
+// encrypted, so keys whose values are cannot be streamed to.
+func (s *service) PutBlob(ctx context.Context, record *domain.Record, body io.Reader) (err error) {
+	ctx, span := tracer.Start(ctx, "record.PutBlob", keyAttribute(record.Key))
+	defer func() { endSpan(span, err) }()
+
+	if s.encrypts(record.Key) {
+		return domain.ErrEncrypted
+	}
+
+	if err = s.repo.PutBlob(ctx, record, body, s.config.MaxValueSize); err != nil {
+		return err
+	}
+
+	s.loads.Forget(record.Key)
+	s.cacheDelete(record.Key)
+	s.publishInvalidation(ctx, record.Key)
+	return nil
+}
+
+func (s *service) OpenBlob(ctx context.Context, key string) (*domain.Record, io.ReadSeeker, error) {
+	record, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if record.Blob == nil {
+		b, err := record.Bytes()
+		if err != nil {
+			return nil, nil, err
+		}
+		return record, bytes.NewReader(b), nil
+	}
+
+	return record, &blobReader{ctx: ctx, repo: s.repo, blob: record.Blob, seq: -1}, nil
+}
+
+// blobReader reads a chunked value, fetching the chunk under the offset
+// when it is not the one last fetched.
+type blobReader struct {
+	ctx   context.Context
+	repo  domain.RecordRepository
+	blob  *domain.Blob
+	off   int64
+	seq   int
+	chunk []byte
+}
+
+func (b *blobReader) Read(p []byte) (int, error) {
+	if b.off >= b.blob.Size {
+		return 0, io.EOF
+	}
+
+	chunkSize := int64(b.blob.ChunkSize)
+	seq := int(b.off / chunkSize)
+	if seq != b.seq {
+		chunk, err := b.repo.GetChunk(b.ctx, b.blob.Id, seq)
+		if err != nil {
+			return 0, err
+		}
+		b.seq, b.chunk = seq, chunk
+	}
+
+	start := b.off - int64(seq)*chunkSize
+	if start >= int64(len(b.chunk)) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(p, b.chunk[start:])
+	b.off += int64(n)
+	return n, nil
+}
+
+func (b *blobReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += b.off
+	case io.SeekEnd:
+		offset += b.blob.Size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	b.off = offset
+	return offset, nil
+}
@@ -0,0 +1,120 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
+	"storage/domain"
+	"storage/domain/mocks"
+	"testing"
+)
+
+func Test_service_PutBlob(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+	body := bytes.NewReader([]byte("data"))
+	repo.On("PutBlob", mock.Anything, mock.Anything, body, DefaultConfig().MaxValueSize).Return(nil).Once()
+
+	s := NewRecordService(repo, DefaultConfig()).(*service)
+	defer s.Close()
+	s.cache.Set("blob", []byte(`{"Key":"blob","Value":"old"}`))
+
+	err := s.PutBlob(context.TODO(), &domain.Record{Key: "blob"}, body)
+	assert.NoError(t, err)
+	_, err = s.cache.Get("blob")
+	assert.Error(t, err, "the old value must leave the cache")
+	repo.AssertExpectations(t)
+}
+
+func Test_service_OpenBlob(t *testing.T) {
+	t.Run("chunked", func(t *testing.T) {
+		repo := new(mocks.MockRecordRepository)
+		repo.On("TrackAccesses", mock.Anything, mock.Anything).Return(nil).Maybe()
+		blob := &domain.Blob{Id: "b1", Size: 10, ChunkSize: 4}
+		repo.On("Get", mock.Anything, "big").Return(&domain.Record{Key: "big", Type: domain.TypeBytes, Blob: blob}, nil).Twice()
+		repo.On("GetChunk", mock.Anything, "b1", 0).Return([]byte("0123"), nil).Once()
+		repo.On("GetChunk", mock.Anything, "b1", 1).Return([]byte("4567"), nil).Twice()
+		repo.On("GetChunk", mock.Anything, "b1", 2).Return([]byte("89"), nil).Twice()
+
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
+		r, body, err := s.OpenBlob(context.TODO(), "big")
+		assert.NoError(t, err)
+		assert.Equal(t, blob, r.Blob)
+
+		b, err := io.ReadAll(body)
+		assert.NoError(t, err)
+		assert.Equal(t, "0123456789", string(b))
+
+		_, err = body.Seek(-3, io.SeekEnd)
+		assert.NoError(t, err)
+		b, err = io.ReadAll(body)
+		assert.NoError(t, err)
+		assert.Equal(t, "789", string(b))
+
+		// chunked records are never cached
+		_, _, err = s.OpenBlob(context.TODO(), "big")
+		assert.NoError(t, err)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("inline", func(t *testing.T) {
+		repo := new(mocks.MockRecordRepository)
+		repo.On("TrackAccesses", mock.Anything, mock.Anything).Return(nil).Maybe()
+		repo.On("Get", mock.Anything, "k").Return(&domain.Record{Key: "k", Value: "hello", Type: domain.TypeString}, nil).Once()
+
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
+		_, body, err := s.OpenBlob(context.TODO(), "k")
+		assert.NoError(t, err)
+		b, err := io.ReadAll(body)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(b))
+	})
+
+	t.Run("replaced while read", func(t *testing.T) {
+		repo := new(mocks.MockRecordRepository)
+		repo.On("TrackAccesses", mock.Anything, mock.Anything).Return(nil).Maybe()
+		blob := &domain.Blob{Id: "b1", Size: 10, ChunkSize: 4}
+		repo.On("Get", mock.Anything, "big").Return(&domain.Record{Key: "big", Type: domain.TypeBytes, Blob: blob}, nil).Once()
+		repo.On("GetChunk", mock.Anything, "b1", 0).Return(nil, domain.ErrBlobReplaced).Once()
+
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
+		_, body, err := s.OpenBlob(context.TODO(), "big")
+		assert.NoError(t, err)
+		_, err = io.ReadAll(body)
+		assert.ErrorIs(t, err, domain.ErrBlobReplaced)
+	})
+}
+
+func Test_service_cacheMaxValueSize(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+	repo.On("TrackAccesses", mock.Anything, mock.Anything).Return(nil).Maybe()
+	repo.On("Get", mock.Anything, "k").Return(&domain.Record{Key: "k", Value: "too large", Type: domain.TypeString}, nil).Twice()
+
+	config := DefaultConfig()
+	config.CacheMaxValueSize = 4
+	s := NewRecordService(repo, config)
+	defer s.Close()
+
+	for i := 0; i < 2; i++ {
+		_, err := s.Get(context.TODO(), "k")
+		assert.NoError(t, err)
+	}
+	repo.AssertExpectations(t)
+}
+
+func Test_service_Set_tooLarge(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+
+	config := DefaultConfig()
+	config.MaxValueSize = 4
+	s := NewRecordService(repo, config)
+	defer s.Close()
+
+	err := s.Set(context.TODO(), &domain.Record{Key: "k", Value: "too large"})
+	assert.ErrorIs(t, err, domain.ErrValueTooLarge)
+	repo.AssertNotCalled(t, "Set", mock.Anything, mock.Anything)
+}
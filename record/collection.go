@@ -0,0 +1,251 @@
+package record
+
+import (
+	"context"
+	"errors"
+	"math"
+	"storage/domain"
+)
+
+// checkElements rejects collection writes the repository cannot take.
+// Elements are stored as they are, so keys whose values are encrypted
+// cannot hold collections.
+func (s *service) checkElements(key string, elements []string) error {
+	if len(elements) == 0 {
+		return errors.New("no elements given")
+	}
+	if s.encrypts(key) {
+		return domain.ErrEncrypted
+	}
+	for _, e := range elements {
+		if int64(len(e)) > s.config.MaxValueSize {
+			return domain.ErrValueTooLarge
+		}
+	}
+	return nil
+}
+
+var errScoreNotFinite = errors.New("scores must be finite numbers")
+
+func finite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// collectionChanged drops the cached record of key, which writes to its
+// elements may have created or deleted.
+func (s *service) collectionChanged(ctx context.Context, key string) {
+	s.loads.Forget(key)
+	s.cacheDelete(key)
+	s.publishInvalidation(ctx, key)
+}
+
+func (s *service) HashSet(ctx context.Context, key string, fields map[string]string) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "record.HashSet", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
+
+	elements := make([]string, 0, 2*len(fields))
+	for f, v := range fields {
+		elements = append(elements, f, v)
+	}
+	if err = s.checkElements(key, elements); err != nil {
+		return 0, err
+	}
+
+	added, err := s.repo.HashSet(ctx, key, fields)
+	if err != nil {
+		return 0, err
+	}
+	s.collectionChanged(ctx, key)
+	return added, nil
+}
+
+func (s *service) HashGet(ctx context.Context, key, field string) (string, error) {
+	return s.repo.HashGet(ctx, key, field)
+}
+
+func (s *service) HashGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return s.repo.HashGetAll(ctx, key)
+}
+
+func (s *service) HashDelete(ctx context.Context, key string, fields ...string) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "record.HashDelete", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
+
+	if len(fields) == 0 {
+		return 0, errors.New("no fields given")
+	}
+	deleted, err := s.repo.HashDelete(ctx, key, fields...)
+	if err != nil {
+		return 0, err
+	}
+	s.collectionChanged(ctx, key)
+	return deleted, nil
+}
+
+func (s *service) ListPush(ctx context.Context, key string, end domain.ListEnd, values ...string) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "record.ListPush", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
+
+	if err = s.checkElements(key, values); err != nil {
+		return 0, err
+	}
+	length, err := s.repo.ListPush(ctx, key, end, values...)
+	if err != nil {
+		return 0, err
+	}
+	s.collectionChanged(ctx, key)
+	return length, nil
+}
+
+func (s *service) ListPop(ctx context.Context, key string, end domain.ListEnd, count int) (_ []string, err error) {
+	ctx, span := tracer.Start(ctx, "record.ListPop", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
+
+	if count < 1 {
+		return nil, errors.New("count must be positive")
+	}
+	values, err := s.repo.ListPop(ctx, key, end, count)
+	if err != nil {
+		return nil, err
+	}
+	s.collectionChanged(ctx, key)
+	return values, nil
+}
+
+func (s *service) ListRange(ctx context.Context, key string, start, stop int) ([]string, error) {
+	return s.repo.ListRange(ctx, key, start, stop)
+}
+
+func (s *service) ListLength(ctx context.Context, key string) (int, error) {
+	return s.repo.ListLength(ctx, key)
+}
+
+func (s *service) SetAdd(ctx context.Context, key string, members ...string) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "record.SetAdd", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
+
+	if err = s.checkElements(key, members); err != nil {
+		return 0, err
+	}
+	added, err := s.repo.SetAdd(ctx, key, members...)
+	if err != nil {
+		return 0, err
+	}
+	s.collectionChanged(ctx, key)
+	return added, nil
+}
+
+func (s *service) SetRemove(ctx context.Context, key string, members ...string) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "record.SetRemove", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
+
+	if len(members) == 0 {
+		return 0, errors.New("no members given")
+	}
+	removed, err := s.repo.SetRemove(ctx, key, members...)
+	if err != nil {
+		return 0, err
+	}
+	s.collectionChanged(ctx, key)
+	return removed, nil
+}
+
+func (s *service) SetMembers(ctx context.Context, key string) ([]string, error) {
+	return s.repo.SetMembers(ctx, key)
+}
+
+func (s *service) SetContains(ctx context.Context, key, member string) (bool, error) {
+	return s.repo.SetContains(ctx, key, member)
+}
+
+func (s *service) ZSetAdd(ctx context.Context, key string, members ...domain.ScoredMember) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "record.ZSetAdd", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
+
+	names := make([]string, len(members))
+	for i, m := range members {
+		if !finite(m.Score) {
+			return 0, errScoreNotFinite
+		}
+		names[i] = m.Member
+	}
+	if err = s.checkElements(key, names); err != nil {
+		return 0, err
+	}
+	added, err := s.repo.ZSetAdd(ctx, key, members...)
+	if err != nil {
+		return 0, err
+	}
+	s.collectionChanged(ctx, key)
+	return added, nil
+}
+
+func (s *service) ZSetIncr(ctx context.Context, key, member string, by float64) (_ float64, err error) {
+	ctx, span := tracer.Start(ctx, "record.ZSetIncr", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
+
+	if !finite(by) {
+		return 0, errScoreNotFinite
+	}
+	if err = s.checkElements(key, []string{member}); err != nil {
+		return 0, err
+	}
+	score, err := s.repo.ZSetIncr(ctx, key, member, by)
+	if err != nil {
+		return 0, err
+	}
+	s.collectionChanged(ctx, key)
+	return score, nil
+}
+
+func (s *service) ZSetRank(ctx context.Context, key, member string, desc bool) (domain.ScoredMember, int, error) {
+	return s.repo.ZSetRank(ctx, key, member, desc)
+}
+
+func (s *service) ZSetRange(ctx context.Context, key string, start, stop int, desc bool) ([]domain.ScoredMember, error) {
+	return s.repo.ZSetRange(ctx, key, start, stop, desc)
+}
+
+func (s *service) ZSetRangeByScore(ctx context.Context, key string, r domain.ScoreRange) ([]domain.ScoredMember, error) {
+	if math.IsNaN(r.Min) || math.IsNaN(r.Max) {
+		return nil, errors.New("score bounds must be numbers")
+	}
+	if r.Offset < 0 || r.Limit < 0 {
+		return nil, errors.New("offset and limit must not be negative")
+	}
+	return s.repo.ZSetRangeByScore(ctx, key, r)
+}
+
+func (s *service) ZSetPop(ctx context.Context, key string, max bool, count int) (_ []domain.ScoredMember, err error) {
+	ctx, span := tracer.Start(ctx, "record.ZSetPop", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
+
+	if count < 1 {
+		return nil, errors.New("count must be positive")
+	}
+	members, err := s.repo.ZSetPop(ctx, key, max, count)
+	if err != nil {
+		return nil, err
+	}
+	s.collectionChanged(ctx, key)
+	return members, nil
+}
+
+func (s *service) ZSetRemove(ctx context.Context, key string, members ...string) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "record.ZSetRemove", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
+
+	if len(members) == 0 {
+		return 0, errors.New("no members given")
+	}
+	removed, err := s.repo.ZSetRemove(ctx, key, members...)
+	if err != nil {
+		return 0, err
+	}
+	s.collectionChanged(ctx, key)
+	return removed, nil
+}
+
+func (s *service) ZSetLength(ctx context.Context, key string) (int, error) {
+	return s.repo.ZSetLength(ctx, key)
+}
@@ -0,0 +1,106 @@
+package record
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"math"
+	"storage/domain"
+	"storage/domain/mocks"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_service_checkElements(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxValueSize = 4
+	config.EncryptPrefixes = []string{"secret/"}
+	s := &service{config: config, keys: newKeyring(nil, testMasterKey(t, 1), nil)}
+
+	assert.NoError(t, s.checkElements("k", []string{"a", "abcd"}))
+	assert.Error(t, s.checkElements("k", nil))
+	assert.ErrorIs(t, s.checkElements("k", []string{"abcde"}), domain.ErrValueTooLarge)
+	assert.ErrorIs(t, s.checkElements("secret/k", []string{"a"}), domain.ErrEncrypted)
+}
+
+func Test_service_HashSet(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+	fields := map[string]string{"name": "alice"}
+	repo.On("HashSet", mock.Anything, "user", fields).Return(1, nil).Once()
+
+	s := NewRecordService(repo, DefaultConfig()).(*service)
+	defer s.Close()
+	s.cache.Set("user", []byte(`{"Key":"user","Type":"hash"}`))
+
+	n, err := s.HashSet(context.TODO(), "user", fields)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	_, err = s.cache.Get("user")
+	assert.Error(t, err, "the record must leave the cache")
+	repo.AssertExpectations(t)
+}
+
+func Test_service_ListPop(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+	repo.On("ListPop", mock.Anything, "queue", domain.ListLeft, 2).Return([]string{"a", "b"}, nil).Once()
+
+	s := NewRecordService(repo, DefaultConfig())
+	defer s.Close()
+
+	values, err := s.ListPop(context.TODO(), "queue", domain.ListLeft, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, values)
+
+	_, err = s.ListPop(context.TODO(), "queue", domain.ListLeft, 0)
+	assert.Error(t, err)
+	repo.AssertExpectations(t)
+}
+
+func Test_service_SetAdd_tooLarge(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+	config := DefaultConfig()
+	config.MaxValueSize = 3
+
+	s := NewRecordService(repo, config)
+	defer s.Close()
+
+	_, err := s.SetAdd(context.TODO(), "tags", "a", strings.Repeat("b", 4))
+	assert.ErrorIs(t, err, domain.ErrValueTooLarge)
+	repo.AssertNotCalled(t, "SetAdd", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func Test_service_SetTtl_collection(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+	expired := &domain.Record{Key: "tags", Type: domain.TypeSet, Ttl: time.Minute}
+	repo.On("Get", mock.Anything, "tags").Return(&domain.Record{Key: "tags", Type: domain.TypeSet}, nil).Once()
+	repo.On("ExpireCollection", mock.Anything, "tags", time.Minute).Return(expired, nil).Once()
+
+	s := NewRecordService(repo, DefaultConfig())
+	defer s.Close()
+
+	r, err := s.SetTtl(context.TODO(), &domain.Record{Key: "tags", Ttl: time.Minute})
+	assert.NoError(t, err)
+	assert.Equal(t, expired, r)
+	repo.AssertNotCalled(t, "Set", mock.Anything, mock.Anything)
+	repo.AssertExpectations(t)
+}
+
+func Test_service_ZSetAdd(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+	members := []domain.ScoredMember{{Member: "alice", Score: 10}, {Member: "bob", Score: 20}}
+	repo.On("ZSetAdd", mock.Anything, "board", members).Return(2, nil).Once()
+
+	s := NewRecordService(repo, DefaultConfig())
+	defer s.Close()
+
+	n, err := s.ZSetAdd(context.TODO(), "board", members...)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	_, err = s.ZSetAdd(context.TODO(), "board", domain.ScoredMember{Member: "carol", Score: math.NaN()})
+	assert.Error(t, err)
+	_, err = s.ZSetIncr(context.TODO(), "board", "carol", math.Inf(1))
+	assert.Error(t, err)
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,80 @@
+package record
+
+import (
+	"encoding/base64"
+	"fmt"
+	"storage/compression"
+	"storage/domain"
+	"storage/metrics"
+)
+
+// compress returns the record to persist and cache for r. Its value is
+// compressed when r asks for an algorithm, or when it reaches
+// CompressionMinSize; json values only with CompressJson, since compressed
+// ones can no longer be patched or queried by the database. Compression is
+// only kept when it saves space, and r.Compression tells which was applied.
+func (s *service) compress(r *domain.Record) (*domain.Record, error) {
+	c := r.Compression
+	bySize := r.Type != domain.TypeJson || s.config.CompressJson
+	if c == "" && bySize && s.config.CompressionMinSize > 0 && len(r.Value) >= s.config.CompressionMinSize {
+		c = s.config.Compression
+	}
+	r.Compression = ""
+	if c == "" || c == domain.CompressionNone {
+		return r, nil
+	}
+	if !r.Type.Compressible() {
+		return nil, fmt.Errorf("%s values cannot be compressed", r.Type)
+	}
+
+	raw, err := r.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := compression.Compress(c, raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > 0 {
+		metrics.CompressionRatio.WithLabelValues(string(c)).Observe(float64(len(compressed)) / float64(len(raw)))
+	}
+	if len(compressed) >= len(raw) {
+		return r, nil
+	}
+	metrics.CompressionBytes.WithLabelValues(string(c), "raw").Add(float64(len(raw)))
+	metrics.CompressionBytes.WithLabelValues(string(c), "compressed").Add(float64(len(compressed)))
+
+	r.Compression = c
+	stored := *r
+	stored.Value = base64.StdEncoding.EncodeToString(compressed)
+	return &stored, nil
+}
+
+// decompress brings the value of a record read from the repository or the
+// cache back into its canonical form; Compression is kept as metadata.
+func decompress(r *domain.Record) error {
+	if r.Compression == "" || r.Blob != nil {
+		return nil
+	}
+	value, err := decompressValue(r.Type, r.Compression, r.Value)
+	if err != nil {
+		return err
+	}
+	r.Value = value
+	return nil
+}
+
+func decompressValue(t domain.ValueType, c domain.Compression, value string) (string, error) {
+	compressed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", err
+	}
+	raw, err := compression.Decompress(c, compressed)
+	if err != nil {
+		return "", fmt.Errorf("decompress value: %w", err)
+	}
+	if t == domain.TypeBytes {
+		return base64.StdEncoding.EncodeToString(raw), nil
+	}
+	return string(raw), nil
+}
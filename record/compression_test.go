@@ -0,0 +1,111 @@
+package record
+
+import (
+	"context"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"storage/compression"
+	"storage/domain"
+	"storage/domain/mocks"
+	"strings"
+	"testing"
+)
+
+func Test_service_compress(t *testing.T) {
+	large := strings.Repeat("verbose ", 100)
+	config := DefaultConfig()
+	config.CompressionMinSize = 64
+	s := &service{config: config}
+
+	tests := []struct {
+		name   string
+		record *domain.Record
+		want   domain.Compression
+	}{
+		{"below the min size", &domain.Record{Value: "short", Type: domain.TypeString}, ""},
+		{"above the min size", &domain.Record{Value: large, Type: domain.TypeString}, domain.CompressionZstd},
+		{"json only on request", &domain.Record{Value: `"` + large + `"`, Type: domain.TypeJson}, ""},
+		{"on request", &domain.Record{Value: `"` + large + `"`, Type: domain.TypeJson, Compression: domain.CompressionGzip}, domain.CompressionGzip},
+		{"opted out", &domain.Record{Value: large, Type: domain.TypeString, Compression: domain.CompressionNone}, ""},
+		{"not worth it", &domain.Record{Value: "abc", Type: domain.TypeString, Compression: domain.CompressionSnappy}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := tt.record.Value
+			stored, err := s.compress(tt.record)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, tt.record.Compression)
+			assert.Equal(t, tt.want, stored.Compression)
+			assert.Equal(t, value, tt.record.Value, "the value of the record written stays as it was")
+
+			assert.NoError(t, decompress(stored))
+			assert.Equal(t, value, stored.Value)
+		})
+	}
+
+	t.Run("json by size when enabled", func(t *testing.T) {
+		config := config
+		config.CompressJson = true
+		s := &service{config: config}
+
+		stored, err := s.compress(&domain.Record{Value: `"` + large + `"`, Type: domain.TypeJson})
+		assert.NoError(t, err)
+		assert.Equal(t, domain.CompressionZstd, stored.Compression)
+	})
+
+	t.Run("numbers are not compressed", func(t *testing.T) {
+		_, err := s.compress(&domain.Record{Value: "42", Type: domain.TypeInt, Compression: domain.CompressionZstd})
+		assert.EqualError(t, err, "int values cannot be compressed")
+	})
+}
+
+func Test_service_Get_compressed(t *testing.T) {
+	value := strings.Repeat("verbose ", 100)
+	compressed, err := compression.Compress(domain.CompressionSnappy, []byte(value))
+	assert.NoError(t, err)
+	stored := &domain.Record{
+		Key:         "k",
+		Value:       base64.StdEncoding.EncodeToString(compressed),
+		Type:        domain.TypeString,
+		Compression: domain.CompressionSnappy,
+	}
+
+	repo := new(mocks.MockRecordRepository)
+	repo.On("TrackAccesses", mock.Anything, mock.Anything).Return(nil).Maybe()
+	repo.On("Get", mock.Anything, "k").Return(stored, nil).Once()
+
+	s := NewRecordService(repo, DefaultConfig()).(*service)
+	defer s.Close()
+
+	// once from the repository, once from the cache, which keeps the
+	// compressed value
+	for i := 0; i < 2; i++ {
+		r, err := s.Get(context.TODO(), "k")
+		assert.NoError(t, err)
+		assert.Equal(t, value, r.Value)
+		assert.Equal(t, domain.CompressionSnappy, r.Compression)
+	}
+	cached, err := s.cache.Get("k")
+	assert.NoError(t, err)
+	assert.Contains(t, string(cached), stored.Value)
+	repo.AssertExpectations(t)
+}
+
+func Test_service_History_compressed(t *testing.T) {
+	compressed, err := compression.Compress(domain.CompressionGzip, []byte(`{"a":1}`))
+	assert.NoError(t, err)
+
+	repo := new(mocks.MockRecordRepository)
+	repo.On("GetHistory", mock.Anything, "doc").Return([]*domain.RecordVersion{
+		{Key: "doc", Version: 1, Value: base64.StdEncoding.EncodeToString(compressed), Type: domain.TypeJson, Compression: domain.CompressionGzip},
+	}, nil).Once()
+
+	s := NewRecordService(repo, DefaultConfig())
+	defer s.Close()
+	versions, err := s.History(context.TODO(), "doc")
+	assert.NoError(t, err)
+	if assert.Len(t, versions, 1) {
+		assert.Equal(t, `{"a":1}`, versions[0].Value)
+	}
+}
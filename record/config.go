@@ -0,0 +1,135 @@
+package record
+
+import (
+	"storage/cache"
+	"storage/domain"
+	"storage/encryption"
+	"time"
+)
+
+type Config struct {
+	// Cache configures the cache in front of the repository.
+	Cache cache.Config
+	// CacheFreshness is how long a cached record is served without asking
+	// the repository; zero keeps serving it until it leaves the cache.
+	CacheFreshness time.Duration
+	// StaleWhileRevalidate is how long past its freshness a cached record is
+	// still served while it is refreshed in the background; zero disables it.
+	// The cache life window must cover both durations.
+	StaleWhileRevalidate time.Duration
+	// CacheMaxValueSize is the largest value, in bytes of its canonical
+	// text, that is cached; larger values and streamed ones are always read
+	// from the repository.
+	CacheMaxValueSize int
+	// MaxValueSize bounds values in bytes, streamed ones included.
+	MaxValueSize int64
+	// Compression is the algorithm values of at least CompressionMinSize
+	// bytes are compressed with, unless their write asks otherwise; a zero
+	// CompressionMinSize only compresses on request. Json values are only
+	// compressed by size with CompressJson, since compressed documents can
+	// no longer be patched in place or found by queries.
+	Compression        domain.Compression
+	CompressionMinSize int
+	CompressJson       bool
+	// MasterKey, when set, enables the encryption of values with data keys
+	// it wraps. PreviousMasterKey unwraps the data keys of the master key
+	// it replaced, which are then wrapped by MasterKey.
+	MasterKey         *encryption.MasterKey
+	PreviousMasterKey *encryption.MasterKey
+	// EncryptPrefixes restricts encryption to the keys with one of these
+	// prefixes; every key is encrypted when it is empty.
+	EncryptPrefixes []string
+	// CachePlaintext caches decrypted values; by default encrypted values
+	// are cached as they were persisted and decrypted on every read.
+	CachePlaintext bool
+	// KeyRotationInterval is the age at which the leader creates a new data
+	// key; zero disables rotation.
+	KeyRotationInterval time.Duration
+	// ReEncryptInterval is how often data keys are reloaded and the leader
+	// re-encrypts the values sealed with older keys, ReEncryptBatchSize rows
+	// per statement.
+	ReEncryptInterval  time.Duration
+	ReEncryptBatchSize int
+	// NegativeCacheTtl is how long a missing key is remembered as missing;
+	// zero disables negative caching.
+	NegativeCacheTtl time.Duration
+
+	// ExpirationStrategy selects how expired records are reclaimed:
+	// ExpirationSweep, ExpirationSampled or ExpirationHybrid (both).
+	ExpirationStrategy string
+
+	// WarmUpKeys is the number of most read keys preloaded into the cache
+	// before the service reports ready; zero disables the warm-up.
+	WarmUpKeys int
+	// WarmUpTimeout bounds the warm-up.
+	WarmUpTimeout time.Duration
+	// AccessLogFlushInterval is how often read counts are written to the
+	// access log, along with the last access time of the records.
+	AccessLogFlushInterval time.Duration
+	// AccessLogMaxKeys bounds the keys counted between two flushes.
+	AccessLogMaxKeys int
+	// AccessLogRetention is how long a key stays in the access log after
+	// its last read.
+	AccessLogRetention time.Duration
+
+	// ExpirySweepInterval is how often expired records are deleted.
+	ExpirySweepInterval time.Duration
+	// ExpirySweepBatchSize bounds the rows deleted by a single statement.
+	ExpirySweepBatchSize int
+	// ExpirySweepBudget bounds the time spent by one sweep; leftovers are
+	// picked up by the next one.
+	ExpirySweepBudget time.Duration
+
+	// ExpirySampleInterval is the longest pause between sampling cycles;
+	// cycles run up to 16 times more often while many keys are expired.
+	ExpirySampleInterval time.Duration
+	// ExpirySampleSize is the number of records with a ttl probed per round.
+	ExpirySampleSize int
+	// ExpirySampleThreshold is the expired share of a sample above which
+	// another round is started right away.
+	ExpirySampleThreshold float64
+	// ExpirySampleBudget bounds the time spent by one sampling cycle.
+	ExpirySampleBudget time.Duration
+
+	// Leader, when set, restricts active expiration to the replica holding
+	// the leadership so that replicas do not sweep the same rows. Lazy
+	// expiration on reads runs on every replica.
+	Leader domain.Leader
+
+	// StreamMaxBlock bounds how long stream reads wait for new entries.
+	StreamMaxBlock time.Duration
+	// StreamPollInterval is how often waiting stream reads look for new
+	// entries on their own, in case an addition was not notified.
+	StreamPollInterval time.Duration
+
+	// Invalidation, when set, broadcasts every write to the caches of the
+	// other replicas and applies theirs to the local cache.
+	Invalidation domain.InvalidationBus
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Cache:                  cache.DefaultConfig(),
+		CacheMaxValueSize:      32 << 10,
+		MaxValueSize:           64 << 20,
+		Compression:            domain.CompressionZstd,
+		KeyRotationInterval:    90 * 24 * time.Hour,
+		ReEncryptInterval:      time.Minute,
+		ReEncryptBatchSize:     500,
+		NegativeCacheTtl:       5 * time.Second,
+		WarmUpTimeout:          30 * time.Second,
+		AccessLogFlushInterval: time.Minute,
+		AccessLogMaxKeys:       10000,
+		AccessLogRetention:     24 * time.Hour,
+		ExpirationStrategy:     ExpirationSweep,
+		ExpirySweepInterval:    10 * time.Minute,
+		ExpirySweepBatchSize:   1000,
+		ExpirySweepBudget:      30 * time.Second,
+		ExpirySampleInterval:   time.Second,
+		ExpirySampleSize:       20,
+		ExpirySampleThreshold:  0.25,
+		ExpirySampleBudget:     250 * time.Millisecond,
+		StreamMaxBlock:         30 * time.Second,
+		StreamPollInterval:     time.Second,
+	}
+}
@@ -0,0 +1,327 @@
+package record
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"storage/domain"
+	"storage/encryption"
+	"storage/metrics"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyring holds the unwrapped data keys. The newest one encrypts new
+// values; every one decrypts the values sealed with it.
+type keyring struct {
+	repo     domain.RecordRepository
+	master   *encryption.MasterKey
+	previous *encryption.MasterKey
+
+	mu       sync.RWMutex
+	keys     map[int]*encryption.Key
+	active   int
+	activeAt time.Time
+}
+
+func newKeyring(repo domain.RecordRepository, master, previous *encryption.MasterKey) *keyring {
+	return &keyring{repo: repo, master: master, previous: previous}
+}
+
+// load reads the data keys from the repository, creating the first one
+// when there is none. Keys wrapped by the previous master key are wrapped
+// again by the current one.
+func (k *keyring) load(ctx context.Context) error {
+	dataKeys, err := k.repo.GetDataKeys(ctx)
+	if err != nil {
+		return err
+	}
+	if len(dataKeys) == 0 {
+		return k.rotate(ctx)
+	}
+
+	keys := make(map[int]*encryption.Key, len(dataKeys))
+	active := dataKeys[0]
+	for _, dk := range dataKeys {
+		raw, err := k.unwrap(ctx, dk)
+		if err != nil {
+			return err
+		}
+		if keys[dk.Id], err = encryption.NewKey(raw); err != nil {
+			return err
+		}
+		if dk.Id > active.Id {
+			active = dk
+		}
+	}
+
+	k.mu.Lock()
+	k.keys, k.active, k.activeAt = keys, active.Id, active.CreatedAt
+	k.mu.Unlock()
+	return nil
+}
+
+func (k *keyring) unwrap(ctx context.Context, dk *domain.DataKey) ([]byte, error) {
+	switch {
+	case dk.MasterKeyId == k.master.Id:
+		return k.master.Unwrap(dk.WrappedKey)
+	case k.previous != nil && dk.MasterKeyId == k.previous.Id:
+		raw, err := k.previous.Unwrap(dk.WrappedKey)
+		if err != nil {
+			return nil, err
+		}
+		if dk.WrappedKey, err = k.master.Wrap(raw); err != nil {
+			return nil, err
+		}
+		dk.MasterKeyId = k.master.Id
+		return raw, k.repo.UpdateDataKey(ctx, dk)
+	default:
+		return nil, fmt.Errorf("data key %d is wrapped by unknown master key %s", dk.Id, dk.MasterKeyId)
+	}
+}
+
+// rotate creates a new data key, which encrypts new values from then on.
+func (k *keyring) rotate(ctx context.Context) error {
+	raw, err := encryption.NewDataKey()
+	if err != nil {
+		return err
+	}
+	wrapped, err := k.master.Wrap(raw)
+	if err != nil {
+		return err
+	}
+	if err = k.repo.AddDataKey(ctx, &domain.DataKey{WrappedKey: wrapped, MasterKeyId: k.master.Id}); err != nil {
+		return err
+	}
+	metrics.DataKeyRotations.Inc()
+	return k.load(ctx)
+}
+
+// current returns the data key new values are encrypted with.
+func (k *keyring) current(ctx context.Context) (int, *encryption.Key, error) {
+	k.mu.RLock()
+	id, key := k.active, k.keys[k.active]
+	k.mu.RUnlock()
+	if key != nil {
+		return id, key, nil
+	}
+
+	if err := k.load(ctx); err != nil {
+		return 0, nil, err
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active, k.keys[k.active], nil
+}
+
+// get returns the data key id. Keys created by other replicas since the
+// last load are loaded on demand.
+func (k *keyring) get(ctx context.Context, id int) (*encryption.Key, error) {
+	k.mu.RLock()
+	key := k.keys[id]
+	k.mu.RUnlock()
+	if key != nil {
+		return key, nil
+	}
+
+	if err := k.load(ctx); err != nil {
+		return nil, err
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if key = k.keys[id]; key == nil {
+		return nil, fmt.Errorf("unknown data key %d", id)
+	}
+	return key, nil
+}
+
+func (k *keyring) activeSince() time.Time {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.activeAt
+}
+
+// encrypts reports whether the values of key are stored encrypted.
+func (s *service) encrypts(key string) bool {
+	if s.keys == nil {
+		return false
+	}
+	if len(s.config.EncryptPrefixes) == 0 {
+		return true
+	}
+	for _, prefix := range s.config.EncryptPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// seal returns the record to persist for r: its value compressed, then
+// encrypted when its key is.
+func (s *service) seal(ctx context.Context, r *domain.Record) (*domain.Record, error) {
+	stored, err := s.compress(r)
+	if err != nil {
+		return nil, err
+	}
+	return s.encrypt(ctx, stored)
+}
+
+// open brings the value of a record read from the repository or the cache
+// back into its canonical form.
+func (s *service) open(ctx context.Context, r *domain.Record) error {
+	if err := s.decrypt(ctx, r); err != nil {
+		return err
+	}
+	return decompress(r)
+}
+
+func (s *service) openVersion(ctx context.Context, v *domain.RecordVersion) error {
+	r := &domain.Record{Key: v.Key, Value: v.Value, Type: v.Type, Compression: v.Compression, KeyId: v.KeyId}
+	if err := s.open(ctx, r); err != nil {
+		return err
+	}
+	v.Value, v.KeyId = r.Value, 0
+	return nil
+}
+
+// encrypt seals the stored bytes of the value with the current data key.
+// The record key is authenticated along, so that sealed values cannot be
+// moved to another key.
+func (s *service) encrypt(ctx context.Context, r *domain.Record) (*domain.Record, error) {
+	if !s.encrypts(r.Key) {
+		return r, nil
+	}
+	id, key, err := s.keys.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var plaintext []byte
+	if r.Compression != "" {
+		plaintext, err = base64.StdEncoding.DecodeString(r.Value)
+	} else {
+		plaintext, err = r.Bytes()
+	}
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := key.Seal(plaintext, []byte(r.Key))
+	if err != nil {
+		return nil, err
+	}
+
+	stored := *r
+	stored.Value = base64.StdEncoding.EncodeToString(sealed)
+	stored.KeyId = id
+	return &stored, nil
+}
+
+// decrypt opens an encrypted value into the form it was sealed from.
+func (s *service) decrypt(ctx context.Context, r *domain.Record) error {
+	if r.KeyId == 0 {
+		return nil
+	}
+	if s.keys == nil {
+		return errors.New("value is encrypted but no master key is configured")
+	}
+	key, err := s.keys.get(ctx, r.KeyId)
+	if err != nil {
+		return err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(r.Value)
+	if err != nil {
+		return err
+	}
+	plaintext, err := key.Open(sealed, []byte(r.Key))
+	if err != nil {
+		return fmt.Errorf("decrypt value: %w", err)
+	}
+
+	if r.Compression != "" || r.Type == domain.TypeBytes {
+		r.Value = base64.StdEncoding.EncodeToString(plaintext)
+	} else {
+		r.Value = string(plaintext)
+	}
+	r.KeyId = 0
+	return nil
+}
+
+// maintainKeys reloads the data keys, picking up keys created or rewrapped
+// by other replicas. On the leader it also rotates the data key once it
+// is older than KeyRotationInterval and re-encrypts the values sealed with
+// older keys.
+func (s *service) maintainKeys(ctx context.Context) {
+	if err := s.keys.load(ctx); err != nil {
+		log.Printf("encryption keys: %v\n", err)
+		return
+	}
+	if s.config.Leader != nil && !s.config.Leader.IsLeader() {
+		return
+	}
+
+	if s.config.KeyRotationInterval > 0 && time.Since(s.keys.activeSince()) >= s.config.KeyRotationInterval {
+		if err := s.keys.rotate(ctx); err != nil {
+			log.Printf("encryption key rotation: %v\n", err)
+			return
+		}
+	}
+
+	if err := s.reEncrypt(ctx); err != nil {
+		log.Printf("re-encryption: %v\n", err)
+	}
+}
+
+// reEncrypt moves the values sealed with older data keys to the current
+// one, a batch at a time, and deletes the keys left unused.
+func (s *service) reEncrypt(ctx context.Context) error {
+	id, key, err := s.keys.current(ctx)
+	if err != nil {
+		return err
+	}
+	reseal := func(r *domain.Record) error {
+		old, err := s.keys.get(ctx, r.KeyId)
+		if err != nil {
+			return err
+		}
+		sealed, err := base64.StdEncoding.DecodeString(r.Value)
+		if err != nil {
+			return err
+		}
+		plaintext, err := old.Open(sealed, []byte(r.Key))
+		if err != nil {
+			return fmt.Errorf("decrypt %s: %w", r.Key, err)
+		}
+		if sealed, err = key.Seal(plaintext, []byte(r.Key)); err != nil {
+			return err
+		}
+		r.Value, r.KeyId = base64.StdEncoding.EncodeToString(sealed), id
+		return nil
+	}
+
+	for ctx.Err() == nil {
+		keys, n, err := s.repo.ReEncrypt(ctx, id, s.config.ReEncryptBatchSize, reseal)
+		if err != nil {
+			return err
+		}
+		metrics.ReEncryptedValues.Add(float64(n))
+		for _, k := range keys {
+			s.loads.Forget(k)
+			s.cacheDelete(k)
+		}
+		if len(keys) > 0 {
+			s.publishInvalidation(ctx, keys...)
+		}
+		if n < s.config.ReEncryptBatchSize {
+			break
+		}
+	}
+
+	// replicas may encrypt with a superseded key until they reload theirs
+	_, err = s.repo.DeleteDataKeys(ctx, id, time.Now().Add(-10*s.config.ReEncryptInterval))
+	return err
+}
@@ -0,0 +1,167 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"storage/cache"
+	"storage/domain"
+	"storage/domain/mocks"
+	"storage/encryption"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testMasterKey(t *testing.T, b byte) *encryption.MasterKey {
+	m, err := encryption.NewMasterKey(bytes.Repeat([]byte{b}, encryption.KeySize))
+	assert.NoError(t, err)
+	return m
+}
+
+// testDataKey returns data key id wrapped by master, and the key itself.
+func testDataKey(t *testing.T, master *encryption.MasterKey, id int) (*domain.DataKey, *encryption.Key) {
+	raw, err := encryption.NewDataKey()
+	assert.NoError(t, err)
+	wrapped, err := master.Wrap(raw)
+	assert.NoError(t, err)
+	key, err := encryption.NewKey(raw)
+	assert.NoError(t, err)
+	return &domain.DataKey{Id: id, WrappedKey: wrapped, MasterKeyId: master.Id, CreatedAt: time.Now()}, key
+}
+
+func Test_service_encrypted(t *testing.T) {
+	master := testMasterKey(t, 1)
+	dataKey, key := testDataKey(t, master, 1)
+	value := strings.Repeat("secret ", 20)
+
+	tests := []struct {
+		name           string
+		cachePlaintext bool
+	}{
+		{"ciphertext cached", false},
+		{"plaintext cached", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stored *domain.Record
+			repo := new(mocks.MockRecordRepository)
+			repo.On("TrackAccesses", mock.Anything, mock.Anything).Return(nil).Maybe()
+			repo.On("GetDataKeys", mock.Anything).Return([]*domain.DataKey{dataKey}, nil).Once()
+			repo.On("Set", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				stored = args.Get(1).(*domain.Record)
+			}).Return(nil).Once()
+
+			config := DefaultConfig()
+			config.MasterKey = master
+			config.CachePlaintext = tt.cachePlaintext
+			config.CompressionMinSize = 64
+			s := NewRecordService(repo, config).(*service)
+			defer s.Close()
+
+			assert.NoError(t, s.Set(context.TODO(), &domain.Record{Key: "k", Value: value}))
+			assert.Equal(t, 1, stored.KeyId)
+			assert.Equal(t, domain.CompressionZstd, stored.Compression)
+
+			sealed, err := base64.StdEncoding.DecodeString(stored.Value)
+			assert.NoError(t, err)
+			_, err = key.Open(sealed, []byte("other key"))
+			assert.Error(t, err, "values are bound to their key")
+
+			repo.On("Get", mock.Anything, "k").Return(stored, nil).Once()
+			for i := 0; i < 2; i++ {
+				r, err := s.Get(context.TODO(), "k")
+				assert.NoError(t, err)
+				assert.Equal(t, value, r.Value)
+				assert.Zero(t, r.KeyId)
+			}
+
+			cached, err := s.cache.Get("k")
+			assert.NoError(t, err)
+			assert.Equal(t, !tt.cachePlaintext, bytes.Contains(cached, []byte(stored.Value)))
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_service_encrypts(t *testing.T) {
+	config := DefaultConfig()
+	config.EncryptPrefixes = []string{"secret/", "tokens/"}
+	s := &service{config: config}
+	assert.False(t, s.encrypts("secret/a"), "without a master key")
+
+	s.keys = newKeyring(nil, testMasterKey(t, 1), nil)
+	assert.True(t, s.encrypts("secret/a"))
+	assert.True(t, s.encrypts("tokens/b"))
+	assert.False(t, s.encrypts("public/c"))
+
+	err := s.PutBlob(context.TODO(), &domain.Record{Key: "secret/blob"}, strings.NewReader("x"))
+	assert.ErrorIs(t, err, domain.ErrEncrypted)
+}
+
+func Test_keyring_load(t *testing.T) {
+	previous, master := testMasterKey(t, 1), testMasterKey(t, 2)
+	old, oldKey := testDataKey(t, previous, 1)
+	current, _ := testDataKey(t, master, 2)
+
+	repo := new(mocks.MockRecordRepository)
+	repo.On("GetDataKeys", mock.Anything).Return([]*domain.DataKey{old, current}, nil).Once()
+	repo.On("UpdateDataKey", mock.Anything, mock.MatchedBy(func(k *domain.DataKey) bool {
+		return k.Id == 1 && k.MasterKeyId == master.Id
+	})).Return(nil).Once()
+
+	k := newKeyring(repo, master, previous)
+	id, _, err := k.current(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, id)
+
+	// the key rewrapped by the new master key is still the same key
+	sealed, err := oldKey.Seal([]byte("value"), []byte("k"))
+	assert.NoError(t, err)
+	key, err := k.get(context.TODO(), 1)
+	assert.NoError(t, err)
+	plaintext, err := key.Open(sealed, []byte("k"))
+	assert.NoError(t, err)
+	assert.Equal(t, "value", string(plaintext))
+	repo.AssertExpectations(t)
+
+	t.Run("unknown master key", func(t *testing.T) {
+		unknown, _ := testDataKey(t, previous, 3)
+		repo := new(mocks.MockRecordRepository)
+		repo.On("GetDataKeys", mock.Anything).Return([]*domain.DataKey{unknown}, nil).Once()
+		_, _, err := newKeyring(repo, master, nil).current(context.TODO())
+		assert.ErrorContains(t, err, "unknown master key")
+	})
+}
+
+func Test_service_reEncrypt(t *testing.T) {
+	master := testMasterKey(t, 1)
+	old, oldKey := testDataKey(t, master, 1)
+	current, currentKey := testDataKey(t, master, 2)
+
+	sealed, err := oldKey.Seal([]byte("value"), []byte("k"))
+	assert.NoError(t, err)
+	r := &domain.Record{Key: "k", Value: base64.StdEncoding.EncodeToString(sealed), Type: domain.TypeString, KeyId: 1}
+
+	repo := new(mocks.MockRecordRepository)
+	repo.On("GetDataKeys", mock.Anything).Return([]*domain.DataKey{old, current}, nil).Once()
+	repo.On("ReEncrypt", mock.Anything, 2, 500, mock.Anything).Run(func(args mock.Arguments) {
+		assert.NoError(t, args.Get(3).(func(*domain.Record) error)(r))
+	}).Return([]string{"k"}, 1, nil).Once()
+	repo.On("DeleteDataKeys", mock.Anything, 2, mock.Anything).Return(int64(1), nil).Once()
+
+	config := DefaultConfig()
+	config.MasterKey = master
+	s := &service{repo: repo, config: config, cache: cache.NewNoopCache(), keys: newKeyring(repo, master, nil)}
+	assert.NoError(t, s.reEncrypt(context.TODO()))
+
+	assert.Equal(t, 2, r.KeyId)
+	resealed, err := base64.StdEncoding.DecodeString(r.Value)
+	assert.NoError(t, err)
+	plaintext, err := currentKey.Open(resealed, []byte("k"))
+	assert.NoError(t, err)
+	assert.Equal(t, "value", string(plaintext))
+	repo.AssertExpectations(t)
+}
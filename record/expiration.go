@@ -0,0 +1,204 @@
+package record
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"storage/domain"
+	"storage/metrics"
+	"time"
+)
+
+const (
+	ExpirationSweep   = "sweep"
+	ExpirationSampled = "sampled"
+	ExpirationHybrid  = "hybrid"
+)
+
+// ExpirationStrategy actively reclaims expired records in the background.
+// Expired records are also rejected lazily on every read, so a strategy only
+// bounds how long they keep occupying storage.
+type ExpirationStrategy interface {
+	Name() string
+	// Interval is the delay before the first cycle.
+	Interval() time.Duration
+	// Expire runs one cycle and returns how many records it reclaimed and
+	// how long to wait before the next cycle.
+	Expire(ctx context.Context) (reclaimed int64, next time.Duration)
+}
+
+func newExpirationStrategies(repo domain.RecordRepository, config Config) ([]ExpirationStrategy, error) {
+	switch config.ExpirationStrategy {
+	case "", ExpirationSweep:
+		return []ExpirationStrategy{NewSweepExpiration(repo, config)}, nil
+	case ExpirationSampled:
+		return []ExpirationStrategy{NewSampledExpiration(repo, config)}, nil
+	case ExpirationHybrid:
+		return []ExpirationStrategy{NewSweepExpiration(repo, config), NewSampledExpiration(repo, config)}, nil
+	default:
+		return nil, fmt.Errorf("unknown expiration strategy: %s", config.ExpirationStrategy)
+	}
+}
+
+// runExpiration runs strategy until ctx is cancelled. Cycles are skipped
+// while leader, if any, is not leading.
+func runExpiration(ctx context.Context, strategy ExpirationStrategy, leader domain.Leader) {
+	timer := time.NewTimer(strategy.Interval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		if leader != nil && !leader.IsLeader() {
+			timer.Reset(strategy.Interval())
+			continue
+		}
+
+		start := time.Now()
+		reclaimed, next := strategy.Expire(ctx)
+
+		name := strategy.Name()
+		metrics.ExpirySweeps.WithLabelValues(name).Inc()
+		metrics.ExpiredKeysDeleted.WithLabelValues(name).Add(float64(reclaimed))
+		metrics.ExpiryCycleReclaimed.WithLabelValues(name).Observe(float64(reclaimed))
+		metrics.ExpirySweepDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+
+		timer.Reset(next)
+	}
+}
+
+type sweepExpiration struct {
+	repo   domain.RecordRepository
+	config Config
+}
+
+// NewSweepExpiration deletes all expired records periodically, in batches
+// that walk the expire_at index.
+func NewSweepExpiration(repo domain.RecordRepository, config Config) ExpirationStrategy {
+	return &sweepExpiration{repo: repo, config: config}
+}
+
+func (e *sweepExpiration) Name() string {
+	return ExpirationSweep
+}
+
+func (e *sweepExpiration) Interval() time.Duration {
+	return e.config.ExpirySweepInterval
+}
+
+// Expire deletes expired records in batches until none are left or the
+// sweep budget is spent.
+func (e *sweepExpiration) Expire(ctx context.Context) (int64, time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, e.config.ExpirySweepBudget)
+	defer cancel()
+
+	var deleted int64
+	for {
+		n, err := e.repo.DeleteExpired(ctx, time.Now(), e.config.ExpirySweepBatchSize)
+		deleted += n
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("expiry sweep: %v\n", err)
+			}
+			break
+		}
+		if n < int64(e.config.ExpirySweepBatchSize) {
+			break
+		}
+	}
+
+	return deleted, e.config.ExpirySweepInterval
+}
+
+type sampledExpiration struct {
+	repo     domain.RecordRepository
+	config   Config
+	interval time.Duration
+}
+
+// NewSampledExpiration works like the active expiration of redis: every cycle
+// it probes random records that have a ttl and deletes the expired ones,
+// repeating while the share of expired records in the sample stays above
+// ExpirySampleThreshold. The cycle interval shrinks while many sampled
+// records are expired and grows back once they become rare.
+func NewSampledExpiration(repo domain.RecordRepository, config Config) ExpirationStrategy {
+	return &sampledExpiration{
+		repo:     repo,
+		config:   config,
+		interval: config.ExpirySampleInterval,
+	}
+}
+
+func (e *sampledExpiration) Name() string {
+	return ExpirationSampled
+}
+
+func (e *sampledExpiration) Interval() time.Duration {
+	return e.config.ExpirySampleInterval
+}
+
+func (e *sampledExpiration) Expire(ctx context.Context) (int64, time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, e.config.ExpirySampleBudget)
+	defer cancel()
+
+	var reclaimed int64
+	ratio := 0.0
+	for ctx.Err() == nil {
+		sample, err := e.repo.SampleVolatile(ctx, e.config.ExpirySampleSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("sampled expiration: %v\n", err)
+			}
+			break
+		}
+		if len(sample) == 0 {
+			ratio = 0
+			break
+		}
+
+		var expiredKeys []string
+		for _, r := range sample {
+			if r.IsExpired() {
+				expiredKeys = append(expiredKeys, r.Key)
+			}
+		}
+		ratio = float64(len(expiredKeys)) / float64(len(sample))
+
+		if len(expiredKeys) > 0 {
+			n, err := e.repo.DeleteIfExpired(ctx, time.Now(), expiredKeys...)
+			reclaimed += n
+			if err != nil {
+				break
+			}
+		}
+
+		if ratio <= e.config.ExpirySampleThreshold {
+			break
+		}
+	}
+
+	metrics.ExpirySampledRatio.Set(ratio)
+	return reclaimed, e.nextInterval(ratio)
+}
+
+// nextInterval halves the interval while the expired ratio is high and
+// doubles it back up to ExpirySampleInterval otherwise.
+func (e *sampledExpiration) nextInterval(ratio float64) time.Duration {
+	minInterval := e.config.ExpirySampleInterval / 16
+	if ratio > e.config.ExpirySampleThreshold {
+		e.interval /= 2
+		if e.interval < minInterval {
+			e.interval = minInterval
+		}
+	} else {
+		e.interval *= 2
+		if e.interval > e.config.ExpirySampleInterval {
+			e.interval = e.config.ExpirySampleInterval
+		}
+	}
+	return e.interval
+}
@@ -0,0 +1,178 @@
+package record
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"storage/domain"
+	"storage/domain/mocks"
+	"testing"
+	"time"
+)
+
+func Test_sweepExpiration_Expire(t *testing.T) {
+	config := DefaultConfig()
+	config.ExpirySweepBatchSize = 2
+
+	t.Run("sweeps batches until one is not full", func(t *testing.T) {
+		repo := new(mocks.MockRecordRepository)
+		repo.
+			On("DeleteExpired", mock.Anything, mock.Anything, 2).Return(int64(2), nil).Twice().
+			On("DeleteExpired", mock.Anything, mock.Anything, 2).Return(int64(1), nil).Once()
+
+		reclaimed, next := NewSweepExpiration(repo, config).Expire(context.TODO())
+		assert.Equal(t, int64(5), reclaimed)
+		assert.Equal(t, config.ExpirySweepInterval, next)
+
+		repo.AssertNumberOfCalls(t, "DeleteExpired", 3)
+	})
+
+	t.Run("stops on error", func(t *testing.T) {
+		repo := new(mocks.MockRecordRepository)
+		repo.On("DeleteExpired", mock.Anything, mock.Anything, 2).Return(int64(0), errors.New("db down")).Once()
+
+		NewSweepExpiration(repo, config).Expire(context.TODO())
+
+		repo.AssertNumberOfCalls(t, "DeleteExpired", 1)
+	})
+
+	t.Run("stops when budget is spent", func(t *testing.T) {
+		config := config
+		config.ExpirySweepBudget = time.Millisecond
+
+		repo := new(mocks.MockRecordRepository)
+		repo.On("DeleteExpired", mock.Anything, mock.Anything, 2).
+			Run(func(args mock.Arguments) { <-args.Get(0).(context.Context).Done() }).
+			Return(int64(0), context.DeadlineExceeded).Once()
+
+		NewSweepExpiration(repo, config).Expire(context.TODO())
+
+		repo.AssertNumberOfCalls(t, "DeleteExpired", 1)
+	})
+}
+
+func Test_sampledExpiration_Expire(t *testing.T) {
+	config := DefaultConfig()
+	config.ExpirySampleSize = 4
+
+	live := &domain.Record{Key: "live", Ttl: time.Hour}
+	expired := func(key string) *domain.Record {
+		return &domain.Record{Key: key, Ttl: -time.Second}
+	}
+
+	t.Run("few expired keys", func(t *testing.T) {
+		repo := new(mocks.MockRecordRepository)
+		repo.
+			On("SampleVolatile", mock.Anything, 4).
+			Return([]*domain.Record{live, live, live, expired("a")}, nil).Once().
+			On("DeleteIfExpired", mock.Anything, mock.Anything, []string{"a"}).
+			Return(int64(1), nil).Once()
+
+		e := NewSampledExpiration(repo, config)
+		reclaimed, next := e.Expire(context.TODO())
+		assert.Equal(t, int64(1), reclaimed)
+		assert.Equal(t, config.ExpirySampleInterval, next)
+
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("many expired keys repeat and speed up", func(t *testing.T) {
+		repo := new(mocks.MockRecordRepository)
+		repo.
+			On("SampleVolatile", mock.Anything, 4).
+			Return([]*domain.Record{expired("a"), expired("b"), live, live}, nil).Once().
+			On("SampleVolatile", mock.Anything, 4).
+			Return([]*domain.Record{expired("c"), live, live, live}, nil).Once().
+			On("DeleteIfExpired", mock.Anything, mock.Anything, []string{"a", "b"}).
+			Return(int64(2), nil).Once().
+			On("DeleteIfExpired", mock.Anything, mock.Anything, []string{"c"}).
+			Return(int64(1), nil).Once()
+
+		e := NewSampledExpiration(repo, config)
+		reclaimed, _ := e.Expire(context.TODO())
+		assert.Equal(t, int64(3), reclaimed)
+		repo.AssertExpectations(t)
+
+		s := e.(*sampledExpiration)
+		assert.Equal(t, config.ExpirySampleInterval/2, s.nextInterval(0.5))
+		assert.Equal(t, config.ExpirySampleInterval/4, s.nextInterval(0.5))
+		assert.Equal(t, config.ExpirySampleInterval/2, s.nextInterval(0))
+	})
+
+	t.Run("no keys with ttl", func(t *testing.T) {
+		repo := new(mocks.MockRecordRepository)
+		repo.On("SampleVolatile", mock.Anything, 4).Return(nil, nil).Once()
+
+		reclaimed, _ := NewSampledExpiration(repo, config).Expire(context.TODO())
+		assert.Equal(t, int64(0), reclaimed)
+		repo.AssertNotCalled(t, "DeleteIfExpired", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func Test_newExpirationStrategies(t *testing.T) {
+	config := DefaultConfig()
+
+	config.ExpirationStrategy = ExpirationHybrid
+	strategies, err := newExpirationStrategies(nil, config)
+	assert.NoError(t, err)
+	if assert.Len(t, strategies, 2) {
+		assert.Equal(t, ExpirationSweep, strategies[0].Name())
+		assert.Equal(t, ExpirationSampled, strategies[1].Name())
+	}
+
+	config.ExpirationStrategy = "lru"
+	_, err = newExpirationStrategies(nil, config)
+	assert.Error(t, err)
+}
+
+type countingExpiration struct {
+	cycles chan struct{}
+}
+
+func (e *countingExpiration) Name() string            { return "counting" }
+func (e *countingExpiration) Interval() time.Duration { return time.Millisecond }
+func (e *countingExpiration) Expire(context.Context) (int64, time.Duration) {
+	e.cycles <- struct{}{}
+	return 0, time.Millisecond
+}
+
+func Test_runExpiration(t *testing.T) {
+	t.Run("runs while leading", func(t *testing.T) {
+		leader := new(mocks.MockLeader)
+		leader.On("IsLeader").Return(true)
+		strategy := &countingExpiration{cycles: make(chan struct{})}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go runExpiration(ctx, strategy, leader)
+
+		select {
+		case <-strategy.cycles:
+		case <-time.After(time.Second):
+			t.Fatal("expiration did not run")
+		}
+	})
+
+	t.Run("skips cycles while following", func(t *testing.T) {
+		leader := new(mocks.MockLeader)
+		leader.On("IsLeader").Return(false)
+		strategy := &countingExpiration{cycles: make(chan struct{})}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			runExpiration(ctx, strategy, leader)
+			close(done)
+		}()
+
+		select {
+		case <-strategy.cycles:
+			t.Fatal("expiration ran without leadership")
+		case <-time.After(20 * time.Millisecond):
+		}
+		cancel()
+		<-done
+		leader.AssertCalled(t, "IsLeader")
+	})
+}
@@ -0,0 +1,77 @@
+package record
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+const generationStripes = 256
+
+// generations counts the writes of each key, so that a load that read a
+// key before a write does not cache what it read after the write dropped
+// the key from the cache. Keys share counters by hash; a write then also
+// keeps loads of the keys sharing its counter from caching, which costs a
+// cache miss but is never stale.
+type generations struct {
+	stripes [generationStripes]generationStripe
+}
+
+type generationStripe struct {
+	mu sync.Mutex
+	n  uint64
+}
+
+func generationStripeOf(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % generationStripes)
+}
+
+// current returns the generation of key, to be taken before reading it.
+func (g *generations) current(key string) uint64 {
+	stripe := &g.stripes[generationStripeOf(key)]
+	stripe.mu.Lock()
+	defer stripe.mu.Unlock()
+	return stripe.n
+}
+
+// all returns the generations of every key, indexed by generationStripeOf.
+func (g *generations) all() [generationStripes]uint64 {
+	var gens [generationStripes]uint64
+	for i := range g.stripes {
+		g.stripes[i].mu.Lock()
+		gens[i] = g.stripes[i].n
+		g.stripes[i].mu.Unlock()
+	}
+	return gens
+}
+
+// bump marks key as written. Writers bump it before they drop key from the
+// cache.
+func (g *generations) bump(key string) {
+	stripe := &g.stripes[generationStripeOf(key)]
+	stripe.mu.Lock()
+	stripe.n++
+	stripe.mu.Unlock()
+}
+
+// bumpAll marks every key as written.
+func (g *generations) bumpAll() {
+	for i := range g.stripes {
+		g.stripes[i].mu.Lock()
+		g.stripes[i].n++
+		g.stripes[i].mu.Unlock()
+	}
+}
+
+// ifCurrent runs cache, which caches what was read of key at generation
+// gen, unless key was written since. A write cannot slip in between the
+// check and cache.
+func (g *generations) ifCurrent(key string, gen uint64, cache func()) {
+	stripe := &g.stripes[generationStripeOf(key)]
+	stripe.mu.Lock()
+	defer stripe.mu.Unlock()
+	if stripe.n == gen {
+		cache()
+	}
+}
@@ -1,10 +1,16 @@
 package record
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"io"
 	"net/http"
 	"storage/domain"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -18,10 +24,56 @@ func NewRecordController(rg *gin.RouterGroup, rs domain.RecordService) {
 	rg.POST("", h.set)
 	rg.GET("", h.getAll)
 	rg.GET(":key", h.get)
+	rg.PUT(":key", h.put)
+	rg.PATCH(":key", h.patch)
+	rg.PUT(":key/blob", h.putBlob)
+	rg.GET(":key/blob", h.getBlob)
+	rg.HEAD(":key/blob", h.getBlob)
+	rg.GET(":key/json", h.getPath)
+	rg.PUT(":key/json", h.setPath)
+	rg.DELETE(":key/json", h.deletePath)
+	rg.GET(":key/history", h.history)
+	rg.POST(":key/restore", h.restore)
+	rg.POST(":key/incr", h.incr)
 	rg.POST("ttl", h.setTtl)
+	rg.POST("query", h.query)
+	rg.GET("indexes", h.indexes)
+	rg.POST("indexes", h.createIndex)
+	rg.DELETE("indexes/:name", h.dropIndex)
+	rg.PUT(":key/hash", h.hashSet)
+	rg.GET(":key/hash", h.hashGetAll)
+	rg.GET(":key/hash/:field", h.hashGet)
+	rg.DELETE(":key/hash", h.hashDelete)
+	rg.POST(":key/list", h.listPush)
+	rg.POST(":key/list/pop", h.listPop)
+	rg.GET(":key/list", h.listRange)
+	rg.GET(":key/list/length", h.listLength)
+	rg.POST(":key/set", h.setAdd)
+	rg.DELETE(":key/set", h.setRemove)
+	rg.GET(":key/set", h.setMembers)
+	rg.GET(":key/set/:member", h.setContains)
+	rg.POST(":key/zset", h.zsetAdd)
+	rg.POST(":key/zset/incr", h.zsetIncr)
+	rg.POST(":key/zset/pop", h.zsetPop)
+	rg.DELETE(":key/zset", h.zsetRemove)
+	rg.GET(":key/zset", h.zsetRange)
+	rg.GET(":key/zset/length", h.zsetLength)
+	rg.GET(":key/zset/:member", h.zsetRank)
+	rg.POST(":key/stream", h.streamAdd)
+	rg.GET(":key/stream", h.streamRead)
+	rg.DELETE(":key/stream", h.streamTrim)
+	rg.POST(":key/stream/groups", h.streamCreateGroup)
+	rg.DELETE(":key/stream/groups/:group", h.streamDeleteGroup)
+	rg.POST(":key/stream/groups/:group/read", h.streamReadGroup)
+	rg.POST(":key/stream/groups/:group/ack", h.streamAck)
+	rg.GET(":key/stream/groups/:group/pending", h.streamPending)
+	rg.POST(":key/stream/groups/:group/claim", h.streamClaim)
 }
 
 // @Summary set a record
+// @Description the value is any json value; without `type` it is stored as a string, int, float, bool or json document depending on its json type. Bytes values are sent base64 encoded.
+// @Description `compression` stores string, bytes and json values compressed; reads decompress them transparently.
+// @Description `owner`, `tags` and `content_type` are kept from the previous value when left out; `owner` defaults to the writer.
 // @Accept  json
 // @Produce  json
 // @Param   req body setRecordRequest true "setRecordRequest"
@@ -36,7 +88,13 @@ func (h *handler) set(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.Set(c.Request.Context(), req.toRecord()); err != nil {
+	record, err := req.toRecord()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = h.service.Set(c.Request.Context(), record); err != nil {
 		c.JSON(http.StatusBadRequest, err.Error())
 		return
 	}
@@ -44,15 +102,128 @@ func (h *handler) set(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
 
+// @Summary set a record from the raw request body
+// @Description the value type follows the content type: application/json is stored as a json document, application/octet-stream as bytes and anything else as a string, unless `type` is given.
+// @Accept  plain
+// @Accept  json
+// @Accept  octet-stream
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   type query string false "value type" Enums(string, int, float, bool, json, bytes)
+// @Param   ttl query string false "ttl as a duration, e.g. 10m"
+// @Param   compression query string false "compression algorithm, none opts out of compression by size" Enums(none, gzip, zstd, snappy)
+// @Param   owner query int false "owning user id, the writer by default"
+// @Param   tag query []string false "tags, kept from the previous value when left out" collectionFormat(multi)
+// @Param   content_type query string false "media type raw reads serve the value with"
+// @Success 200 {object} response
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Router /record/{key} [put]
+func (h *handler) put(c *gin.Context) {
+	record, err := rawRecord(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err = h.service.Set(c.Request.Context(), record); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, toResponse(record))
+}
+
+// @Summary stream a large value into a record
+// @Description the body is stored as a bytes value in chunks, without being buffered, up to the configured maximum value size. Streamed values are not kept in the history.
+// @Accept  octet-stream
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   ttl query string false "ttl as a duration, e.g. 10m"
+// @Success 200 {object} response
+// @Failure 400 {string} string
+// @Failure 413 {string} string
+// @Router /record/{key}/blob [put]
+func (h *handler) putBlob(c *gin.Context) {
+	record := &domain.Record{Key: c.Param("key")}
+	if s := c.Query("ttl"); s != "" {
+		ttl, err := time.ParseDuration(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		record.Ttl = ttl
+	}
+
+	err := h.service.PutBlob(c.Request.Context(), record, c.Request.Body)
+	if errors.Is(err, domain.ErrValueTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.Header("ETag", `"`+record.Blob.Checksum+`"`)
+	c.JSON(http.StatusOK, toResponse(record))
+}
+
+// @Summary stream the raw value of a record
+// @Description works for every record, streamed or not. Supports range requests and conditional requests on the ETag, the sha256 of the value.
+// @Produce  octet-stream
+// @Produce  plain
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   Range header string false "byte range, e.g. bytes=0-1023"
+// @Param   If-None-Match header string false "ETag of a cached copy"
+// @Success 200 {file} file
+// @Success 206 {file} file
+// @Success 304
+// @Failure 400 {string} string
+// @Failure 416 {string} string
+// @Router /record/{key}/blob [get]
+func (h *handler) getBlob(c *gin.Context) {
+	record, body, err := h.service.OpenBlob(c.Request.Context(), c.Param("key"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	tag, err := etag(record)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Header("ETag", tag)
+	c.Header("Content-Type", rawContentType(record))
+	c.Header("X-Value-Type", string(record.Type))
+	http.ServeContent(c.Writer, c.Request, "", time.Time{}, body)
+}
+
 // @Summary get record list
+// @Description timestamps are RFC3339 or unix seconds.
 // @Accept  json
 // @Produce  json
+// @Param   tag query []string false "records carrying all of these tags" collectionFormat(multi)
+// @Param   owner query int false "owning user id"
+// @Param   created_after query string false "created after"
+// @Param   created_before query string false "created before"
+// @Param   updated_after query string false "updated after"
+// @Param   updated_before query string false "updated before"
+// @Param   accessed_before query string false "not read since, never read records included"
 // @Success 200 {object} []response
 // @Failure 400 {string} string
 // @Failure 404 {string} string
 // @Router /record [get]
 func (h *handler) getAll(c *gin.Context) {
-	records := h.service.GetAll(c.Request.Context())
+	filter, err := metadataFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	records := h.service.GetAll(c.Request.Context(), filter)
 
 	var res []*response
 	for _, record := range records {
@@ -63,8 +234,14 @@ func (h *handler) getAll(c *gin.Context) {
 }
 
 // @Summary get a record by key
+// @Description when `at` is given, the value the record held at that time is returned.
+// @Description Unless json is accepted, the raw value is returned with the content type of its value type.
 // @Accept  json
 // @Produce  json
+// @Produce  plain
+// @Produce  octet-stream
+// @Param   key path string true "record key"
+// @Param   at query string false "RFC3339 timestamp or unix seconds"
 // @Success 200 {object} response
 // @Failure 400 {string} string
 // @Failure 404 {string} string
@@ -75,7 +252,206 @@ func (h *handler) get(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, errors.New("key slug not found"))
 		return
 	}
-	record, err := h.service.Get(c.Request.Context(), key)
+
+	var record *domain.Record
+	var err error
+	if at := c.Query("at"); at != "" {
+		var t time.Time
+		if t, err = parseTimestamp(at); err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		record, err = h.service.GetAt(c.Request.Context(), key, t)
+	} else {
+		record, err = h.service.Get(c.Request.Context(), key)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	switch c.NegotiateFormat(gin.MIMEJSON, mimeOctetStream, gin.MIMEPlain) {
+	case gin.MIMEJSON:
+		c.JSON(http.StatusOK, toResponse(record))
+	case "":
+		c.JSON(http.StatusNotAcceptable, "only json, plain text and octet-stream responses are supported")
+	default:
+		if record.Type.IsCollection() {
+			c.JSON(http.StatusBadRequest, domain.ErrWrongType.Error())
+			return
+		}
+		if record.Blob != nil {
+			c.Redirect(http.StatusTemporaryRedirect, c.Request.URL.Path+"/blob")
+			return
+		}
+		writeRaw(c, record)
+	}
+}
+
+// @Summary get previous values of a record
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Success 200 {object} []versionResponse
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Router /record/{key}/history [get]
+func (h *handler) history(c *gin.Context) {
+	versions, err := h.service.History(c.Request.Context(), c.Param("key"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := make([]*versionResponse, 0, len(versions))
+	for _, v := range versions {
+		res = append(res, toVersionResponse(v))
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// @Summary restore an older version of a record
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   req body restoreRecordRequest true "restoreRecordRequest"
+// @Success 200 {object} response
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Router /record/{key}/restore [post]
+func (h *handler) restore(c *gin.Context) {
+	var req restoreRecordRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	record, err := h.service.Restore(c.Request.Context(), c.Param("key"), req.Version)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, toResponse(record))
+}
+
+// @Summary patch a json record
+// @Description the patch is a json merge patch (RFC 7396) or a json patch (RFC 6902), depending on the content type. It is applied atomically.
+// @Accept  application/merge-patch+json
+// @Accept  application/json-patch+json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   patch body object true "merge patch or json patch"
+// @Success 200 {object} response
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 415 {string} string
+// @Router /record/{key} [patch]
+func (h *handler) patch(c *gin.Context) {
+	var patchType domain.PatchType
+	switch c.ContentType() {
+	case mimeMergePatch:
+		patchType = domain.PatchMerge
+	case mimeJsonPatch:
+		patchType = domain.PatchJson
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, "patches must be sent as "+mimeMergePatch+" or "+mimeJsonPatch)
+		return
+	}
+
+	patch, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxRawValueSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.applyPatch(c, patchType, patch)
+}
+
+// @Summary get part of a json record
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   path query string false "json pointer, the whole document when empty"
+// @Success 200 {object} object
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Router /record/{key}/json [get]
+func (h *handler) getPath(c *gin.Context) {
+	value, err := h.service.GetPath(c.Request.Context(), c.Param("key"), c.Query("path"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.Data(http.StatusOK, gin.MIMEJSON, value)
+}
+
+// @Summary set part of a json record
+// @Description follows the add operation of json patch: object members are added or replaced, array elements are inserted, `-` appends to an array.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   path query string false "json pointer, the whole document when empty"
+// @Param   value body object true "json value"
+// @Success 200 {object} response
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Router /record/{key}/json [put]
+func (h *handler) setPath(c *gin.Context) {
+	var value json.RawMessage
+	if err := c.BindJSON(&value); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	patch, _ := json.Marshal([]domain.JsonPatchOperation{{Op: "add", Path: c.Query("path"), Value: value}})
+	h.applyPatch(c, domain.PatchJson, patch)
+}
+
+// @Summary delete part of a json record
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   path query string true "json pointer"
+// @Success 200 {object} response
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Router /record/{key}/json [delete]
+func (h *handler) deletePath(c *gin.Context) {
+	patch, _ := json.Marshal([]domain.JsonPatchOperation{{Op: "remove", Path: c.Query("path")}})
+	h.applyPatch(c, domain.PatchJson, patch)
+}
+
+func (h *handler) applyPatch(c *gin.Context, patchType domain.PatchType, patch []byte) {
+	record, err := h.service.Patch(c.Request.Context(), c.Param("key"), patchType, string(patch))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, toResponse(record))
+}
+
+// @Summary increment a numeric record
+// @Description int records only accept whole increments; other value types are rejected.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   req body incrRecordRequest false "incrRecordRequest"
+// @Success 200 {object} response
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Router /record/{key}/incr [post]
+func (h *handler) incr(c *gin.Context) {
+	req := incrRecordRequest{By: 1}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	record, err := h.service.Incr(c.Request.Context(), c.Param("key"), req.By)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, err.Error())
 		return
@@ -108,42 +484,1213 @@ func (h *handler) setTtl(c *gin.Context) {
 	c.JSON(http.StatusOK, toResponse(record))
 }
 
-type setRecordRequest struct {
-	Key   string        `json:"key" binding:"required"`
-	Value string        `json:"value" binding:"required"`
-	Ttl   time.Duration `json:"ttl" swaggertype:"integer"`
+// @Summary query json records by indexed paths
+// @Description every filtered or sorted path needs an index on the prefix. Results are paged; pass `next` of a page as `cursor` to get the following one.
+// @Accept  json
+// @Produce  json
+// @Param   req body queryRequest true "queryRequest"
+// @Success 200 {object} queryResponse
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Router /record/query [post]
+func (h *handler) query(c *gin.Context) {
+	var req queryRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query, err := req.toQuery()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	records, next, err := h.service.Query(c.Request.Context(), query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := queryResponse{Records: make([]*response, 0, len(records))}
+	for _, r := range records {
+		res.Records = append(res.Records, toResponse(r))
+	}
+	if next != nil {
+		res.Next = encodeCursor(next)
+	}
+
+	c.JSON(http.StatusOK, res)
 }
 
-func (s *setRecordRequest) toRecord() *domain.Record {
-	return &domain.Record{
-		Key:   s.Key,
-		Value: s.Value,
-		Ttl:   s.Ttl,
+// @Summary list the secondary indexes
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []indexResponse
+// @Failure 400 {string} string
+// @Router /record/indexes [get]
+func (h *handler) indexes(c *gin.Context) {
+	indexes, err := h.service.Indexes(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := make([]*indexResponse, 0, len(indexes))
+	for _, i := range indexes {
+		res = append(res, toIndexResponse(i))
 	}
+
+	c.JSON(http.StatusOK, res)
 }
 
-type response struct {
-	Key   string        `json:"key"`
-	Value string        `json:"value"`
-	Ttl   time.Duration `json:"ttl,omitempty" swaggertype:"integer"`
+// @Summary create a secondary index on a json path of the records of a key prefix
+// @Accept  json
+// @Produce  json
+// @Param   req body createIndexRequest true "createIndexRequest"
+// @Success 200 {object} indexResponse
+// @Failure 400 {string} string
+// @Router /record/indexes [post]
+func (h *handler) createIndex(c *gin.Context) {
+	var req createIndexRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	index := req.toIndex()
+	if err := h.service.CreateIndex(c.Request.Context(), index); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, toIndexResponse(index))
 }
 
-func toResponse(r *domain.Record) *response {
-	return &response{
-		Key:   r.Key,
-		Value: r.Value,
-		Ttl:   r.Ttl,
+// @Summary drop a secondary index
+// @Accept  json
+// @Produce  json
+// @Param   name path string true "index name"
+// @Success 200
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Router /record/indexes/{name} [delete]
+func (h *handler) dropIndex(c *gin.Context) {
+	err := h.service.DropIndex(c.Request.Context(), c.Param("name"))
+	if errors.Is(err, domain.ErrIndexNotFound) {
+		c.JSON(http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
 	}
+
+	c.Status(http.StatusOK)
 }
 
-type setRecordTtlRequest struct {
-	Key string        `json:"key" binding:"required"`
-	Ttl time.Duration `json:"ttl" binding:"required" swaggertype:"integer"`
+// @Summary set fields of a hash
+// @Description creates the hash when the key is missing or expired.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   req body hashSetRequest true "hashSetRequest"
+// @Success 200 {object} countResponse "number of new fields"
+// @Failure 400 {string} string
+// @Failure 413 {string} string
+// @Router /record/{key}/hash [put]
+func (h *handler) hashSet(c *gin.Context) {
+	var req hashSetRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	n, err := h.service.HashSet(c.Request.Context(), c.Param("key"), req.Fields)
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, countResponse{Count: n})
 }
 
-func (s *setRecordTtlRequest) toRecord() *domain.Record {
-	return &domain.Record{
-		Key: s.Key,
-		Ttl: s.Ttl,
+// @Summary get every field of a hash
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Success 200 {object} map[string]string
+// @Failure 400 {string} string
+// @Router /record/{key}/hash [get]
+func (h *handler) hashGetAll(c *gin.Context) {
+	fields, err := h.service.HashGetAll(c.Request.Context(), c.Param("key"))
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, fields)
+}
+
+// @Summary get a field of a hash
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   field path string true "field"
+// @Success 200 {object} fieldResponse
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Router /record/{key}/hash/{field} [get]
+func (h *handler) hashGet(c *gin.Context) {
+	value, err := h.service.HashGet(c.Request.Context(), c.Param("key"), c.Param("field"))
+	if err != nil {
+		collectionError(c, err)
+		return
 	}
+
+	c.JSON(http.StatusOK, fieldResponse{Field: c.Param("field"), Value: value})
+}
+
+// @Summary delete fields of a hash
+// @Description deleting the last field deletes the hash.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   field query []string true "fields to delete" collectionFormat(multi)
+// @Success 200 {object} countResponse "number of deleted fields"
+// @Failure 400 {string} string
+// @Router /record/{key}/hash [delete]
+func (h *handler) hashDelete(c *gin.Context) {
+	n, err := h.service.HashDelete(c.Request.Context(), c.Param("key"), c.QueryArray("field")...)
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, countResponse{Count: n})
+}
+
+// @Summary push values to a list
+// @Description values are pushed one after the other, so pushing a and b to the left end leaves b first. Creates the list when the key is missing or expired.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   req body listPushRequest true "listPushRequest"
+// @Success 200 {object} countResponse "length of the list"
+// @Failure 400 {string} string
+// @Failure 413 {string} string
+// @Router /record/{key}/list [post]
+func (h *handler) listPush(c *gin.Context) {
+	var req listPushRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	end, err := domain.ParseListEnd(string(req.End))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	n, err := h.service.ListPush(c.Request.Context(), c.Param("key"), end, req.Values...)
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, countResponse{Count: n})
+}
+
+// @Summary pop values from a list
+// @Description popping the last value deletes the list.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   req body listPopRequest false "listPopRequest"
+// @Success 200 {object} []string
+// @Failure 400 {string} string
+// @Router /record/{key}/list/pop [post]
+func (h *handler) listPop(c *gin.Context) {
+	req := listPopRequest{Count: 1}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	end, err := domain.ParseListEnd(string(req.End))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	values, err := h.service.ListPop(c.Request.Context(), c.Param("key"), end, req.Count)
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, values)
+}
+
+// @Summary get a range of a list
+// @Description start and stop are included; negative indexes count from the right end, -1 being the last value.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   start query int false "first index" default(0)
+// @Param   stop query int false "last index" default(-1)
+// @Success 200 {object} []string
+// @Failure 400 {string} string
+// @Router /record/{key}/list [get]
+func (h *handler) listRange(c *gin.Context) {
+	start, err := strconv.Atoi(c.DefaultQuery("start", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "invalid start: "+c.Query("start"))
+		return
+	}
+	stop, err := strconv.Atoi(c.DefaultQuery("stop", "-1"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "invalid stop: "+c.Query("stop"))
+		return
+	}
+
+	values, err := h.service.ListRange(c.Request.Context(), c.Param("key"), start, stop)
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, values)
+}
+
+// @Summary get the length of a list
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Success 200 {object} countResponse
+// @Failure 400 {string} string
+// @Router /record/{key}/list/length [get]
+func (h *handler) listLength(c *gin.Context) {
+	n, err := h.service.ListLength(c.Request.Context(), c.Param("key"))
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, countResponse{Count: n})
+}
+
+// @Summary add members to a set
+// @Description creates the set when the key is missing or expired.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   req body setMembersRequest true "setMembersRequest"
+// @Success 200 {object} countResponse "number of new members"
+// @Failure 400 {string} string
+// @Failure 413 {string} string
+// @Router /record/{key}/set [post]
+func (h *handler) setAdd(c *gin.Context) {
+	var req setMembersRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	n, err := h.service.SetAdd(c.Request.Context(), c.Param("key"), req.Members...)
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, countResponse{Count: n})
+}
+
+// @Summary remove members from a set
+// @Description removing the last member deletes the set.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   member query []string true "members to remove" collectionFormat(multi)
+// @Success 200 {object} countResponse "number of removed members"
+// @Failure 400 {string} string
+// @Router /record/{key}/set [delete]
+func (h *handler) setRemove(c *gin.Context) {
+	n, err := h.service.SetRemove(c.Request.Context(), c.Param("key"), c.QueryArray("member")...)
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, countResponse{Count: n})
+}
+
+// @Summary get the members of a set
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Success 200 {object} []string
+// @Failure 400 {string} string
+// @Router /record/{key}/set [get]
+func (h *handler) setMembers(c *gin.Context) {
+	members, err := h.service.SetMembers(c.Request.Context(), c.Param("key"))
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// @Summary check whether a set contains a member
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   member path string true "member"
+// @Success 200 {object} containsResponse
+// @Failure 400 {string} string
+// @Router /record/{key}/set/{member} [get]
+func (h *handler) setContains(c *gin.Context) {
+	ok, err := h.service.SetContains(c.Request.Context(), c.Param("key"), c.Param("member"))
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, containsResponse{Contains: ok})
+}
+
+// @Summary add members to a sorted set
+// @Description sets the scores of members, which are created when new. Creates the sorted set when the key is missing or expired.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   req body zsetAddRequest true "zsetAddRequest"
+// @Success 200 {object} countResponse "number of new members"
+// @Failure 400 {string} string
+// @Failure 413 {string} string
+// @Router /record/{key}/zset [post]
+func (h *handler) zsetAdd(c *gin.Context) {
+	var req zsetAddRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	n, err := h.service.ZSetAdd(c.Request.Context(), c.Param("key"), req.Members...)
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, countResponse{Count: n})
+}
+
+// @Summary increment the score of a sorted set member
+// @Description new members start from zero. Creates the sorted set when the key is missing or expired.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   req body zsetIncrRequest true "zsetIncrRequest"
+// @Success 200 {object} domain.ScoredMember
+// @Failure 400 {string} string
+// @Failure 413 {string} string
+// @Router /record/{key}/zset/incr [post]
+func (h *handler) zsetIncr(c *gin.Context) {
+	var req zsetIncrRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	score, err := h.service.ZSetIncr(c.Request.Context(), c.Param("key"), req.Member, req.By)
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, domain.ScoredMember{Member: req.Member, Score: score})
+}
+
+// @Summary pop the members with the lowest or highest scores
+// @Description popping the last member deletes the sorted set.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   req body zsetPopRequest false "zsetPopRequest"
+// @Success 200 {object} []domain.ScoredMember
+// @Failure 400 {string} string
+// @Router /record/{key}/zset/pop [post]
+func (h *handler) zsetPop(c *gin.Context) {
+	req := zsetPopRequest{Count: 1}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	members, err := h.service.ZSetPop(c.Request.Context(), c.Param("key"), req.Max, req.Count)
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// @Summary remove members from a sorted set
+// @Description removing the last member deletes the sorted set.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   member query []string true "members to remove" collectionFormat(multi)
+// @Success 200 {object} countResponse "number of removed members"
+// @Failure 400 {string} string
+// @Router /record/{key}/zset [delete]
+func (h *handler) zsetRemove(c *gin.Context) {
+	n, err := h.service.ZSetRemove(c.Request.Context(), c.Param("key"), c.QueryArray("member")...)
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, countResponse{Count: n})
+}
+
+// @Summary get a range of a sorted set
+// @Description members are ordered by score, ties by member. The range goes by rank from start to stop, both included and negative ranks counting from the end, unless min or max is given: it then goes by score, bounds prefixed with ( being exclusive, and takes limit members after offset.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   start query int false "first rank" default(0)
+// @Param   stop query int false "last rank" default(-1)
+// @Param   min query string false "lowest score, e.g. 10, (10 or -inf" default(-inf)
+// @Param   max query string false "highest score" default(+inf)
+// @Param   offset query int false "members to skip in a score range" default(0)
+// @Param   limit query int false "maximum number of members in a score range, all when 0" default(0)
+// @Param   desc query bool false "order from the highest score"
+// @Success 200 {object} []domain.ScoredMember
+// @Failure 400 {string} string
+// @Router /record/{key}/zset [get]
+func (h *handler) zsetRange(c *gin.Context) {
+	desc, err := strconv.ParseBool(c.DefaultQuery("desc", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "invalid desc: "+c.Query("desc"))
+		return
+	}
+
+	var members []domain.ScoredMember
+	_, byMin := c.GetQuery("min")
+	_, byMax := c.GetQuery("max")
+	if byMin || byMax {
+		r, err := scoreRange(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		r.Desc = desc
+		members, err = h.service.ZSetRangeByScore(c.Request.Context(), c.Param("key"), r)
+		if err != nil {
+			collectionError(c, err)
+			return
+		}
+	} else {
+		start, err := strconv.Atoi(c.DefaultQuery("start", "0"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, "invalid start: "+c.Query("start"))
+			return
+		}
+		stop, err := strconv.Atoi(c.DefaultQuery("stop", "-1"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, "invalid stop: "+c.Query("stop"))
+			return
+		}
+		members, err = h.service.ZSetRange(c.Request.Context(), c.Param("key"), start, stop, desc)
+		if err != nil {
+			collectionError(c, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// scoreRange reads the score range of a sorted set range from the query.
+func scoreRange(c *gin.Context) (domain.ScoreRange, error) {
+	var r domain.ScoreRange
+	var err error
+	if r.Min, r.MinExclusive, err = scoreBound(c.DefaultQuery("min", "-inf")); err != nil {
+		return r, fmt.Errorf("invalid min: %w", err)
+	}
+	if r.Max, r.MaxExclusive, err = scoreBound(c.DefaultQuery("max", "+inf")); err != nil {
+		return r, fmt.Errorf("invalid max: %w", err)
+	}
+	if r.Offset, err = strconv.Atoi(c.DefaultQuery("offset", "0")); err != nil {
+		return r, errors.New("invalid offset: " + c.Query("offset"))
+	}
+	if r.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "0")); err != nil {
+		return r, errors.New("invalid limit: " + c.Query("limit"))
+	}
+	return r, nil
+}
+
+// scoreBound parses a score bound, exclusive when prefixed with "(".
+func scoreBound(s string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(s, "(")
+	score, err := strconv.ParseFloat(strings.TrimPrefix(s, "("), 64)
+	return score, exclusive, err
+}
+
+// @Summary get the length of a sorted set
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Success 200 {object} countResponse
+// @Failure 400 {string} string
+// @Router /record/{key}/zset/length [get]
+func (h *handler) zsetLength(c *gin.Context) {
+	n, err := h.service.ZSetLength(c.Request.Context(), c.Param("key"))
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, countResponse{Count: n})
+}
+
+// @Summary get the score and rank of a sorted set member
+// @Description the rank is the number of members ordered before it, from the lowest score unless desc is set.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   member path string true "member"
+// @Param   desc query bool false "rank from the highest score"
+// @Success 200 {object} rankResponse
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Router /record/{key}/zset/{member} [get]
+func (h *handler) zsetRank(c *gin.Context) {
+	desc, err := strconv.ParseBool(c.DefaultQuery("desc", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "invalid desc: "+c.Query("desc"))
+		return
+	}
+
+	m, rank, err := h.service.ZSetRank(c.Request.Context(), c.Param("key"), c.Param("member"), desc)
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rankResponse{ScoredMember: m, Rank: rank})
+}
+
+// @Summary add entries to a stream
+// @Description values are appended in order and get increasing ids. Creates the stream when the key is missing or expired.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   req body streamAddRequest true "streamAddRequest"
+// @Success 200 {object} streamAddResponse
+// @Failure 400 {string} string
+// @Failure 413 {string} string
+// @Router /record/{key}/stream [post]
+func (h *handler) streamAdd(c *gin.Context) {
+	var req streamAddRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ids, err := h.service.StreamAdd(c.Request.Context(), c.Param("key"), req.Values...)
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, streamAddResponse{Ids: ids})
+}
+
+// @Summary read the entries of a stream
+// @Description returns the entries after the given id, oldest first. When there are none, waits up to block for new ones.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   after query int false "id to read after" default(0)
+// @Param   count query int false "maximum number of entries" default(10)
+// @Param   block query string false "how long to wait for new entries, e.g. 5s" default(0s)
+// @Success 200 {object} []domain.StreamEntry
+// @Failure 400 {string} string
+// @Router /record/{key}/stream [get]
+func (h *handler) streamRead(c *gin.Context) {
+	after, err := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "invalid after: "+c.Query("after"))
+		return
+	}
+	count, block, err := streamReadQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := h.service.StreamRead(c.Request.Context(), c.Param("key"), after, count, block)
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// streamReadQuery reads the number of entries to read and how long to wait
+// for them from the query.
+func streamReadQuery(c *gin.Context) (int, time.Duration, error) {
+	count, err := strconv.Atoi(c.DefaultQuery("count", "10"))
+	if err != nil {
+		return 0, 0, errors.New("invalid count: " + c.Query("count"))
+	}
+	block, err := time.ParseDuration(c.DefaultQuery("block", "0s"))
+	if err != nil {
+		return 0, 0, errors.New("invalid block: " + c.Query("block"))
+	}
+	return count, block, nil
+}
+
+// @Summary trim a stream
+// @Description deletes the oldest entries beyond max_length and the entries older than max_age; at least one of them is required.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   max_length query int false "number of entries to keep"
+// @Param   max_age query string false "age of the entries to keep, e.g. 24h"
+// @Success 200 {object} countResponse "number of deleted entries"
+// @Failure 400 {string} string
+// @Router /record/{key}/stream [delete]
+func (h *handler) streamTrim(c *gin.Context) {
+	maxLength, err := strconv.Atoi(c.DefaultQuery("max_length", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "invalid max_length: "+c.Query("max_length"))
+		return
+	}
+	var before time.Time
+	if s := c.Query("max_age"); s != "" {
+		maxAge, err := time.ParseDuration(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, "invalid max_age: "+s)
+			return
+		}
+		before = time.Now().Add(-maxAge)
+	}
+
+	n, err := h.service.StreamTrim(c.Request.Context(), c.Param("key"), maxLength, before)
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, countResponse{Count: n})
+}
+
+// @Summary create a consumer group
+// @Description the group delivers the entries after the given id, or only the ones added from now on with latest. Creates the stream when the key is missing or expired.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   req body streamGroupRequest true "streamGroupRequest"
+// @Success 200
+// @Failure 400 {string} string
+// @Failure 409 {string} string
+// @Router /record/{key}/stream/groups [post]
+func (h *handler) streamCreateGroup(c *gin.Context) {
+	var req streamGroupRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	after := req.After
+	if req.Latest {
+		after = domain.StreamLatest
+	}
+
+	if err := h.service.StreamCreateGroup(c.Request.Context(), c.Param("key"), req.Name, after); err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// @Summary delete a consumer group
+// @Description its pending entries are dropped.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   group path string true "group name"
+// @Success 200
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Router /record/{key}/stream/groups/{group} [delete]
+func (h *handler) streamDeleteGroup(c *gin.Context) {
+	if err := h.service.StreamDeleteGroup(c.Request.Context(), c.Param("key"), c.Param("group")); err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// @Summary read entries as a consumer of a group
+// @Description delivers entries the group has not delivered yet, which stay pending until acknowledged. When there are none, waits up to block for new ones.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   group path string true "group name"
+// @Param   consumer query string true "consumer name"
+// @Param   count query int false "maximum number of entries" default(10)
+// @Param   block query string false "how long to wait for new entries, e.g. 5s" default(0s)
+// @Success 200 {object} []domain.StreamEntry
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Router /record/{key}/stream/groups/{group}/read [post]
+func (h *handler) streamReadGroup(c *gin.Context) {
+	count, block, err := streamReadQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := h.service.StreamReadGroup(c.Request.Context(), c.Param("key"), c.Param("group"), c.Query("consumer"), count, block)
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// @Summary acknowledge entries of a group
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   group path string true "group name"
+// @Param   req body streamAckRequest true "streamAckRequest"
+// @Success 200 {object} countResponse "number of acknowledged entries"
+// @Failure 400 {string} string
+// @Router /record/{key}/stream/groups/{group}/ack [post]
+func (h *handler) streamAck(c *gin.Context) {
+	var req streamAckRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	n, err := h.service.StreamAck(c.Request.Context(), c.Param("key"), c.Param("group"), req.Ids...)
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, countResponse{Count: n})
+}
+
+// @Summary list the pending entries of a group
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   group path string true "group name"
+// @Param   count query int false "maximum number of entries" default(100)
+// @Success 200 {object} []domain.PendingEntry
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Router /record/{key}/stream/groups/{group}/pending [get]
+func (h *handler) streamPending(c *gin.Context) {
+	count, err := strconv.Atoi(c.DefaultQuery("count", "100"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "invalid count: "+c.Query("count"))
+		return
+	}
+
+	pending, err := h.service.StreamPending(c.Request.Context(), c.Param("key"), c.Param("group"), count)
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, pending)
+}
+
+// @Summary claim the entries pending for too long
+// @Description delivers to consumer the entries of the group that have been pending for at least min_idle, the visibility timeout of the entries.
+// @Accept  json
+// @Produce  json
+// @Param   key path string true "record key"
+// @Param   group path string true "group name"
+// @Param   consumer query string true "consumer name"
+// @Param   min_idle query string true "how long the entries must have been pending, e.g. 30s"
+// @Param   count query int false "maximum number of entries" default(10)
+// @Success 200 {object} []domain.StreamEntry
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Router /record/{key}/stream/groups/{group}/claim [post]
+func (h *handler) streamClaim(c *gin.Context) {
+	minIdle, err := time.ParseDuration(c.Query("min_idle"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "invalid min_idle: "+c.Query("min_idle"))
+		return
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", "10"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, "invalid count: "+c.Query("count"))
+		return
+	}
+
+	entries, err := h.service.StreamClaim(c.Request.Context(), c.Param("key"), c.Param("group"), c.Query("consumer"), minIdle, count)
+	if err != nil {
+		collectionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// collectionError answers a failed collection operation.
+func collectionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrFieldNotFound), errors.Is(err, domain.ErrMemberNotFound), errors.Is(err, domain.ErrGroupNotFound):
+		c.JSON(http.StatusNotFound, err.Error())
+	case errors.Is(err, domain.ErrGroupExists):
+		c.JSON(http.StatusConflict, err.Error())
+	case errors.Is(err, domain.ErrValueTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, err.Error())
+	default:
+		c.JSON(http.StatusBadRequest, err.Error())
+	}
+}
+
+type setRecordRequest struct {
+	Key         string             `json:"key" binding:"required"`
+	Value       json.RawMessage    `json:"value" binding:"required" swaggertype:"object"`
+	Type        domain.ValueType   `json:"type"`
+	Ttl         time.Duration      `json:"ttl" swaggertype:"integer"`
+	Compression domain.Compression `json:"compression,omitempty"`
+	Owner       int                `json:"owner,omitempty"`
+	Tags        []string           `json:"tags"`
+	ContentType string             `json:"content_type,omitempty"`
+}
+
+func (s *setRecordRequest) toRecord() (*domain.Record, error) {
+	t, value, err := decodeValue(s.Value, s.Type)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = domain.ParseCompression(string(s.Compression)); err != nil {
+		return nil, err
+	}
+	return &domain.Record{
+		Key:         s.Key,
+		Value:       value,
+		Type:        t,
+		Ttl:         s.Ttl,
+		Compression: s.Compression,
+		Metadata: domain.Metadata{
+			Owner:       s.Owner,
+			Tags:        s.Tags,
+			ContentType: s.ContentType,
+		},
+	}, nil
+}
+
+// response carries the value of inline records; streamed ones have a null
+// value, their size and checksum instead, and are read from /blob.
+// Collections have a null value too, their elements have endpoints of their own.
+type response struct {
+	Key         string             `json:"key"`
+	Value       json.RawMessage    `json:"value" swaggertype:"object"`
+	Type        domain.ValueType   `json:"type"`
+	Ttl         time.Duration      `json:"ttl,omitempty" swaggertype:"integer"`
+	Compression domain.Compression `json:"compression,omitempty"`
+	Size        int64              `json:"size,omitempty"`
+	Checksum    string             `json:"checksum,omitempty"`
+	Owner       int                `json:"owner,omitempty"`
+	Tags        []string           `json:"tags,omitempty"`
+	ContentType string             `json:"content_type,omitempty"`
+	CreatedAt   *time.Time         `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time         `json:"updated_at,omitempty"`
+	// LastAccessedAt lags behind reads by up to the access log flush interval.
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+}
+
+func toResponse(r *domain.Record) *response {
+	res := &response{
+		Key:            r.Key,
+		Value:          encodeValue(r.Type, r.Value),
+		Type:           r.Type,
+		Ttl:            r.Ttl,
+		Compression:    r.Compression,
+		Owner:          r.Owner,
+		Tags:           r.Tags,
+		ContentType:    r.ContentType,
+		CreatedAt:      timestamp(r.CreatedAt),
+		UpdatedAt:      timestamp(r.UpdatedAt),
+		LastAccessedAt: timestamp(r.LastAccessedAt),
+	}
+	if r.Type.IsCollection() {
+		res.Value = json.RawMessage("null")
+	}
+	if r.Blob != nil {
+		res.Value = json.RawMessage("null")
+		res.Size = r.Blob.Size
+		res.Checksum = r.Blob.Checksum
+	}
+	return res
+}
+
+type incrRecordRequest struct {
+	By float64 `json:"by" default:"1"`
+}
+
+type setRecordTtlRequest struct {
+	Key string        `json:"key" binding:"required"`
+	Ttl time.Duration `json:"ttl" binding:"required" swaggertype:"integer"`
+}
+
+func (s *setRecordTtlRequest) toRecord() *domain.Record {
+	return &domain.Record{
+		Key: s.Key,
+		Ttl: s.Ttl,
+	}
+}
+
+type versionResponse struct {
+	Key       string           `json:"key"`
+	Value     json.RawMessage  `json:"value" swaggertype:"object"`
+	Type      domain.ValueType `json:"type"`
+	Version   int              `json:"version"`
+	ChangedBy int              `json:"changed_by"`
+	ChangedAt time.Time        `json:"changed_at"`
+}
+
+func toVersionResponse(v *domain.RecordVersion) *versionResponse {
+	return &versionResponse{
+		Key:       v.Key,
+		Value:     encodeValue(v.Type, v.Value),
+		Type:      v.Type,
+		Version:   v.Version,
+		ChangedBy: v.ChangedBy,
+		ChangedAt: v.ChangedAt,
+	}
+}
+
+type restoreRecordRequest struct {
+	Version int `json:"version" binding:"required"`
+}
+
+// timestamp leaves unknown times out of responses.
+func timestamp(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// metadataFilter reads the metadata filter of a list from the query string.
+func metadataFilter(c *gin.Context) (*domain.MetadataFilter, error) {
+	filter := &domain.MetadataFilter{Tags: c.QueryArray("tag")}
+	if s := c.Query("owner"); s != "" {
+		owner, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, errors.New("invalid owner: " + s)
+		}
+		filter.Owner = owner
+	}
+
+	for param, t := range map[string]*time.Time{
+		"created_after":   &filter.CreatedAfter,
+		"created_before":  &filter.CreatedBefore,
+		"updated_after":   &filter.UpdatedAfter,
+		"updated_before":  &filter.UpdatedBefore,
+		"accessed_before": &filter.AccessedBefore,
+	} {
+		if s := c.Query(param); s != "" {
+			var err error
+			if *t, err = parseTimestamp(s); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return filter, nil
+}
+
+// parseTimestamp accepts either an RFC3339 timestamp or unix seconds.
+func parseTimestamp(s string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.New("invalid timestamp: " + s)
+	}
+	return t, nil
+}
+
+type queryRequest struct {
+	Prefix  string          `json:"prefix" binding:"required"`
+	Filters []filterRequest `json:"filters" binding:"dive"`
+	Sort    string          `json:"sort"`
+	Desc    bool            `json:"desc"`
+	Limit   int             `json:"limit" binding:"omitempty,min=1,max=1000"`
+	Cursor  string          `json:"cursor"`
+	// Tags and Owner narrow the results down by metadata.
+	Tags  []string `json:"tags"`
+	Owner int      `json:"owner"`
+}
+
+type filterRequest struct {
+	Path  string          `json:"path" binding:"required"`
+	Op    domain.FilterOp `json:"op" binding:"required"`
+	Value json.RawMessage `json:"value" binding:"required" swaggertype:"object"`
+}
+
+func (q *queryRequest) toQuery() (*domain.RecordQuery, error) {
+	query := &domain.RecordQuery{
+		Prefix: q.Prefix,
+		SortBy: q.Sort,
+		Desc:   q.Desc,
+		Limit:  q.Limit,
+		Metadata: domain.MetadataFilter{
+			Tags:  q.Tags,
+			Owner: q.Owner,
+		},
+	}
+	for _, f := range q.Filters {
+		query.Filters = append(query.Filters, domain.QueryFilter{Path: f.Path, Op: f.Op, Value: f.Value})
+	}
+	if q.Cursor != "" {
+		after, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query.After = after
+	}
+	return query, nil
+}
+
+type queryResponse struct {
+	Records []*response `json:"records"`
+	Next    string      `json:"next,omitempty"`
+}
+
+func encodeCursor(c *domain.QueryCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*domain.QueryCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var c domain.QueryCursor
+	if err = json.Unmarshal(b, &c); err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	return &c, nil
+}
+
+type createIndexRequest struct {
+	Name   string `json:"name" binding:"required"`
+	Prefix string `json:"prefix" binding:"required"`
+	Path   string `json:"path" binding:"required"`
+}
+
+func (r *createIndexRequest) toIndex() *domain.RecordIndex {
+	return &domain.RecordIndex{
+		Name:   r.Name,
+		Prefix: r.Prefix,
+		Path:   r.Path,
+	}
+}
+
+type indexResponse struct {
+	Name      string    `json:"name"`
+	Prefix    string    `json:"prefix"`
+	Path      string    `json:"path"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func toIndexResponse(i *domain.RecordIndex) *indexResponse {
+	return &indexResponse{
+		Name:      i.Name,
+		Prefix:    i.Prefix,
+		Path:      i.Path,
+		CreatedAt: i.CreatedAt,
+	}
+}
+
+type hashSetRequest struct {
+	Fields map[string]string `json:"fields" binding:"required"`
+}
+
+type fieldResponse struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+type listPushRequest struct {
+	Values []string       `json:"values" binding:"required"`
+	End    domain.ListEnd `json:"end" enums:"left,right" default:"right"`
+}
+
+type listPopRequest struct {
+	End   domain.ListEnd `json:"end" enums:"left,right" default:"right"`
+	Count int            `json:"count" binding:"omitempty,min=1" default:"1"`
+}
+
+type setMembersRequest struct {
+	Members []string `json:"members" binding:"required"`
+}
+
+type countResponse struct {
+	Count int `json:"count"`
+}
+
+type containsResponse struct {
+	Contains bool `json:"contains"`
+}
+
+type zsetAddRequest struct {
+	Members []domain.ScoredMember `json:"members" binding:"required"`
+}
+
+type zsetIncrRequest struct {
+	Member string  `json:"member" binding:"required"`
+	By     float64 `json:"by"`
+}
+
+type zsetPopRequest struct {
+	Max   bool `json:"max"`
+	Count int  `json:"count" binding:"omitempty,min=1" default:"1"`
+}
+
+type rankResponse struct {
+	domain.ScoredMember
+	Rank int `json:"rank"`
+}
+
+type streamAddRequest struct {
+	Values []string `json:"values" binding:"required"`
+}
+
+type streamAddResponse struct {
+	Ids []int64 `json:"ids"`
+}
+
+type streamGroupRequest struct {
+	Name   string `json:"name" binding:"required"`
+	After  int64  `json:"after" binding:"min=0"`
+	Latest bool   `json:"latest"`
+}
+
+type streamAckRequest struct {
+	Ids []int64 `json:"ids" binding:"required"`
 }
@@ -1,16 +1,20 @@
 package record
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"io"
+	"math"
 	"net/http/httptest"
 	"net/url"
 	"storage/domain"
 	"storage/domain/mocks"
 	"storage/util"
+	"strings"
 	"testing"
 	"time"
 )
@@ -19,6 +23,7 @@ func Test_handler_set(t *testing.T) {
 	mockRecord := &domain.Record{
 		Key:   "key",
 		Value: "value",
+		Type:  domain.TypeString,
 		Ttl:   time.Hour,
 	}
 
@@ -30,7 +35,7 @@ func Test_handler_set(t *testing.T) {
 
 		setReq := setRecordRequest{
 			Key:   mockRecord.Key,
-			Value: mockRecord.Value,
+			Value: json.RawMessage(`"value"`),
 			Ttl:   mockRecord.Ttl,
 		}
 
@@ -43,10 +48,26 @@ func Test_handler_set(t *testing.T) {
 		assert.Equal(t, 200, w.Code)
 	})
 
+	t.Run("typed value", func(t *testing.T) {
+		mockService := new(mocks.MockRecordService)
+		mockService.
+			On("Set", mock.Anything, &domain.Record{Key: "doc", Value: `{"a":[1,2]}`, Type: domain.TypeJson}).
+			Return(nil).Once()
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonPost(ctx, setRecordRequest{Key: "doc", Value: json.RawMessage(`{"a":[1,2]}`)})
+		h := handler{service: mockService}
+		h.set(ctx)
+
+		assert.Equal(t, 200, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
 	t.Run("bad request", func(t *testing.T) {
 		mockService := new(mocks.MockRecordService)
 		setReq := setRecordRequest{
-			Value: mockRecord.Value,
+			Value: json.RawMessage(`"value"`),
 			Ttl:   mockRecord.Ttl,
 		}
 
@@ -77,7 +98,7 @@ func Test_handler_getAll(t *testing.T) {
 	}
 
 	mockService := new(mocks.MockRecordService)
-	mockService.On("GetAll", mock.Anything).
+	mockService.On("GetAll", mock.Anything, mock.Anything).
 		Return(records).Once()
 
 	w := httptest.NewRecorder()
@@ -96,6 +117,37 @@ func Test_handler_getAll(t *testing.T) {
 	assert.Equal(t, toResponse(records[1]), res[1])
 }
 
+func Test_handler_getAll_filtered(t *testing.T) {
+	createdAfter := time.Unix(1700000000, 0)
+	mockService := new(mocks.MockRecordService)
+	mockService.On("GetAll", mock.Anything, &domain.MetadataFilter{
+		Tags:         []string{"a", "b"},
+		Owner:        3,
+		CreatedAfter: createdAfter,
+	}).Return([]*domain.Record{}).Once()
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonGet(ctx, []gin.Param{}, url.Values{"tag": {"a", "b"}, "owner": {"3"}, "created_after": {"1700000000"}})
+
+	h := handler{service: mockService}
+	h.getAll(ctx)
+
+	assert.Equal(t, 200, w.Code)
+	mockService.AssertExpectations(t)
+
+	t.Run("invalid timestamp", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonGet(ctx, []gin.Param{}, url.Values{"accessed_before": {"yesterday"}})
+
+		h := handler{service: new(mocks.MockRecordService)}
+		h.getAll(ctx)
+
+		assert.Equal(t, 400, w.Code)
+	})
+}
+
 func Test_handler_get(t *testing.T) {
 	mockRecord := &domain.Record{
 		Key:   "key",
@@ -152,6 +204,500 @@ func Test_handler_get(t *testing.T) {
 	})
 }
 
+func Test_handler_get_raw(t *testing.T) {
+	tests := []struct {
+		name        string
+		record      *domain.Record
+		contentType string
+		body        string
+	}{
+		{"string", &domain.Record{Key: "k", Value: "hello", Type: domain.TypeString}, "text/plain; charset=utf-8", "hello"},
+		{"bytes", &domain.Record{Key: "k", Value: "AAEC", Type: domain.TypeBytes}, "application/octet-stream", "\x00\x01\x02"},
+		{"json", &domain.Record{Key: "k", Value: `{"a":1}`, Type: domain.TypeJson}, "application/json", `{"a":1}`},
+		{"content type", &domain.Record{Key: "k", Value: "a,b", Type: domain.TypeString, Metadata: domain.Metadata{ContentType: "text/csv"}}, "text/csv", "a,b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(mocks.MockRecordService)
+			mockService.On("Get", mock.Anything, "k").Return(tt.record, nil).Once()
+
+			w := httptest.NewRecorder()
+			ctx := util.GetTestGinContext(w)
+			util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "k"}}, url.Values{})
+			ctx.Request.Header.Set("Accept", "application/octet-stream")
+
+			h := handler{service: mockService}
+			h.get(ctx)
+
+			assert.Equal(t, 200, w.Code)
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, string(tt.record.Type), w.Header().Get("X-Value-Type"))
+			assert.Equal(t, tt.body, w.Body.String())
+		})
+	}
+
+	t.Run("not acceptable", func(t *testing.T) {
+		mockService := new(mocks.MockRecordService)
+		mockService.On("Get", mock.Anything, "k").Return(tests[0].record, nil).Once()
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "k"}}, url.Values{})
+		ctx.Request.Header.Set("Accept", "image/png")
+
+		h := handler{service: mockService}
+		h.get(ctx)
+
+		assert.Equal(t, 406, w.Code)
+	})
+}
+
+func Test_handler_put(t *testing.T) {
+	t.Run("octet-stream", func(t *testing.T) {
+		mockService := new(mocks.MockRecordService)
+		mockService.
+			On("Set", mock.Anything, &domain.Record{Key: "blob", Value: "AAEC", Type: domain.TypeBytes, Ttl: time.Minute}).
+			Return(nil).Once()
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "blob"}}, url.Values{"ttl": {"1m"}})
+		ctx.Request.Method = "PUT"
+		ctx.Request.Header.Set("Content-Type", "application/octet-stream")
+		ctx.Request.Body = io.NopCloser(bytes.NewReader([]byte{0, 1, 2}))
+
+		h := handler{service: mockService}
+		h.put(ctx)
+
+		assert.Equal(t, 200, w.Code)
+		assert.JSONEq(t, `{"key":"blob","value":"AAEC","type":"bytes","ttl":60000000000}`, w.Body.String())
+	})
+
+	t.Run("type from query", func(t *testing.T) {
+		mockService := new(mocks.MockRecordService)
+		mockService.
+			On("Set", mock.Anything, &domain.Record{Key: "n", Value: "42", Type: domain.TypeInt}).
+			Return(nil).Once()
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "n"}}, url.Values{"type": {"int"}})
+		ctx.Request.Method = "PUT"
+		ctx.Request.Header.Set("Content-Type", "text/plain")
+		ctx.Request.Body = io.NopCloser(strings.NewReader("42"))
+
+		h := handler{service: mockService}
+		h.put(ctx)
+
+		assert.Equal(t, 200, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("compression", func(t *testing.T) {
+		mockService := new(mocks.MockRecordService)
+		mockService.
+			On("Set", mock.Anything, &domain.Record{Key: "doc", Value: "text", Type: domain.TypeString, Compression: domain.CompressionGzip}).
+			Return(nil).Once()
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "doc"}}, url.Values{"compression": {"gzip"}})
+		ctx.Request.Method = "PUT"
+		ctx.Request.Header.Set("Content-Type", "text/plain")
+		ctx.Request.Body = io.NopCloser(strings.NewReader("text"))
+
+		h := handler{service: mockService}
+		h.put(ctx)
+
+		assert.Equal(t, 200, w.Code)
+		assert.JSONEq(t, `{"key":"doc","value":"text","type":"string","compression":"gzip"}`, w.Body.String())
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		mockService := new(mocks.MockRecordService)
+		mockService.
+			On("Set", mock.Anything, &domain.Record{Key: "report", Value: "a,b", Type: domain.TypeString, Metadata: domain.Metadata{
+				Owner: 4, Tags: []string{"csv", "daily"}, ContentType: "text/csv",
+			}}).
+			Run(func(args mock.Arguments) {
+				r := args.Get(1).(*domain.Record)
+				r.CreatedAt, r.UpdatedAt = createdAt, createdAt
+			}).
+			Return(nil).Once()
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "report"}},
+			url.Values{"owner": {"4"}, "tag": {"csv", "daily"}, "content_type": {"text/csv"}})
+		ctx.Request.Method = "PUT"
+		ctx.Request.Header.Set("Content-Type", "text/plain")
+		ctx.Request.Body = io.NopCloser(strings.NewReader("a,b"))
+
+		h := handler{service: mockService}
+		h.put(ctx)
+
+		assert.Equal(t, 200, w.Code)
+		assert.JSONEq(t, `{"key":"report","value":"a,b","type":"string","owner":4,"tags":["csv","daily"],"content_type":"text/csv",`+
+			`"created_at":"2024-01-02T03:04:05Z","updated_at":"2024-01-02T03:04:05Z"}`, w.Body.String())
+	})
+
+	t.Run("unknown compression", func(t *testing.T) {
+		mockService := new(mocks.MockRecordService)
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "doc"}}, url.Values{"compression": {"lz4"}})
+		ctx.Request.Method = "PUT"
+		ctx.Request.Body = io.NopCloser(strings.NewReader("text"))
+
+		h := handler{service: mockService}
+		h.put(ctx)
+
+		assert.Equal(t, 400, w.Code)
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		mockService := new(mocks.MockRecordService)
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "n"}}, url.Values{"type": {"decimal"}})
+		ctx.Request.Method = "PUT"
+		ctx.Request.Body = io.NopCloser(strings.NewReader("42"))
+
+		h := handler{service: mockService}
+		h.put(ctx)
+
+		assert.Equal(t, 400, w.Code)
+	})
+}
+
+func Test_handler_putBlob(t *testing.T) {
+	t.Run("streams the body", func(t *testing.T) {
+		mockService := new(mocks.MockRecordService)
+		mockService.
+			On("PutBlob", mock.Anything, &domain.Record{Key: "big", Ttl: time.Hour}, mock.Anything).
+			Run(func(args mock.Arguments) {
+				r := args.Get(1).(*domain.Record)
+				r.Type = domain.TypeBytes
+				r.Blob = &domain.Blob{Id: "b1", Size: 3, Checksum: "abc", ChunkSize: 4}
+			}).
+			Return(nil).Once()
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "big"}}, url.Values{"ttl": {"1h"}})
+		ctx.Request.Method = "PUT"
+		ctx.Request.Body = io.NopCloser(bytes.NewReader([]byte{0, 1, 2}))
+
+		h := handler{service: mockService}
+		h.putBlob(ctx)
+
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, `"abc"`, w.Header().Get("ETag"))
+		assert.JSONEq(t, `{"key":"big","value":null,"type":"bytes","ttl":3600000000000,"size":3,"checksum":"abc"}`, w.Body.String())
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		mockService := new(mocks.MockRecordService)
+		mockService.On("PutBlob", mock.Anything, mock.Anything, mock.Anything).Return(domain.ErrValueTooLarge).Once()
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "big"}}, url.Values{})
+		ctx.Request.Method = "PUT"
+		ctx.Request.Body = io.NopCloser(strings.NewReader("data"))
+
+		h := handler{service: mockService}
+		h.putBlob(ctx)
+
+		assert.Equal(t, 413, w.Code)
+	})
+}
+
+func Test_handler_getBlob(t *testing.T) {
+	record := &domain.Record{Key: "k", Value: "hello world", Type: domain.TypeString}
+	tag := `"b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"`
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		code    int
+		body    string
+	}{
+		{"whole value", nil, 200, "hello world"},
+		{"range", map[string]string{"Range": "bytes=6-"}, 206, "world"},
+		{"not modified", map[string]string{"If-None-Match": tag}, 304, ""},
+		{"unsatisfiable range", map[string]string{"Range": "bytes=20-"}, 416, "invalid range: failed to overlap\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(mocks.MockRecordService)
+			mockService.On("OpenBlob", mock.Anything, "k").Return(record, strings.NewReader(record.Value), nil).Once()
+
+			w := httptest.NewRecorder()
+			ctx := util.GetTestGinContext(w)
+			util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "k"}}, url.Values{})
+			for k, v := range tt.headers {
+				ctx.Request.Header.Set(k, v)
+			}
+
+			h := handler{service: mockService}
+			h.getBlob(ctx)
+
+			// gin writes a status without a body once the handler returns
+			assert.Equal(t, tt.code, ctx.Writer.Status())
+			assert.Equal(t, tag, w.Header().Get("ETag"))
+			assert.Equal(t, tt.body, w.Body.String())
+		})
+	}
+}
+
+func Test_handler_get_rawBlob(t *testing.T) {
+	mockService := new(mocks.MockRecordService)
+	blob := &domain.Record{Key: "big", Type: domain.TypeBytes, Blob: &domain.Blob{Id: "b1", Size: 10}}
+	mockService.On("Get", mock.Anything, "big").Return(blob, nil).Once()
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "big"}}, url.Values{})
+	ctx.Request.URL.Path = "/api/record/big"
+	ctx.Request.Header.Set("Accept", "application/octet-stream")
+
+	h := handler{service: mockService}
+	h.get(ctx)
+
+	assert.Equal(t, 307, w.Code)
+	assert.Equal(t, "/api/record/big/blob", w.Header().Get("Location"))
+}
+
+func Test_handler_patch(t *testing.T) {
+	patched := &domain.Record{Key: "doc", Value: `{"a":1}`, Type: domain.TypeJson}
+	tests := []struct {
+		contentType string
+		patchType   domain.PatchType
+	}{
+		{"application/merge-patch+json", domain.PatchMerge},
+		{"application/json-patch+json", domain.PatchJson},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			mockService := new(mocks.MockRecordService)
+			mockService.On("Patch", mock.Anything, "doc", tt.patchType, "{}").Return(patched, nil).Once()
+
+			w := httptest.NewRecorder()
+			ctx := util.GetTestGinContext(w)
+			util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "doc"}}, url.Values{})
+			ctx.Request.Method = "PATCH"
+			ctx.Request.Header.Set("Content-Type", tt.contentType)
+			ctx.Request.Body = io.NopCloser(strings.NewReader("{}"))
+
+			h := handler{service: mockService}
+			h.patch(ctx)
+
+			assert.Equal(t, 200, w.Code)
+			assert.JSONEq(t, `{"key":"doc","value":{"a":1},"type":"json"}`, w.Body.String())
+		})
+	}
+
+	t.Run("unsupported media type", func(t *testing.T) {
+		mockService := new(mocks.MockRecordService)
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonPost(ctx, map[string]int{"a": 1})
+		ctx.Params = []gin.Param{{Key: "key", Value: "doc"}}
+
+		h := handler{service: mockService}
+		h.patch(ctx)
+
+		assert.Equal(t, 415, w.Code)
+	})
+}
+
+func Test_handler_setPath(t *testing.T) {
+	mockService := new(mocks.MockRecordService)
+	mockService.
+		On("Patch", mock.Anything, "doc", domain.PatchJson, `[{"op":"add","path":"/a/-","value":{"b":1}}]`).
+		Return(&domain.Record{Key: "doc", Value: `{"a":[{"b":1}]}`, Type: domain.TypeJson}, nil).Once()
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonPost(ctx, map[string]int{"b": 1})
+	ctx.Params = []gin.Param{{Key: "key", Value: "doc"}}
+	ctx.Request.URL.RawQuery = url.Values{"path": {"/a/-"}}.Encode()
+
+	h := handler{service: mockService}
+	h.setPath(ctx)
+
+	assert.Equal(t, 200, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func Test_handler_deletePath(t *testing.T) {
+	mockService := new(mocks.MockRecordService)
+	mockService.
+		On("Patch", mock.Anything, "doc", domain.PatchJson, `[{"op":"remove","path":"/a"}]`).
+		Return(nil, domain.ErrPatchFailed).Once()
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonDelete(ctx, []gin.Param{{Key: "key", Value: "doc"}})
+	ctx.Request.URL.RawQuery = url.Values{"path": {"/a"}}.Encode()
+
+	h := handler{service: mockService}
+	h.deletePath(ctx)
+
+	assert.Equal(t, 400, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func Test_handler_incr(t *testing.T) {
+	t.Run("default increment", func(t *testing.T) {
+		mockService := new(mocks.MockRecordService)
+		mockService.On("Incr", mock.Anything, "counter", float64(1)).
+			Return(&domain.Record{Key: "counter", Value: "2", Type: domain.TypeInt}, nil).Once()
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonPost(ctx, nil)
+		ctx.Request.Body = io.NopCloser(strings.NewReader(""))
+		ctx.Params = []gin.Param{{Key: "key", Value: "counter"}}
+
+		h := handler{service: mockService}
+		h.incr(ctx)
+
+		assert.Equal(t, 200, w.Code)
+		assert.JSONEq(t, `{"key":"counter","value":2,"type":"int"}`, w.Body.String())
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		mockService := new(mocks.MockRecordService)
+		mockService.On("Incr", mock.Anything, "name", 2.5).
+			Return(nil, domain.ErrWrongType).Once()
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonPost(ctx, incrRecordRequest{By: 2.5})
+		ctx.Params = []gin.Param{{Key: "key", Value: "name"}}
+
+		h := handler{service: mockService}
+		h.incr(ctx)
+
+		assert.Equal(t, 400, w.Code)
+	})
+}
+
+func Test_handler_getAt(t *testing.T) {
+	mockRecord := &domain.Record{
+		Key:   "key",
+		Value: "old",
+	}
+
+	t.Run("unix timestamp", func(t *testing.T) {
+		mockService := new(mocks.MockRecordService)
+		mockService.On("GetAt", mock.Anything, mockRecord.Key, time.Unix(1680000000, 0)).
+			Return(mockRecord, nil).Once()
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: mockRecord.Key}}, url.Values{"at": {"1680000000"}})
+
+		h := handler{service: mockService}
+		h.get(ctx)
+
+		var res response
+		err := json.Unmarshal(w.Body.Bytes(), &res)
+
+		assert.Equal(t, 200, w.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, toResponse(mockRecord), &res)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("invalid timestamp", func(t *testing.T) {
+		mockService := new(mocks.MockRecordService)
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: mockRecord.Key}}, url.Values{"at": {"yesterday"}})
+
+		h := handler{service: mockService}
+		h.get(ctx)
+
+		assert.Equal(t, 400, w.Code)
+	})
+}
+
+func Test_handler_history(t *testing.T) {
+	versions := []*domain.RecordVersion{
+		{Key: "key", Value: "val2", Version: 2, ChangedBy: 1, ChangedAt: time.Now().UTC()},
+		{Key: "key", Value: "val1", Version: 1, ChangedBy: 1, ChangedAt: time.Now().UTC()},
+	}
+
+	mockService := new(mocks.MockRecordService)
+	mockService.On("History", mock.Anything, "key").
+		Return(versions, nil).Once()
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "key"}}, url.Values{})
+
+	h := handler{service: mockService}
+	h.history(ctx)
+
+	var res []*versionResponse
+	err := json.Unmarshal(w.Body.Bytes(), &res)
+
+	assert.Equal(t, 200, w.Code)
+	assert.NoError(t, err)
+	assert.Equal(t, toVersionResponse(versions[0]), res[0])
+	assert.Equal(t, toVersionResponse(versions[1]), res[1])
+}
+
+func Test_handler_restore(t *testing.T) {
+	mockRecord := &domain.Record{
+		Key:   "key",
+		Value: "old",
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockService := new(mocks.MockRecordService)
+		mockService.On("Restore", mock.Anything, mockRecord.Key, 1).
+			Return(mockRecord, nil).Once()
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonPost(ctx, restoreRecordRequest{Version: 1})
+		ctx.Params = []gin.Param{{Key: "key", Value: mockRecord.Key}}
+
+		h := handler{service: mockService}
+		h.restore(ctx)
+
+		var res response
+		err := json.Unmarshal(w.Body.Bytes(), &res)
+
+		assert.Equal(t, 200, w.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, toResponse(mockRecord), &res)
+	})
+
+	t.Run("missing version", func(t *testing.T) {
+		mockService := new(mocks.MockRecordService)
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonPost(ctx, restoreRecordRequest{})
+		ctx.Params = []gin.Param{{Key: "key", Value: mockRecord.Key}}
+
+		h := handler{service: mockService}
+		h.restore(ctx)
+
+		assert.Equal(t, 400, w.Code)
+	})
+}
+
 func Test_handler_setTtl(t *testing.T) {
 	mockRecord := &domain.Record{
 		Key: "key",
@@ -199,3 +745,390 @@ func Test_handler_setTtl(t *testing.T) {
 		assert.Equal(t, 400, w.Code)
 	})
 }
+
+func Test_handler_query(t *testing.T) {
+	mockService := new(mocks.MockRecordService)
+	mockService.
+		On("Query", mock.Anything, &domain.RecordQuery{
+			Prefix: "session:",
+			Filters: []domain.QueryFilter{
+				{Path: "/user_id", Op: domain.FilterEq, Value: json.RawMessage("42")},
+			},
+			SortBy: "/age",
+			Limit:  1,
+			After:  &domain.QueryCursor{Key: "session:a", Value: json.RawMessage("20")},
+		}).
+		Return([]*domain.Record{{Key: "session:b", Value: `{"age":30}`, Type: domain.TypeJson}},
+			&domain.QueryCursor{Key: "session:b", Value: json.RawMessage("30")}, nil).Once()
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonPost(ctx, queryRequest{
+		Prefix:  "session:",
+		Filters: []filterRequest{{Path: "/user_id", Op: domain.FilterEq, Value: json.RawMessage("42")}},
+		Sort:    "/age",
+		Limit:   1,
+		Cursor:  encodeCursor(&domain.QueryCursor{Key: "session:a", Value: json.RawMessage("20")}),
+	})
+
+	h := handler{service: mockService}
+	h.query(ctx)
+
+	var res queryResponse
+	assert.Equal(t, 200, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	if assert.Len(t, res.Records, 1) {
+		assert.Equal(t, "session:b", res.Records[0].Key)
+	}
+	next, err := decodeCursor(res.Next)
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.QueryCursor{Key: "session:b", Value: json.RawMessage("30")}, next)
+}
+
+func Test_handler_query_invalidCursor(t *testing.T) {
+	mockService := new(mocks.MockRecordService)
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonPost(ctx, queryRequest{Prefix: "session:", Cursor: "%%%"})
+
+	h := handler{service: mockService}
+	h.query(ctx)
+
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid cursor")
+}
+
+func Test_handler_createIndex(t *testing.T) {
+	mockService := new(mocks.MockRecordService)
+	mockService.
+		On("CreateIndex", mock.Anything, &domain.RecordIndex{Name: "session_user", Prefix: "session:", Path: "/user_id"}).
+		Return(nil).Once()
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonPost(ctx, createIndexRequest{Name: "session_user", Prefix: "session:", Path: "/user_id"})
+
+	h := handler{service: mockService}
+	h.createIndex(ctx)
+
+	assert.Equal(t, 200, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func Test_handler_dropIndex(t *testing.T) {
+	mockService := new(mocks.MockRecordService)
+	mockService.On("DropIndex", mock.Anything, "missing").Return(domain.ErrIndexNotFound).Once()
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonDelete(ctx, []gin.Param{{Key: "name", Value: "missing"}})
+
+	h := handler{service: mockService}
+	h.dropIndex(ctx)
+
+	assert.Equal(t, 404, w.Code)
+}
+
+func Test_handler_hashSet(t *testing.T) {
+	mockService := new(mocks.MockRecordService)
+	fields := map[string]string{"name": "alice"}
+	mockService.On("HashSet", mock.Anything, "user", fields).Return(1, nil).Once()
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonPut(ctx, hashSetRequest{Fields: fields}, []gin.Param{{Key: "key", Value: "user"}})
+
+	h := handler{service: mockService}
+	h.hashSet(ctx)
+
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"count":1}`, w.Body.String())
+	mockService.AssertExpectations(t)
+}
+
+func Test_handler_hashGet(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"found", nil, 200},
+		{"missing field", domain.ErrFieldNotFound, 404},
+		{"wrong type", domain.ErrWrongType, 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(mocks.MockRecordService)
+			mockService.On("HashGet", mock.Anything, "user", "name").Return("alice", tt.err).Once()
+
+			w := httptest.NewRecorder()
+			ctx := util.GetTestGinContext(w)
+			util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "user"}, {Key: "field", Value: "name"}}, nil)
+
+			h := handler{service: mockService}
+			h.hashGet(ctx)
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.err == nil {
+				assert.JSONEq(t, `{"field":"name","value":"alice"}`, w.Body.String())
+			}
+		})
+	}
+}
+
+func Test_handler_listPush(t *testing.T) {
+	mockService := new(mocks.MockRecordService)
+	mockService.On("ListPush", mock.Anything, "queue", domain.ListLeft, []string{"a", "b"}).Return(2, nil).Once()
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonPost(ctx, listPushRequest{Values: []string{"a", "b"}, End: domain.ListLeft})
+	ctx.Params = []gin.Param{{Key: "key", Value: "queue"}}
+
+	h := handler{service: mockService}
+	h.listPush(ctx)
+
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"count":2}`, w.Body.String())
+	mockService.AssertExpectations(t)
+}
+
+func Test_handler_listPop(t *testing.T) {
+	mockService := new(mocks.MockRecordService)
+	mockService.On("ListPop", mock.Anything, "queue", domain.ListRight, 1).Return([]string{"b"}, nil).Once()
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonPost(ctx, nil)
+	ctx.Request.Body = io.NopCloser(strings.NewReader(""))
+	ctx.Params = []gin.Param{{Key: "key", Value: "queue"}}
+
+	h := handler{service: mockService}
+	h.listPop(ctx)
+
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `["b"]`, w.Body.String())
+	mockService.AssertExpectations(t)
+}
+
+func Test_handler_listRange(t *testing.T) {
+	mockService := new(mocks.MockRecordService)
+	mockService.On("ListRange", mock.Anything, "queue", 1, -2).Return([]string{"b", "c"}, nil).Once()
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "queue"}}, url.Values{"start": {"1"}, "stop": {"-2"}})
+
+	h := handler{service: mockService}
+	h.listRange(ctx)
+
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `["b","c"]`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	ctx = util.GetTestGinContext(w)
+	util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "queue"}}, url.Values{"start": {"first"}})
+	h.listRange(ctx)
+
+	assert.Equal(t, 400, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func Test_handler_setRemove(t *testing.T) {
+	mockService := new(mocks.MockRecordService)
+	mockService.On("SetRemove", mock.Anything, "tags", []string{"a", "b"}).Return(1, nil).Once()
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "tags"}}, url.Values{"member": {"a", "b"}})
+	ctx.Request.Method = "DELETE"
+
+	h := handler{service: mockService}
+	h.setRemove(ctx)
+
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"count":1}`, w.Body.String())
+	mockService.AssertExpectations(t)
+}
+
+func Test_handler_get_collection(t *testing.T) {
+	mockService := new(mocks.MockRecordService)
+	mockService.On("Get", mock.Anything, "tags").Return(&domain.Record{Key: "tags", Type: domain.TypeSet}, nil)
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "tags"}}, nil)
+
+	h := handler{service: mockService}
+	h.get(ctx)
+
+	assert.Equal(t, 200, w.Code)
+	var res map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Nil(t, res["value"])
+	assert.Equal(t, "set", res["type"])
+}
+
+func Test_handler_zsetRange(t *testing.T) {
+	t.Run("by rank", func(t *testing.T) {
+		mockService := new(mocks.MockRecordService)
+		mockService.On("ZSetRange", mock.Anything, "board", 0, 9, true).
+			Return([]domain.ScoredMember{{Member: "bob", Score: 20}}, nil).Once()
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "board"}}, url.Values{"stop": {"9"}, "desc": {"true"}})
+
+		h := handler{service: mockService}
+		h.zsetRange(ctx)
+
+		assert.Equal(t, 200, w.Code)
+		assert.JSONEq(t, `[{"member":"bob","score":20}]`, w.Body.String())
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("by score", func(t *testing.T) {
+		mockService := new(mocks.MockRecordService)
+		mockService.On("ZSetRangeByScore", mock.Anything, "board", domain.ScoreRange{
+			Min: 10, MinExclusive: true, Max: math.Inf(1), Limit: 5,
+		}).Return([]domain.ScoredMember{}, nil).Once()
+
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "board"}}, url.Values{"min": {"(10"}, "limit": {"5"}})
+
+		h := handler{service: mockService}
+		h.zsetRange(ctx)
+
+		assert.Equal(t, 200, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("invalid bound", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		ctx := util.GetTestGinContext(w)
+		util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "board"}}, url.Values{"max": {"top"}})
+
+		h := handler{service: new(mocks.MockRecordService)}
+		h.zsetRange(ctx)
+
+		assert.Equal(t, 400, w.Code)
+	})
+}
+
+func Test_handler_zsetRank(t *testing.T) {
+	mockService := new(mocks.MockRecordService)
+	mockService.On("ZSetRank", mock.Anything, "board", "alice", false).
+		Return(domain.ScoredMember{Member: "alice", Score: 10}, 0, nil).Once()
+	mockService.On("ZSetRank", mock.Anything, "board", "dave", false).
+		Return(domain.ScoredMember{}, 0, domain.ErrMemberNotFound).Once()
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "board"}, {Key: "member", Value: "alice"}}, nil)
+	h := handler{service: mockService}
+	h.zsetRank(ctx)
+
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"member":"alice","score":10,"rank":0}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	ctx = util.GetTestGinContext(w)
+	util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "board"}, {Key: "member", Value: "dave"}}, nil)
+	h.zsetRank(ctx)
+
+	assert.Equal(t, 404, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func Test_handler_streamAdd(t *testing.T) {
+	mockService := new(mocks.MockRecordService)
+	mockService.On("StreamAdd", mock.Anything, "jobs", []string{"a", "b"}).Return([]int64{1, 2}, nil).Once()
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonPost(ctx, streamAddRequest{Values: []string{"a", "b"}})
+	ctx.Params = []gin.Param{{Key: "key", Value: "jobs"}}
+
+	h := handler{service: mockService}
+	h.streamAdd(ctx)
+
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"ids":[1,2]}`, w.Body.String())
+	mockService.AssertExpectations(t)
+}
+
+func Test_handler_streamRead(t *testing.T) {
+	mockService := new(mocks.MockRecordService)
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockService.On("StreamRead", mock.Anything, "jobs", int64(3), 10, 5*time.Second).
+		Return([]domain.StreamEntry{{Id: 4, Value: "a", CreatedAt: created}}, nil).Once()
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "jobs"}}, url.Values{"after": {"3"}, "block": {"5s"}})
+
+	h := handler{service: mockService}
+	h.streamRead(ctx)
+
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `[{"id":4,"value":"a","created_at":"2024-01-01T00:00:00Z"}]`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	ctx = util.GetTestGinContext(w)
+	util.MockJsonGet(ctx, []gin.Param{{Key: "key", Value: "jobs"}}, url.Values{"block": {"forever"}})
+	h.streamRead(ctx)
+
+	assert.Equal(t, 400, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func Test_handler_streamCreateGroup(t *testing.T) {
+	mockService := new(mocks.MockRecordService)
+	mockService.On("StreamCreateGroup", mock.Anything, "jobs", "workers", domain.StreamLatest).Return(nil).Once()
+	mockService.On("StreamCreateGroup", mock.Anything, "jobs", "workers", int64(0)).Return(domain.ErrGroupExists).Once()
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	util.MockJsonPost(ctx, streamGroupRequest{Name: "workers", Latest: true})
+	ctx.Params = []gin.Param{{Key: "key", Value: "jobs"}}
+
+	h := handler{service: mockService}
+	h.streamCreateGroup(ctx)
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	ctx = util.GetTestGinContext(w)
+	util.MockJsonPost(ctx, streamGroupRequest{Name: "workers"})
+	ctx.Params = []gin.Param{{Key: "key", Value: "jobs"}}
+	h.streamCreateGroup(ctx)
+
+	assert.Equal(t, 409, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func Test_handler_streamClaim(t *testing.T) {
+	mockService := new(mocks.MockRecordService)
+	mockService.On("StreamClaim", mock.Anything, "jobs", "workers", "w2", 30*time.Second, 10).
+		Return([]domain.StreamEntry{}, domain.ErrGroupNotFound).Once()
+
+	w := httptest.NewRecorder()
+	ctx := util.GetTestGinContext(w)
+	params := []gin.Param{{Key: "key", Value: "jobs"}, {Key: "group", Value: "workers"}}
+	util.MockJsonGet(ctx, params, url.Values{"consumer": {"w2"}, "min_idle": {"30s"}})
+	ctx.Request.Method = "POST"
+
+	h := handler{service: mockService}
+	h.streamClaim(ctx)
+	assert.Equal(t, 404, w.Code)
+
+	w = httptest.NewRecorder()
+	ctx = util.GetTestGinContext(w)
+	util.MockJsonGet(ctx, params, url.Values{"consumer": {"w2"}})
+	ctx.Request.Method = "POST"
+	h.streamClaim(ctx)
+
+	assert.Equal(t, 400, w.Code)
+	mockService.AssertExpectations(t)
+}
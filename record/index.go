@@ -0,0 +1,113 @@
+package record
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"storage/domain"
+)
+
+const (
+	defaultQueryLimit = 50
+	maxQueryLimit     = 1000
+)
+
+func (s *service) CreateIndex(ctx context.Context, index *domain.RecordIndex) error {
+	if err := index.Validate(); err != nil {
+		return err
+	}
+	return s.repo.CreateIndex(ctx, index)
+}
+
+func (s *service) DropIndex(ctx context.Context, name string) error {
+	return s.repo.DropIndex(ctx, name)
+}
+
+func (s *service) Indexes(ctx context.Context) ([]*domain.RecordIndex, error) {
+	return s.repo.GetIndexes(ctx)
+}
+
+// Query only runs queries that an index can answer: every filtered or
+// sorted path needs an index on the prefix of the query.
+func (s *service) Query(ctx context.Context, query *domain.RecordQuery) (_ []*domain.Record, _ *domain.QueryCursor, err error) {
+	ctx, span := tracer.Start(ctx, "record.Query")
+	defer func() { endSpan(span, err) }()
+
+	indexes, err := s.repo.GetIndexes(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = checkQuery(query, indexes); err != nil {
+		return nil, nil, err
+	}
+
+	q := *query
+	if q.Limit <= 0 {
+		q.Limit = defaultQueryLimit
+	}
+	if q.Limit > maxQueryLimit {
+		q.Limit = maxQueryLimit
+	}
+	// one more record tells whether there is a next page
+	limit := q.Limit
+	q.Limit++
+
+	records, err := s.repo.Query(ctx, &q)
+	if err != nil {
+		return nil, nil, err
+	}
+	// without filters and sorting, compressed and encrypted json records
+	// match too
+	for _, r := range records {
+		if err = s.open(ctx, r); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(records) <= limit {
+		return records, nil, nil
+	}
+
+	records = records[:limit]
+	last := records[limit-1]
+	next := &domain.QueryCursor{Key: last.Key}
+	if q.SortBy != "" {
+		if next.Value, err = domain.JsonPointerGet(last.Value, q.SortBy); err != nil {
+			return nil, nil, err
+		}
+	}
+	return records, next, nil
+}
+
+func checkQuery(query *domain.RecordQuery, indexes []*domain.RecordIndex) error {
+	indexed := map[string]bool{}
+	for _, i := range indexes {
+		if i.Prefix == query.Prefix {
+			indexed[i.Path] = true
+		}
+	}
+	if len(indexed) == 0 {
+		return fmt.Errorf("%w: no index on prefix %q", domain.ErrNotIndexed, query.Prefix)
+	}
+
+	for _, f := range query.Filters {
+		if !indexed[f.Path] {
+			return fmt.Errorf("%w: %s", domain.ErrNotIndexed, f.Path)
+		}
+		switch f.Op {
+		case domain.FilterEq, domain.FilterLt, domain.FilterLte, domain.FilterGt, domain.FilterGte:
+		default:
+			return fmt.Errorf("unknown filter op %q", f.Op)
+		}
+		if len(f.Value) == 0 || !json.Valid(f.Value) {
+			return fmt.Errorf("invalid value for filter on %s", f.Path)
+		}
+	}
+	if query.SortBy != "" && !indexed[query.SortBy] {
+		return fmt.Errorf("%w: %s", domain.ErrNotIndexed, query.SortBy)
+	}
+	if query.After != nil && query.SortBy != "" && len(query.After.Value) == 0 {
+		return errors.New("cursor does not match the sort order")
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package record
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"storage/domain"
+	"storage/domain/mocks"
+	"testing"
+)
+
+func Test_service_CreateIndex(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+	index := &domain.RecordIndex{Name: "session_user", Prefix: "session:", Path: "/user_id"}
+	repo.On("CreateIndex", mock.Anything, index).Return(nil).Once()
+
+	s := NewRecordService(repo, DefaultConfig())
+	defer s.Close()
+	assert.NoError(t, s.CreateIndex(context.TODO(), index))
+
+	err := s.CreateIndex(context.TODO(), &domain.RecordIndex{Name: "Bad Name", Prefix: "session:", Path: "/user_id"})
+	assert.Error(t, err)
+	repo.AssertExpectations(t)
+}
+
+func Test_service_Query(t *testing.T) {
+	indexes := []*domain.RecordIndex{
+		{Name: "session_user", Prefix: "session:", Path: "/user_id"},
+		{Name: "session_age", Prefix: "session:", Path: "/age"},
+		{Name: "order_user", Prefix: "order:", Path: "/total"},
+	}
+	records := []*domain.Record{
+		{Key: "session:a", Value: `{"age":20,"user_id":42}`, Type: domain.TypeJson},
+		{Key: "session:b", Value: `{"age":30,"user_id":42}`, Type: domain.TypeJson},
+		{Key: "session:c", Value: `{"age":40,"user_id":42}`, Type: domain.TypeJson},
+	}
+	filter := domain.QueryFilter{Path: "/user_id", Op: domain.FilterEq, Value: json.RawMessage("42")}
+
+	t.Run("pages", func(t *testing.T) {
+		repo := new(mocks.MockRecordRepository)
+		repo.
+			On("GetIndexes", mock.Anything).Return(indexes, nil).Once().
+			On("Query", mock.Anything, &domain.RecordQuery{
+				Prefix:  "session:",
+				Filters: []domain.QueryFilter{filter},
+				SortBy:  "/age",
+				Limit:   3,
+			}).Return(records, nil).Once()
+
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
+		page, next, err := s.Query(context.TODO(), &domain.RecordQuery{
+			Prefix:  "session:",
+			Filters: []domain.QueryFilter{filter},
+			SortBy:  "/age",
+			Limit:   2,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, records[:2], page)
+		assert.Equal(t, &domain.QueryCursor{Key: "session:b", Value: json.RawMessage("30")}, next)
+	})
+
+	t.Run("last page", func(t *testing.T) {
+		repo := new(mocks.MockRecordRepository)
+		repo.
+			On("GetIndexes", mock.Anything).Return(indexes, nil).Once().
+			On("Query", mock.Anything, mock.Anything).Return(records, nil).Once()
+
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
+		page, next, err := s.Query(context.TODO(), &domain.RecordQuery{Prefix: "session:", Filters: []domain.QueryFilter{filter}})
+		assert.NoError(t, err)
+		assert.Len(t, page, 3)
+		assert.Nil(t, next)
+	})
+
+	tests := []struct {
+		name  string
+		query *domain.RecordQuery
+	}{
+		{"prefix without index", &domain.RecordQuery{Prefix: "user:"}},
+		{"filter on an unindexed path", &domain.RecordQuery{Prefix: "session:", Filters: []domain.QueryFilter{
+			{Path: "/name", Op: domain.FilterEq, Value: json.RawMessage(`"bob"`)},
+		}}},
+		{"index of another prefix", &domain.RecordQuery{Prefix: "session:", SortBy: "/total"}},
+		{"unknown op", &domain.RecordQuery{Prefix: "session:", Filters: []domain.QueryFilter{
+			{Path: "/age", Op: "like", Value: json.RawMessage(`1`)},
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mocks.MockRecordRepository)
+			repo.On("GetIndexes", mock.Anything).Return(indexes, nil).Once()
+
+			s := NewRecordService(repo, DefaultConfig())
+			defer s.Close()
+			_, _, err := s.Query(context.TODO(), tt.query)
+			assert.Error(t, err)
+			repo.AssertNotCalled(t, "Query", mock.Anything, mock.Anything)
+		})
+	}
+}
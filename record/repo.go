@@ -2,16 +2,204 @@ package record
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"io"
 	"log"
+	"sort"
 	"storage/domain"
+	"strings"
 	"time"
 )
 
+// notExpired matches records without a ttl or whose ttl has not passed yet.
+// Records without a ttl store the zero time in expire_at.
+const notExpired = "expire_at = ? OR expire_at > ?"
+
+// historyLimit is the number of versions kept per key; older ones are pruned on write.
+const historyLimit = 20
+
+// blobChunkSize is the size of the chunks streamed values are stored in.
+const blobChunkSize = 256 << 10
+
+// record stores a value in the column that matches its type: strings in
+// value, numbers, booleans and JSON documents in value_json and binary
+// blobs in value_bytes, or in record_chunks when they were streamed in.
+// Compressed and encrypted values of any type are stored in value_bytes.
 type record struct {
-	Key      string `gorm:"primaryKey"`
-	Value    string
-	ExpireAt time.Time `gorm:"index"`
+	Key           string `gorm:"primaryKey"`
+	Type          domain.ValueType
+	Value         string
+	ValueJson     *string `gorm:"type:jsonb"`
+	ValueBytes    []byte
+	Compression   domain.Compression
+	KeyId         *int
+	BlobId        *string
+	BlobSize      int64
+	BlobChecksum  string
+	BlobChunkSize int
+	ExpireAt      time.Time `gorm:"index"`
+
+	Owner          int
+	Tags           tagList `gorm:"type:jsonb"`
+	ContentType    string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	LastAccessedAt *time.Time
+}
+
+// tagList stores the tags of a record as a json array, which the tags @>
+// filters match against.
+type tagList []string
+
+func (t tagList) Value() (driver.Value, error) {
+	if t == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]string(t))
+	return string(b), err
+}
+
+func (t *tagList) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	case nil:
+		*t = nil
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into tags", src)
+	}
+	return json.Unmarshal(b, (*[]string)(t))
+}
+
+type recordChunk struct {
+	BlobId string `gorm:"primaryKey"`
+	Seq    int    `gorm:"primaryKey"`
+	Data   []byte
+}
+
+type recordVersion struct {
+	ID          int
+	Key         string `gorm:"uniqueIndex:idx_record_versions_key_version"`
+	Version     int    `gorm:"uniqueIndex:idx_record_versions_key_version"`
+	Value       string
+	Type        domain.ValueType
+	Compression domain.Compression
+	KeyId       *int
+	ChangedBy   int
+	ChangedAt   time.Time
+}
+
+type recordAccess struct {
+	Key          string `gorm:"primaryKey"`
+	Hits         int64
+	LastAccessAt time.Time `gorm:"index"`
+}
+
+type recordIndex struct {
+	Name      string `gorm:"primaryKey"`
+	Prefix    string
+	Path      string
+	CreatedAt time.Time
+}
+
+// TableName keeps gorm from naming the table record_indices.
+func (recordIndex) TableName() string {
+	return "record_indexes"
+}
+
+// recordHashField, recordListItem, recordSetMember and
+// recordSortedSetMember are the elements of collection records, deleted
+// along with them.
+type recordHashField struct {
+	Key   string `gorm:"primaryKey"`
+	Field string `gorm:"primaryKey"`
+	Value string
+}
+
+type recordListItem struct {
+	Key   string `gorm:"primaryKey"`
+	Pos   int64  `gorm:"primaryKey;autoIncrement:false"`
+	Value string
+}
+
+type recordSetMember struct {
+	Key    string `gorm:"primaryKey"`
+	Member string `gorm:"primaryKey"`
+}
+
+type recordSortedSetMember struct {
+	Key    string `gorm:"primaryKey"`
+	Member string `gorm:"primaryKey"`
+	Score  float64
+}
+
+// recordStream holds the last id handed out by a stream, which outlives
+// its entries.
+type recordStream struct {
+	Key    string `gorm:"primaryKey"`
+	LastId int64
+}
+
+type recordStreamEntry struct {
+	Key       string `gorm:"primaryKey"`
+	Id        int64  `gorm:"primaryKey;autoIncrement:false"`
+	Value     string
+	CreatedAt time.Time
+}
+
+func (e *recordStreamEntry) toEntry() domain.StreamEntry {
+	return domain.StreamEntry{Id: e.Id, Value: e.Value, CreatedAt: e.CreatedAt}
+}
+
+type recordStreamGroup struct {
+	Key             string `gorm:"primaryKey"`
+	Name            string `gorm:"primaryKey"`
+	LastDeliveredId int64
+}
+
+type recordStreamPending struct {
+	Key         string `gorm:"primaryKey"`
+	GroupName   string `gorm:"primaryKey"`
+	EntryId     int64  `gorm:"primaryKey;autoIncrement:false"`
+	Consumer    string
+	DeliveredAt time.Time
+	Deliveries  int
+}
+
+// TableName keeps gorm from naming the table record_stream_pendings.
+func (recordStreamPending) TableName() string {
+	return "record_stream_pending"
+}
+
+// elementTables are the tables holding the elements of each collection type.
+var elementTables = map[domain.ValueType]string{
+	domain.TypeHash: "record_hash_fields",
+	domain.TypeList: "record_list_items",
+	domain.TypeSet:  "record_set_members",
+	domain.TypeZSet: "record_sorted_set_members",
+	// streams keep their ids and groups once empty
+	domain.TypeStream: "record_streams",
+}
+
+type encryptionKey struct {
+	ID          int
+	WrappedKey  []byte
+	MasterKeyId string
+	CreatedAt   time.Time
 }
 
 type postgresRepo struct {
@@ -19,26 +207,382 @@ type postgresRepo struct {
 }
 
 func NewPostgresRecordRepository(db *gorm.DB) domain.RecordRepository {
-	if err := db.AutoMigrate(record{}); err != nil {
-		log.Println(err)
-	}
-
 	return &postgresRepo{db: db}
 }
 
 func (p *postgresRepo) Set(ctx context.Context, record *domain.Record) error {
-	return p.db.WithContext(ctx).Save(convertToModel(record)).Error
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		model, err := convertToModel(record)
+		if err != nil {
+			return err
+		}
+		if err = upsert(tx, model, domain.UserIdFromContext(ctx)); err != nil {
+			return err
+		}
+		record.Metadata = model.metadata()
+
+		return addVersion(tx, model, domain.UserIdFromContext(ctx))
+	})
+}
+
+func (p *postgresRepo) Update(ctx context.Context, key string, fn func(*domain.Record) error) (*domain.Record, error) {
+	var updated *domain.Record
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current record
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("key = ?", key).
+			Where(notExpired, time.Time{}, time.Now()).
+			First(&current).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.ErrRecordNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		r := current.toRecord()
+		ttl := r.Ttl
+		if err = fn(r); err != nil {
+			return err
+		}
+
+		model, err := convertToModel(r)
+		if err != nil {
+			return err
+		}
+		if r.Ttl == ttl {
+			model.ExpireAt = current.ExpireAt
+		}
+		// the access log stamps reads concurrently
+		if err = tx.Omit("created_at", "last_accessed_at").Save(model).Error; err != nil {
+			return err
+		}
+
+		r.UpdatedAt = model.UpdatedAt
+		updated = r
+		return addVersion(tx, model, domain.UserIdFromContext(ctx))
+	})
+	return updated, err
 }
 
 func (p *postgresRepo) Get(ctx context.Context, key string) (*domain.Record, error) {
 	var r record
-	err := p.db.WithContext(ctx).Where("key = ?", key).First(&r).Error
+	err := p.db.WithContext(ctx).
+		Where("key = ?", key).
+		Where(notExpired, time.Time{}, time.Now()).
+		First(&r).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = domain.ErrRecordNotFound
+	}
 	return r.toRecord(), err
 }
 
-func (p *postgresRepo) GetAll(ctx context.Context) []*domain.Record {
+// PutBlob writes body in chunks under a new blob id and then points the
+// record to them; the trigger on records deletes the chunks of the value it
+// replaces. Only that last write runs in a transaction, so a slow upload
+// does not hold one open. The chunks of a failed upload are deleted.
+// Streamed values are not kept in the history.
+func (p *postgresRepo) PutBlob(ctx context.Context, r *domain.Record, body io.Reader, maxSize int64) (err error) {
+	blob := &domain.Blob{Id: newBlobId(), ChunkSize: blobChunkSize}
+	defer func() {
+		if err != nil {
+			// also when the upload failed because the client went away
+			if cleanupErr := p.db.WithContext(detach(ctx)).Where("blob_id = ?", blob.Id).Delete(&recordChunk{}).Error; cleanupErr != nil {
+				log.Printf("delete chunks of failed upload %s: %v\n", blob.Id, cleanupErr)
+			}
+		}
+	}()
+
+	db := p.db.WithContext(ctx).Session(&gorm.Session{SkipDefaultTransaction: true})
+	hash := sha256.New()
+	buf := make([]byte, blobChunkSize)
+	for seq := 0; ; seq++ {
+		n, err := io.ReadFull(body, buf)
+		if n > 0 {
+			if blob.Size += int64(n); blob.Size > maxSize {
+				return domain.ErrValueTooLarge
+			}
+			hash.Write(buf[:n])
+			if err := db.Create(&recordChunk{BlobId: blob.Id, Seq: seq, Data: buf[:n]}).Error; err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	blob.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	r.Type, r.Value, r.Compression, r.Blob = domain.TypeBytes, "", "", blob
+	model, err := convertToModel(r)
+	if err != nil {
+		return err
+	}
+	err = p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return upsert(tx, model, domain.UserIdFromContext(ctx))
+	})
+	if err != nil {
+		return err
+	}
+	r.Metadata = model.metadata()
+	return nil
+}
+
+// upsertColumns are the columns every write replaces.
+var upsertColumns = []string{
+	"type", "value", "value_json", "value_bytes", "compression", "key_id",
+	"blob_id", "blob_size", "blob_checksum", "blob_chunk_size", "expire_at", "updated_at",
+}
+
+// upsert writes m and reads back the metadata of the record. created_at
+// and last_accessed_at are kept, and so are owner, tags and content_type
+// unless m sets them; new records are owned by the writer. A record that
+// expired but was not deleted yet is replaced as a whole.
+func upsert(tx *gorm.DB, m *record, writer int) error {
+	columns := append([]string(nil), upsertColumns...)
+	kept := []string{"created_at", "last_accessed_at"}
+	if m.Owner != 0 {
+		columns = append(columns, "owner")
+	} else {
+		m.Owner = writer
+		kept = append(kept, "owner")
+	}
+	if m.Tags != nil {
+		columns = append(columns, "tags")
+	} else {
+		kept = append(kept, "tags")
+	}
+	if m.ContentType != "" {
+		columns = append(columns, "content_type")
+	} else {
+		kept = append(kept, "content_type")
+	}
+
+	set := clause.AssignmentColumns(columns)
+	now := time.Now()
+	for _, column := range kept {
+		set = append(set, clause.Assignment{
+			Column: clause.Column{Name: column},
+			Value: gorm.Expr("CASE WHEN records.expire_at > ? AND records.expire_at <= ? "+
+				"THEN excluded."+column+" ELSE records."+column+" END", time.Time{}, now),
+		})
+	}
+
+	return tx.Clauses(
+		clause.OnConflict{Columns: []clause.Column{{Name: "key"}}, DoUpdates: set},
+		clause.Returning{Columns: []clause.Column{
+			{Name: "owner"}, {Name: "tags"}, {Name: "content_type"},
+			{Name: "created_at"}, {Name: "updated_at"}, {Name: "last_accessed_at"},
+		}},
+	).Create(m).Error
+}
+
+func (p *postgresRepo) GetChunk(ctx context.Context, blobId string, seq int) ([]byte, error) {
+	var c recordChunk
+	err := p.db.WithContext(ctx).
+		Where("blob_id = ? AND seq = ?", blobId, seq).
+		Take(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrBlobReplaced
+	}
+	return c.Data, err
+}
+
+func (p *postgresRepo) GetDataKeys(ctx context.Context) ([]*domain.DataKey, error) {
+	var rows []encryptionKey
+	err := p.db.WithContext(ctx).Order("id").Find(&rows).Error
+
+	keys := make([]*domain.DataKey, len(rows))
+	for i, r := range rows {
+		keys[i] = &domain.DataKey{
+			Id:          r.ID,
+			WrappedKey:  r.WrappedKey,
+			MasterKeyId: r.MasterKeyId,
+			CreatedAt:   r.CreatedAt,
+		}
+	}
+	return keys, err
+}
+
+func (p *postgresRepo) AddDataKey(ctx context.Context, key *domain.DataKey) error {
+	row := encryptionKey{
+		WrappedKey:  key.WrappedKey,
+		MasterKeyId: key.MasterKeyId,
+		CreatedAt:   time.Now(),
+	}
+	if err := p.db.WithContext(ctx).Create(&row).Error; err != nil {
+		return err
+	}
+
+	key.Id, key.CreatedAt = row.ID, row.CreatedAt
+	return nil
+}
+
+func (p *postgresRepo) UpdateDataKey(ctx context.Context, key *domain.DataKey) error {
+	return p.db.WithContext(ctx).Model(&encryptionKey{}).
+		Where("id = ?", key.Id).
+		Updates(map[string]any{"wrapped_key": key.WrappedKey, "master_key_id": key.MasterKeyId}).Error
+}
+
+// ReEncrypt locks the rows it rewrites and skips those locked by writers,
+// which store values encrypted with a current key anyway. Expired records
+// are included, since their rows still hold the old key.
+func (p *postgresRepo) ReEncrypt(ctx context.Context, keyId, limit int, fn func(*domain.Record) error) ([]string, int, error) {
+	var keys []string
+	var changed int
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		keys, changed = nil, 0
+		locking := clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}
+
+		var rows []record
+		err := tx.Clauses(locking).
+			Where("key_id <> ?", keyId).
+			Limit(limit).
+			Find(&rows).Error
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			r := &domain.Record{Key: row.Key, Value: row.text(), Type: row.Type, KeyId: *row.KeyId}
+			if err = fn(r); err != nil {
+				return err
+			}
+			value, err := base64.StdEncoding.DecodeString(r.Value)
+			if err != nil {
+				return err
+			}
+			err = tx.Model(&record{}).
+				Where("key = ?", row.Key).
+				UpdateColumns(map[string]any{"value_bytes": value, "key_id": r.KeyId}).Error
+			if err != nil {
+				return err
+			}
+			keys = append(keys, row.Key)
+		}
+
+		if len(rows) == limit {
+			changed = len(rows)
+			return nil
+		}
+		var versions []recordVersion
+		err = tx.Clauses(locking).
+			Where("key_id <> ?", keyId).
+			Limit(limit - len(rows)).
+			Find(&versions).Error
+		if err != nil {
+			return err
+		}
+		for _, v := range versions {
+			r := &domain.Record{Key: v.Key, Value: v.Value, Type: v.Type, KeyId: *v.KeyId}
+			if err = fn(r); err != nil {
+				return err
+			}
+			err = tx.Model(&recordVersion{}).
+				Where("id = ?", v.ID).
+				Updates(map[string]any{"value": r.Value, "key_id": r.KeyId}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		changed = len(rows) + len(versions)
+		return nil
+	})
+	return keys, changed, err
+}
+
+// DeleteDataKeys leaves keys that were superseded only recently, since
+// replicas that have not reloaded their keys yet may still encrypt with
+// them; the foreign keys on the values guard against the rest.
+func (p *postgresRepo) DeleteDataKeys(ctx context.Context, keepId int, supersededBefore time.Time) (int64, error) {
+	res := p.db.WithContext(ctx).Exec(`DELETE FROM encryption_keys k WHERE id <> ? `+
+		`AND EXISTS (SELECT 1 FROM encryption_keys n WHERE n.id > k.id AND n.created_at < ?) `+
+		`AND NOT EXISTS (SELECT 1 FROM records WHERE key_id = k.id) `+
+		`AND NOT EXISTS (SELECT 1 FROM record_versions WHERE key_id = k.id)`,
+		keepId, supersededBefore)
+	return res.RowsAffected, res.Error
+}
+
+func newBlobId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// patchFunctions are the database functions that apply each patch type.
+var patchFunctions = map[domain.PatchType]string{
+	domain.PatchMerge: "jsonb_merge_patch",
+	domain.PatchJson:  "jsonb_patch",
+}
+
+func (p *postgresRepo) PatchJson(ctx context.Context, key string, patchType domain.PatchType, patch string) (*domain.Record, error) {
+	fn, ok := patchFunctions[patchType]
+	if !ok {
+		return nil, fmt.Errorf("unknown patch type %q", patchType)
+	}
+
+	var updated *domain.Record
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rows []record
+		err := tx.Raw(`UPDATE "records" SET value_json = `+fn+`(value_json, ?::jsonb), updated_at = now() `+
+			`WHERE key = ? AND type = ? AND compression = '' AND key_id IS NULL AND (`+notExpired+`) RETURNING *`,
+			patch, key, domain.TypeJson, time.Time{}, time.Now()).
+			Scan(&rows).Error
+		if err != nil {
+			return patchError(err)
+		}
+		if len(rows) == 0 {
+			return notJson(tx, key)
+		}
+
+		updated = rows[0].toRecord()
+		return addVersion(tx, &rows[0], domain.UserIdFromContext(ctx))
+	})
+	return updated, err
+}
+
+// notJson tells why a json operation on key matched no record.
+func notJson(tx *gorm.DB, key string) error {
+	var r record
+	err := tx.Select("type", "compression", "key_id").
+		Where("key = ?", key).
+		Where(notExpired, time.Time{}, time.Now()).
+		Take(&r).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return domain.ErrRecordNotFound
+	case err != nil:
+		return err
+	case r.Type == domain.TypeJson && r.KeyId != nil:
+		return domain.ErrEncrypted
+	case r.Type == domain.TypeJson && r.Compression != "":
+		return domain.ErrCompressed
+	default:
+		return domain.ErrWrongType
+	}
+}
+
+// patchError turns the exceptions raised by the patch functions into
+// domain.ErrPatchFailed.
+func patchError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "P0001" {
+		return fmt.Errorf("%w: %s", domain.ErrPatchFailed, pgErr.Message)
+	}
+	return err
+}
+
+func (p *postgresRepo) GetAll(ctx context.Context, filter *domain.MetadataFilter) []*domain.Record {
+	db := p.db.WithContext(ctx).Where(notExpired, time.Time{}, time.Now())
+	if filter != nil {
+		db = filterMetadata(db, filter)
+	}
+
 	var rows []record
-	p.db.WithContext(ctx).Find(&rows)
+	db.Find(&rows)
 
 	var records []*domain.Record
 	for _, r := range rows {
@@ -51,15 +595,209 @@ func (p *postgresRepo) Delete(ctx context.Context, keys ...string) {
 	p.db.WithContext(ctx).Delete(record{}, keys)
 }
 
-func convertToModel(r *domain.Record) *record {
+// DeleteExpired deletes at most limit records that expired before now,
+// walking the expire_at index, and returns how many were deleted. The
+// expiry is checked again on the deleted rows, since Postgres only
+// rechecks those conditions on rows a concurrent Set or Expire updated.
+func (p *postgresRepo) DeleteExpired(ctx context.Context, now time.Time, limit int) (int64, error) {
+	db := p.db.WithContext(ctx)
+	expired := db.Model(&record{}).
+		Select("key").
+		Where("expire_at > ? AND expire_at <= ?", time.Time{}, now).
+		Limit(limit)
+
+	res := db.Where("key IN (?)", expired).
+		Where("expire_at > ? AND expire_at <= ?", time.Time{}, now).
+		Delete(&record{})
+	return res.RowsAffected, res.Error
+}
+
+// DeleteIfExpired deletes those of keys that expired before now and returns
+// how many were deleted. Keys whose ttl was extended are left alone.
+func (p *postgresRepo) DeleteIfExpired(ctx context.Context, now time.Time, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	res := p.db.WithContext(ctx).
+		Where("key IN ? AND expire_at > ? AND expire_at <= ?", keys, time.Time{}, now).
+		Delete(&record{})
+	return res.RowsAffected, res.Error
+}
+
+// SampleVolatile returns up to limit randomly chosen records that have a ttl.
+// It reads a block sample of the table sized from the planner's row estimate,
+// so its cost does not grow with the table.
+func (p *postgresRepo) SampleVolatile(ctx context.Context, limit int) ([]*domain.Record, error) {
+	db := p.db.WithContext(ctx)
+
+	var estimate float64
+	if err := db.Raw("SELECT reltuples FROM pg_class WHERE relname = ?", "records").Scan(&estimate).Error; err != nil {
+		return nil, err
+	}
+
+	var rows []record
+	err := db.Raw(`SELECT * FROM "records" TABLESAMPLE SYSTEM (?) WHERE expire_at > ? LIMIT ?`,
+		samplePercent(limit, estimate), time.Time{}, limit).
+		Scan(&rows).Error
+
+	var records []*domain.Record
+	for _, r := range rows {
+		records = append(records, r.toRecord())
+	}
+	return records, err
+}
+
+// samplePercent returns the share of table blocks to read to find about
+// limit records, oversampling because only some records have a ttl.
+func samplePercent(limit int, estimate float64) float64 {
+	const oversampling = 10
+	if estimate <= 0 {
+		return 100
+	}
+	pct := 100 * float64(limit*oversampling) / estimate
+	if pct > 100 {
+		return 100
+	}
+	if pct < 0.001 {
+		return 0.001
+	}
+	return pct
+}
+
+func (p *postgresRepo) GetHistory(ctx context.Context, key string) ([]*domain.RecordVersion, error) {
+	var rows []recordVersion
+	err := p.db.WithContext(ctx).
+		Where("key = ?", key).
+		Order("version desc").
+		Find(&rows).Error
+
+	var versions []*domain.RecordVersion
+	for _, v := range rows {
+		versions = append(versions, v.toRecordVersion())
+	}
+	return versions, err
+}
+
+func (p *postgresRepo) GetVersion(ctx context.Context, key string, version int) (*domain.RecordVersion, error) {
+	var v recordVersion
+	err := p.db.WithContext(ctx).
+		Where("key = ? AND version = ?", key, version).
+		First(&v).Error
+	return v.toRecordVersion(), err
+}
+
+func (p *postgresRepo) GetVersionAt(ctx context.Context, key string, at time.Time) (*domain.RecordVersion, error) {
+	var v recordVersion
+	err := p.db.WithContext(ctx).
+		Where("key = ? AND changed_at <= ?", key, at).
+		Order("version desc").
+		First(&v).Error
+	return v.toRecordVersion(), err
+}
+
+// addVersion appends the value of r to its history unless it is unchanged
+// (e.g. only the ttl was updated) or streamed, and prunes versions beyond historyLimit.
+func addVersion(tx *gorm.DB, r *record, changedBy int) error {
+	var last recordVersion
+	err := tx.Where("key = ?", r.Key).
+		Order("version desc").
+		Limit(1).
+		Find(&last).Error
+	if err != nil {
+		return err
+	}
+
+	if r.BlobId != nil {
+		return nil
+	}
+
+	value := r.text()
+	if last.Version > 0 && last.Value == value && last.Type == r.Type && last.Compression == r.Compression &&
+		keyId(last.KeyId) == keyId(r.KeyId) {
+		return nil
+	}
+
+	v := recordVersion{
+		Key:         r.Key,
+		Version:     last.Version + 1,
+		Value:       value,
+		Type:        r.Type,
+		Compression: r.Compression,
+		KeyId:       r.KeyId,
+		ChangedBy:   changedBy,
+		ChangedAt:   time.Now(),
+	}
+	if err = tx.Create(&v).Error; err != nil {
+		return err
+	}
+
+	return tx.Where("key = ? AND version <= ?", r.Key, v.Version-historyLimit).
+		Delete(&recordVersion{}).Error
+}
+
+func convertToModel(r *domain.Record) (*record, error) {
 	var expireAt time.Time
 	if r.Ttl != 0 {
 		expireAt = time.Now().Add(r.Ttl)
 	}
-	return &record{
-		Key:      r.Key,
-		Value:    r.Value,
-		ExpireAt: expireAt,
+	m := &record{
+		Key:         r.Key,
+		Type:        r.Type,
+		Compression: r.Compression,
+		ExpireAt:    expireAt,
+		Owner:       r.Owner,
+		Tags:        r.Tags,
+		ContentType: r.ContentType,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+	if !r.LastAccessedAt.IsZero() {
+		lastAccessedAt := r.LastAccessedAt
+		m.LastAccessedAt = &lastAccessedAt
+	}
+	if r.KeyId != 0 {
+		id := r.KeyId
+		m.KeyId = &id
+	}
+
+	switch {
+	case r.Compression != "" || r.KeyId != 0:
+		b, err := base64.StdEncoding.DecodeString(r.Value)
+		if err != nil {
+			return nil, err
+		}
+		m.ValueBytes = b
+	case r.Blob != nil:
+		m.BlobId = &r.Blob.Id
+		m.BlobSize = r.Blob.Size
+		m.BlobChecksum = r.Blob.Checksum
+		m.BlobChunkSize = r.Blob.ChunkSize
+	case r.Type == domain.TypeString || r.Type == "":
+		m.Type = domain.TypeString
+		m.Value = r.Value
+	case r.Type == domain.TypeBytes:
+		b, err := r.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		m.ValueBytes = b
+	default:
+		value := r.Value
+		m.ValueJson = &value
+	}
+	return m, nil
+}
+
+// text returns the canonical text of the value, whichever column holds it.
+func (r *record) text() string {
+	switch {
+	case r.ValueJson != nil:
+		return *r.ValueJson
+	case r.Type == domain.TypeBytes || r.Compression != "" || r.KeyId != nil:
+		return base64.StdEncoding.EncodeToString(r.ValueBytes)
+	default:
+		return r.Value
 	}
 }
 
@@ -68,9 +806,1075 @@ func (r *record) toRecord() *domain.Record {
 	if !r.ExpireAt.IsZero() {
 		ttl = r.ExpireAt.Sub(time.Now())
 	}
+	t := r.Type
+	if t == "" {
+		t = domain.TypeString
+	}
+	value := r.text()
+	if r.ValueJson != nil {
+		// jsonb does not keep the formatting it was given
+		if v, err := domain.NormalizeValue(t, value); err == nil {
+			value = v
+		}
+	}
+	var blob *domain.Blob
+	if r.BlobId != nil {
+		blob = &domain.Blob{
+			Id:        *r.BlobId,
+			Size:      r.BlobSize,
+			Checksum:  r.BlobChecksum,
+			ChunkSize: r.BlobChunkSize,
+		}
+	}
 	return &domain.Record{
-		Key:   r.Key,
-		Value: r.Value,
-		Ttl:   ttl,
+		Key:         r.Key,
+		Value:       value,
+		Type:        t,
+		Ttl:         ttl,
+		Compression: r.Compression,
+		KeyId:       keyId(r.KeyId),
+		Blob:        blob,
+		Metadata:    r.metadata(),
+	}
+}
+
+func (r *record) metadata() domain.Metadata {
+	m := domain.Metadata{
+		Owner:       r.Owner,
+		Tags:        r.Tags,
+		ContentType: r.ContentType,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+	if r.LastAccessedAt != nil {
+		m.LastAccessedAt = *r.LastAccessedAt
+	}
+	return m
+}
+
+// filterMetadata adds the conditions of filter to a query on records.
+func filterMetadata(db *gorm.DB, filter *domain.MetadataFilter) *gorm.DB {
+	if len(filter.Tags) > 0 {
+		tags, _ := json.Marshal(filter.Tags)
+		db = db.Where("tags @> ?::jsonb", string(tags))
+	}
+	if filter.Owner != 0 {
+		db = db.Where("owner = ?", filter.Owner)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		db = db.Where("created_at > ?", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		db = db.Where("created_at < ?", filter.CreatedBefore)
+	}
+	if !filter.UpdatedAfter.IsZero() {
+		db = db.Where("updated_at > ?", filter.UpdatedAfter)
+	}
+	if !filter.UpdatedBefore.IsZero() {
+		db = db.Where("updated_at < ?", filter.UpdatedBefore)
+	}
+	if !filter.AccessedBefore.IsZero() {
+		db = db.Where("last_accessed_at IS NULL OR last_accessed_at < ?", filter.AccessedBefore)
+	}
+	return db
+}
+
+func (v *recordVersion) toRecordVersion() *domain.RecordVersion {
+	t := v.Type
+	if t == "" {
+		t = domain.TypeString
+	}
+	return &domain.RecordVersion{
+		Key:         v.Key,
+		Value:       v.Value,
+		Type:        t,
+		Compression: v.Compression,
+		KeyId:       keyId(v.KeyId),
+		Version:     v.Version,
+		ChangedBy:   v.ChangedBy,
+		ChangedAt:   v.ChangedAt,
+	}
+}
+
+// keyId returns the data key id of an encrypted value, 0 for plain ones.
+func keyId(id *int) int {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+// TrackAccesses adds the given hits to the access counts of the keys.
+func (p *postgresRepo) TrackAccesses(ctx context.Context, accesses []*domain.RecordAccess) error {
+	if len(accesses) == 0 {
+		return nil
+	}
+
+	rows := make([]recordAccess, len(accesses))
+	keys := map[time.Time][]string{}
+	for i, a := range accesses {
+		rows[i] = recordAccess{Key: a.Key, Hits: a.Hits, LastAccessAt: a.LastAccessAt}
+		keys[a.LastAccessAt] = append(keys[a.LastAccessAt], a.Key)
+	}
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "key"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"hits":           gorm.Expr("record_accesses.hits + excluded.hits"),
+				"last_access_at": gorm.Expr("excluded.last_access_at"),
+			}),
+		}).Create(&rows).Error
+		if err != nil {
+			return err
+		}
+
+		// a read is not an update, so updated_at is left alone
+		for at, keys := range keys {
+			err = tx.Model(&record{}).
+				Where("key IN ?", keys).
+				UpdateColumn("last_accessed_at", at).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (p *postgresRepo) DeleteAccessesBefore(ctx context.Context, before time.Time) (int64, error) {
+	res := p.db.WithContext(ctx).
+		Where("last_access_at < ?", before).
+		Delete(&recordAccess{})
+	return res.RowsAffected, res.Error
+}
+
+func (p *postgresRepo) GetHot(ctx context.Context, since time.Time, limit int) ([]*domain.Record, error) {
+	var rows []record
+	err := p.db.WithContext(ctx).
+		Select("records.*").
+		Joins("JOIN record_accesses ON record_accesses.key = records.key").
+		Where("record_accesses.last_access_at > ?", since).
+		Where(notExpired, time.Time{}, time.Now()).
+		Order("record_accesses.hits DESC").
+		Limit(limit).
+		Find(&rows).Error
+
+	records := make([]*domain.Record, len(rows))
+	for i := range rows {
+		records[i] = rows[i].toRecord()
+	}
+	return records, err
+}
+
+// filterOperators maps query filters to sql operators.
+var filterOperators = map[domain.FilterOp]string{
+	domain.FilterEq:  "=",
+	domain.FilterLt:  "<",
+	domain.FilterLte: "<=",
+	domain.FilterGt:  ">",
+	domain.FilterGte: ">=",
+}
+
+// indexName is the name of the expression index of a record index.
+func indexName(name string) string {
+	return "idx_records_json_" + name
+}
+
+// indexExpr returns the sql expression of an indexed json path. The path
+// is inlined rather than bound, since the planner only uses an expression
+// index for queries that repeat its expression.
+func indexExpr(path string) (string, error) {
+	tokens, err := domain.IndexPathTokens(path)
+	if err != nil {
+		return "", err
+	}
+	return "(value_json #> '{" + strings.Join(tokens, ",") + "}')", nil
+}
+
+// indexPredicate is the condition of the partial indexes of a prefix,
+// inlined for the same reason as indexExpr.
+func indexPredicate(prefix string) string {
+	return "type = 'json' AND starts_with(key, '" + strings.ReplaceAll(prefix, "'", "''") + "')"
+}
+
+// CreateIndex builds the index without blocking writes. That cannot happen
+// inside a transaction, so the definition is stored first and removed
+// again when the index cannot be built.
+func (p *postgresRepo) CreateIndex(ctx context.Context, index *domain.RecordIndex) error {
+	if err := index.Validate(); err != nil {
+		return err
+	}
+	expr, _ := indexExpr(index.Path)
+
+	db := p.db.WithContext(ctx)
+	row := recordIndex{
+		Name:      index.Name,
+		Prefix:    index.Prefix,
+		Path:      index.Path,
+		CreatedAt: time.Now(),
+	}
+	if err := db.Create(&row).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return domain.ErrIndexExists
+		}
+		return err
+	}
+
+	err := db.Exec(fmt.Sprintf("CREATE INDEX CONCURRENTLY IF NOT EXISTS %s ON records (%s) WHERE %s",
+		indexName(index.Name), expr, indexPredicate(index.Prefix))).Error
+	if err != nil {
+		// a failed concurrent build leaves an invalid index behind
+		db.Exec("DROP INDEX CONCURRENTLY IF EXISTS " + indexName(index.Name))
+		db.Delete(&recordIndex{}, "name = ?", index.Name)
+		return err
+	}
+
+	index.CreatedAt = row.CreatedAt
+	return nil
+}
+
+func (p *postgresRepo) DropIndex(ctx context.Context, name string) error {
+	db := p.db.WithContext(ctx)
+
+	var row recordIndex
+	err := db.Where("name = ?", name).Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return domain.ErrIndexNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = db.Exec("DROP INDEX CONCURRENTLY IF EXISTS " + indexName(row.Name)).Error; err != nil {
+		return err
+	}
+	return db.Delete(&recordIndex{}, "name = ?", row.Name).Error
+}
+
+func (p *postgresRepo) GetIndexes(ctx context.Context) ([]*domain.RecordIndex, error) {
+	var rows []recordIndex
+	err := p.db.WithContext(ctx).Order("prefix, path").Find(&rows).Error
+
+	indexes := make([]*domain.RecordIndex, len(rows))
+	for i, r := range rows {
+		indexes[i] = &domain.RecordIndex{
+			Name:      r.Name,
+			Prefix:    r.Prefix,
+			Path:      r.Path,
+			CreatedAt: r.CreatedAt,
+		}
+	}
+	return indexes, err
+}
+
+func (p *postgresRepo) Query(ctx context.Context, query *domain.RecordQuery) ([]*domain.Record, error) {
+	db := p.db.WithContext(ctx).
+		Where(indexPredicate(query.Prefix)).
+		Where(notExpired, time.Time{}, time.Now())
+	db = filterMetadata(db, &query.Metadata)
+
+	for _, f := range query.Filters {
+		expr, err := indexExpr(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		op, ok := filterOperators[f.Op]
+		if !ok {
+			return nil, fmt.Errorf("unknown filter op %q", f.Op)
+		}
+
+		db = db.Where(expr+" "+op+" ?::jsonb", string(f.Value))
+		if f.Op != domain.FilterEq {
+			// jsonb orders values of different types too, e.g. every
+			// boolean after every number
+			db = db.Where("jsonb_typeof("+expr+") = jsonb_typeof(?::jsonb)", string(f.Value))
+		}
+	}
+
+	cmp, dir := ">", "ASC"
+	if query.Desc {
+		cmp, dir = "<", "DESC"
+	}
+	if query.SortBy == "" {
+		if query.After != nil {
+			db = db.Where("key "+cmp+" ?", query.After.Key)
+		}
+		db = db.Order("key " + dir)
+	} else {
+		expr, err := indexExpr(query.SortBy)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where(expr + " IS NOT NULL")
+		if query.After != nil {
+			db = db.Where("("+expr+", key) "+cmp+" (?::jsonb, ?)", string(query.After.Value), query.After.Key)
+		}
+		db = db.Order(expr + " " + dir).Order("key " + dir)
+	}
+
+	var rows []record
+	err := db.Limit(query.Limit).Find(&rows).Error
+
+	records := make([]*domain.Record, len(rows))
+	for i := range rows {
+		records[i] = rows[i].toRecord()
+	}
+	return records, err
+}
+
+// lockCollection locks the live record of key, which must hold a collection
+// of type t, and reports whether there is one. An expired record is deleted
+// along with its elements; a missing one is created when create is set.
+func lockCollection(tx *gorm.DB, key string, t domain.ValueType, create bool, owner int) (bool, error) {
+	var current record
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("key = ?", key).
+		Take(&current).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+	case err != nil:
+		return false, err
+	case !current.ExpireAt.IsZero() && !current.ExpireAt.After(time.Now()):
+		if err = tx.Delete(&current).Error; err != nil {
+			return false, err
+		}
+	case current.Type != t:
+		return false, domain.ErrWrongType
+	default:
+		return true, nil
+	}
+	if !create {
+		return false, nil
+	}
+
+	err = tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&record{Key: key, Type: t, Owner: owner, Tags: tagList{}}).Error
+	if err != nil {
+		return false, err
+	}
+	// a concurrent write may have created the key first
+	return lockCollection(tx, key, t, false, owner)
+}
+
+// updateCollection runs fn while holding the lock of the collection record
+// of key, which is created first when create is set; fn is skipped when
+// there is none. The record is deleted once it has no elements left.
+func (p *postgresRepo) updateCollection(ctx context.Context, key string, t domain.ValueType, create bool, fn func(tx *gorm.DB) error) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ok, err := lockCollection(tx, key, t, create, domain.UserIdFromContext(ctx))
+		if err != nil || !ok {
+			return err
+		}
+		if err = fn(tx); err != nil {
+			return err
+		}
+
+		var exists bool
+		err = tx.Raw("SELECT EXISTS (SELECT 1 FROM "+elementTables[t]+" WHERE key = ?)", key).
+			Scan(&exists).Error
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return tx.Delete(&record{Key: key}).Error
+		}
+		return tx.Model(&record{Key: key}).UpdateColumn("updated_at", time.Now()).Error
+	})
+}
+
+// readCollection runs fn when key holds a live collection of type t.
+func (p *postgresRepo) readCollection(ctx context.Context, key string, t domain.ValueType, fn func(db *gorm.DB) error) error {
+	db := p.db.WithContext(ctx)
+	var current record
+	err := db.Select("type").
+		Where("key = ?", key).
+		Where(notExpired, time.Time{}, time.Now()).
+		Take(&current).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if current.Type != t {
+		return domain.ErrWrongType
+	}
+	return fn(db)
+}
+
+func (p *postgresRepo) HashSet(ctx context.Context, key string, fields map[string]string) (int, error) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rows := make([]recordHashField, len(names))
+	for i, name := range names {
+		rows[i] = recordHashField{Key: key, Field: name, Value: fields[name]}
+	}
+
+	var added int
+	err := p.updateCollection(ctx, key, domain.TypeHash, true, func(tx *gorm.DB) error {
+		var existing int64
+		err := tx.Model(&recordHashField{}).
+			Where("key = ? AND field IN ?", key, names).
+			Count(&existing).Error
+		if err != nil {
+			return err
+		}
+		added = len(rows) - int(existing)
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "key"}, {Name: "field"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value"}),
+		}).Create(&rows).Error
+	})
+	return added, err
+}
+
+func (p *postgresRepo) HashGet(ctx context.Context, key, field string) (string, error) {
+	var rows []recordHashField
+	err := p.readCollection(ctx, key, domain.TypeHash, func(db *gorm.DB) error {
+		return db.Where("key = ? AND field = ?", key, field).Find(&rows).Error
+	})
+	if err == nil && len(rows) == 0 {
+		err = domain.ErrFieldNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return rows[0].Value, nil
+}
+
+func (p *postgresRepo) HashGetAll(ctx context.Context, key string) (map[string]string, error) {
+	var rows []recordHashField
+	err := p.readCollection(ctx, key, domain.TypeHash, func(db *gorm.DB) error {
+		return db.Where("key = ?", key).Find(&rows).Error
+	})
+
+	fields := make(map[string]string, len(rows))
+	for _, r := range rows {
+		fields[r.Field] = r.Value
+	}
+	return fields, err
+}
+
+func (p *postgresRepo) HashDelete(ctx context.Context, key string, fields ...string) (int, error) {
+	var deleted int64
+	err := p.updateCollection(ctx, key, domain.TypeHash, false, func(tx *gorm.DB) error {
+		res := tx.Where("key = ? AND field IN ?", key, fields).Delete(&recordHashField{})
+		deleted = res.RowsAffected
+		return res.Error
+	})
+	return int(deleted), err
+}
+
+// listBounds are the first and last positions of a list, and its length.
+type listBounds struct {
+	First  int64
+	Last   int64
+	Length int
+}
+
+func getListBounds(db *gorm.DB, key string) (listBounds, error) {
+	var b listBounds
+	err := db.Model(&recordListItem{}).
+		Select("coalesce(min(pos), 0) AS first, coalesce(max(pos), -1) AS last, count(*) AS length").
+		Where("key = ?", key).
+		Scan(&b).Error
+	return b, err
+}
+
+func (p *postgresRepo) ListPush(ctx context.Context, key string, end domain.ListEnd, values ...string) (int, error) {
+	var length int
+	err := p.updateCollection(ctx, key, domain.TypeList, true, func(tx *gorm.DB) error {
+		b, err := getListBounds(tx, key)
+		if err != nil {
+			return err
+		}
+
+		items := make([]recordListItem, len(values))
+		for i, v := range values {
+			pos := b.Last + 1 + int64(i)
+			if end == domain.ListLeft {
+				pos = b.First - 1 - int64(i)
+			}
+			items[i] = recordListItem{Key: key, Pos: pos, Value: v}
+		}
+		length = b.Length + len(items)
+		return tx.Create(&items).Error
+	})
+	return length, err
+}
+
+func (p *postgresRepo) ListPop(ctx context.Context, key string, end domain.ListEnd, count int) ([]string, error) {
+	order := "pos"
+	if end == domain.ListRight {
+		order = "pos DESC"
+	}
+
+	var items []recordListItem
+	err := p.updateCollection(ctx, key, domain.TypeList, false, func(tx *gorm.DB) error {
+		err := tx.Where("key = ?", key).Order(order).Limit(count).Find(&items).Error
+		if err != nil || len(items) == 0 {
+			return err
+		}
+		positions := make([]int64, len(items))
+		for i, item := range items {
+			positions[i] = item.Pos
+		}
+		return tx.Where("key = ? AND pos IN ?", key, positions).Delete(&recordListItem{}).Error
+	})
+
+	values := make([]string, len(items))
+	for i, item := range items {
+		values[i] = item.Value
+	}
+	return values, err
+}
+
+// listRange turns the inclusive start and stop indexes of a list or sorted
+// set of length n, negative ones counting from the end, into an offset and
+// a limit.
+func listRange(start, stop, n int) (int, int) {
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop {
+		return 0, 0
+	}
+	return start, stop - start + 1
+}
+
+func (p *postgresRepo) ListRange(ctx context.Context, key string, start, stop int) ([]string, error) {
+	values := []string{}
+	err := p.readCollection(ctx, key, domain.TypeList, func(db *gorm.DB) error {
+		b, err := getListBounds(db, key)
+		if err != nil {
+			return err
+		}
+		offset, limit := listRange(start, stop, b.Length)
+		if limit == 0 {
+			return nil
+		}
+		return db.Model(&recordListItem{}).
+			Where("key = ?", key).
+			Order("pos").
+			Offset(offset).
+			Limit(limit).
+			Pluck("value", &values).Error
+	})
+	return values, err
+}
+
+func (p *postgresRepo) ListLength(ctx context.Context, key string) (int, error) {
+	var b listBounds
+	err := p.readCollection(ctx, key, domain.TypeList, func(db *gorm.DB) (err error) {
+		b, err = getListBounds(db, key)
+		return err
+	})
+	return b.Length, err
+}
+
+func (p *postgresRepo) SetAdd(ctx context.Context, key string, members ...string) (int, error) {
+	rows := make([]recordSetMember, len(members))
+	for i, m := range members {
+		rows[i] = recordSetMember{Key: key, Member: m}
+	}
+
+	var added int64
+	err := p.updateCollection(ctx, key, domain.TypeSet, true, func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows)
+		added = res.RowsAffected
+		return res.Error
+	})
+	return int(added), err
+}
+
+func (p *postgresRepo) SetRemove(ctx context.Context, key string, members ...string) (int, error) {
+	var removed int64
+	err := p.updateCollection(ctx, key, domain.TypeSet, false, func(tx *gorm.DB) error {
+		res := tx.Where("key = ? AND member IN ?", key, members).Delete(&recordSetMember{})
+		removed = res.RowsAffected
+		return res.Error
+	})
+	return int(removed), err
+}
+
+func (p *postgresRepo) SetMembers(ctx context.Context, key string) ([]string, error) {
+	members := []string{}
+	err := p.readCollection(ctx, key, domain.TypeSet, func(db *gorm.DB) error {
+		return db.Model(&recordSetMember{}).
+			Where("key = ?", key).
+			Order("member").
+			Pluck("member", &members).Error
+	})
+	return members, err
+}
+
+func (p *postgresRepo) SetContains(ctx context.Context, key, member string) (bool, error) {
+	var n int64
+	err := p.readCollection(ctx, key, domain.TypeSet, func(db *gorm.DB) error {
+		return db.Model(&recordSetMember{}).
+			Where("key = ? AND member = ?", key, member).
+			Count(&n).Error
+	})
+	return n > 0, err
+}
+
+// zsetOrder orders the members of a sorted set by score, ties broken by
+// member, as the score index does.
+func zsetOrder(desc bool) string {
+	if desc {
+		return "score DESC, member DESC"
+	}
+	return "score, member"
+}
+
+func scoredMembers(rows []recordSortedSetMember) []domain.ScoredMember {
+	members := make([]domain.ScoredMember, len(rows))
+	for i, r := range rows {
+		members[i] = domain.ScoredMember{Member: r.Member, Score: r.Score}
+	}
+	return members
+}
+
+func (p *postgresRepo) ZSetAdd(ctx context.Context, key string, members ...domain.ScoredMember) (int, error) {
+	// the last score given for a member wins, as a single insert cannot
+	// update a row twice
+	scores := make(map[string]float64, len(members))
+	for _, m := range members {
+		scores[m.Member] = m.Score
+	}
+	names := make([]string, 0, len(scores))
+	for name := range scores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rows := make([]recordSortedSetMember, len(names))
+	for i, name := range names {
+		rows[i] = recordSortedSetMember{Key: key, Member: name, Score: scores[name]}
+	}
+
+	var added int
+	err := p.updateCollection(ctx, key, domain.TypeZSet, true, func(tx *gorm.DB) error {
+		var existing int64
+		err := tx.Model(&recordSortedSetMember{}).
+			Where("key = ? AND member IN ?", key, names).
+			Count(&existing).Error
+		if err != nil {
+			return err
+		}
+		added = len(rows) - int(existing)
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "key"}, {Name: "member"}},
+			DoUpdates: clause.AssignmentColumns([]string{"score"}),
+		}).Create(&rows).Error
+	})
+	return added, err
+}
+
+func (p *postgresRepo) ZSetIncr(ctx context.Context, key, member string, by float64) (float64, error) {
+	var score float64
+	err := p.updateCollection(ctx, key, domain.TypeZSet, true, func(tx *gorm.DB) error {
+		return tx.Raw(`INSERT INTO record_sorted_set_members (key, member, score) VALUES (?, ?, ?)
+ON CONFLICT (key, member) DO UPDATE SET score = record_sorted_set_members.score + excluded.score
+RETURNING score`, key, member, by).
+			Scan(&score).Error
+	})
+	return score, err
+}
+
+func (p *postgresRepo) ZSetRank(ctx context.Context, key, member string, desc bool) (domain.ScoredMember, int, error) {
+	var rows []recordSortedSetMember
+	var rank int64
+	err := p.readCollection(ctx, key, domain.TypeZSet, func(db *gorm.DB) error {
+		err := db.Where("key = ? AND member = ?", key, member).Find(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+		cmp := "<"
+		if desc {
+			cmp = ">"
+		}
+		return db.Model(&recordSortedSetMember{}).
+			Where("key = ? AND (score, member) "+cmp+" (?, ?)", key, rows[0].Score, member).
+			Count(&rank).Error
+	})
+	if err == nil && len(rows) == 0 {
+		err = domain.ErrMemberNotFound
+	}
+	if err != nil {
+		return domain.ScoredMember{}, 0, err
+	}
+	return scoredMembers(rows)[0], int(rank), nil
+}
+
+func (p *postgresRepo) ZSetRange(ctx context.Context, key string, start, stop int, desc bool) ([]domain.ScoredMember, error) {
+	var rows []recordSortedSetMember
+	err := p.readCollection(ctx, key, domain.TypeZSet, func(db *gorm.DB) error {
+		var n int64
+		err := db.Model(&recordSortedSetMember{}).Where("key = ?", key).Count(&n).Error
+		if err != nil {
+			return err
+		}
+		offset, limit := listRange(start, stop, int(n))
+		if limit == 0 {
+			return nil
+		}
+		return db.Where("key = ?", key).
+			Order(zsetOrder(desc)).
+			Offset(offset).
+			Limit(limit).
+			Find(&rows).Error
+	})
+	return scoredMembers(rows), err
+}
+
+func (p *postgresRepo) ZSetRangeByScore(ctx context.Context, key string, r domain.ScoreRange) ([]domain.ScoredMember, error) {
+	var rows []recordSortedSetMember
+	err := p.readCollection(ctx, key, domain.TypeZSet, func(db *gorm.DB) error {
+		minCmp, maxCmp := ">=", "<="
+		if r.MinExclusive {
+			minCmp = ">"
+		}
+		if r.MaxExclusive {
+			maxCmp = "<"
+		}
+		db = db.Where("key = ?", key).
+			Where("score "+minCmp+" ?", r.Min).
+			Where("score "+maxCmp+" ?", r.Max).
+			Order(zsetOrder(r.Desc))
+		if r.Offset > 0 {
+			db = db.Offset(r.Offset)
+		}
+		if r.Limit > 0 {
+			db = db.Limit(r.Limit)
+		}
+		return db.Find(&rows).Error
+	})
+	return scoredMembers(rows), err
+}
+
+func (p *postgresRepo) ZSetPop(ctx context.Context, key string, max bool, count int) ([]domain.ScoredMember, error) {
+	var rows []recordSortedSetMember
+	err := p.updateCollection(ctx, key, domain.TypeZSet, false, func(tx *gorm.DB) error {
+		err := tx.Where("key = ?", key).Order(zsetOrder(max)).Limit(count).Find(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+		names := make([]string, len(rows))
+		for i, r := range rows {
+			names[i] = r.Member
+		}
+		return tx.Where("key = ? AND member IN ?", key, names).Delete(&recordSortedSetMember{}).Error
+	})
+	return scoredMembers(rows), err
+}
+
+func (p *postgresRepo) ZSetRemove(ctx context.Context, key string, members ...string) (int, error) {
+	var removed int64
+	err := p.updateCollection(ctx, key, domain.TypeZSet, false, func(tx *gorm.DB) error {
+		res := tx.Where("key = ? AND member IN ?", key, members).Delete(&recordSortedSetMember{})
+		removed = res.RowsAffected
+		return res.Error
+	})
+	return int(removed), err
+}
+
+func (p *postgresRepo) ZSetLength(ctx context.Context, key string) (int, error) {
+	var n int64
+	err := p.readCollection(ctx, key, domain.TypeZSet, func(db *gorm.DB) error {
+		return db.Model(&recordSortedSetMember{}).Where("key = ?", key).Count(&n).Error
+	})
+	return int(n), err
+}
+
+func streamEntries(rows []recordStreamEntry) []domain.StreamEntry {
+	entries := make([]domain.StreamEntry, len(rows))
+	for i := range rows {
+		entries[i] = rows[i].toEntry()
+	}
+	return entries
+}
+
+// nextStreamIds reserves n ids of the stream of key, creating its row, and
+// returns the first one.
+func nextStreamIds(tx *gorm.DB, key string, n int) (int64, error) {
+	var last int64
+	err := tx.Raw(`INSERT INTO record_streams (key, last_id) VALUES (?, ?)
+ON CONFLICT (key) DO UPDATE SET last_id = record_streams.last_id + excluded.last_id
+RETURNING last_id`, key, n).
+		Scan(&last).Error
+	return last - int64(n) + 1, err
+}
+
+// lockStreamGroup locks the group of the stream of key.
+func lockStreamGroup(tx *gorm.DB, key, name string) (*recordStreamGroup, error) {
+	var g recordStreamGroup
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("key = ? AND name = ?", key, name).
+		Take(&g).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrGroupNotFound
+	}
+	return &g, err
+}
+
+func (p *postgresRepo) StreamAdd(ctx context.Context, key string, values ...string) ([]int64, error) {
+	ids := make([]int64, len(values))
+	err := p.updateCollection(ctx, key, domain.TypeStream, true, func(tx *gorm.DB) error {
+		first, err := nextStreamIds(tx, key, len(values))
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		rows := make([]recordStreamEntry, len(values))
+		for i, v := range values {
+			ids[i] = first + int64(i)
+			rows[i] = recordStreamEntry{Key: key, Id: ids[i], Value: v, CreatedAt: now}
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (p *postgresRepo) StreamRead(ctx context.Context, key string, after int64, count int) ([]domain.StreamEntry, error) {
+	var rows []recordStreamEntry
+	err := p.readCollection(ctx, key, domain.TypeStream, func(db *gorm.DB) error {
+		return db.Where("key = ? AND id > ?", key, after).
+			Order("id").
+			Limit(count).
+			Find(&rows).Error
+	})
+	return streamEntries(rows), err
+}
+
+func (p *postgresRepo) StreamCreateGroup(ctx context.Context, key, group string, after int64) error {
+	return p.updateCollection(ctx, key, domain.TypeStream, true, func(tx *gorm.DB) error {
+		var last int64
+		err := tx.Raw(`INSERT INTO record_streams (key) VALUES (?)
+ON CONFLICT (key) DO UPDATE SET last_id = record_streams.last_id
+RETURNING last_id`, key).
+			Scan(&last).Error
+		if err != nil {
+			return err
+		}
+		if after == domain.StreamLatest {
+			after = last
+		}
+
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&recordStreamGroup{Key: key, Name: group, LastDeliveredId: after})
+		if res.Error == nil && res.RowsAffected == 0 {
+			return domain.ErrGroupExists
+		}
+		return res.Error
+	})
+}
+
+func (p *postgresRepo) StreamDeleteGroup(ctx context.Context, key, group string) error {
+	var deleted int64
+	err := p.updateCollection(ctx, key, domain.TypeStream, false, func(tx *gorm.DB) error {
+		res := tx.Where("key = ? AND name = ?", key, group).Delete(&recordStreamGroup{})
+		deleted = res.RowsAffected
+		return res.Error
+	})
+	if err == nil && deleted == 0 {
+		err = domain.ErrGroupNotFound
+	}
+	return err
+}
+
+func (p *postgresRepo) StreamReadGroup(ctx context.Context, key, group, consumer string, count int) ([]domain.StreamEntry, error) {
+	var rows []recordStreamEntry
+	found := false
+	err := p.updateCollection(ctx, key, domain.TypeStream, false, func(tx *gorm.DB) error {
+		g, err := lockStreamGroup(tx, key, group)
+		if err != nil {
+			return err
+		}
+		found = true
+
+		err = tx.Where("key = ? AND id > ?", key, g.LastDeliveredId).
+			Order("id").
+			Limit(count).
+			Find(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+
+		now := time.Now()
+		pending := make([]recordStreamPending, len(rows))
+		for i, r := range rows {
+			pending[i] = recordStreamPending{Key: key, GroupName: group, EntryId: r.Id, Consumer: consumer, DeliveredAt: now, Deliveries: 1}
+		}
+		if err = tx.Create(&pending).Error; err != nil {
+			return err
+		}
+		return tx.Model(g).UpdateColumn("last_delivered_id", rows[len(rows)-1].Id).Error
+	})
+	if err == nil && !found {
+		err = domain.ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return streamEntries(rows), nil
+}
+
+func (p *postgresRepo) StreamAck(ctx context.Context, key, group string, ids ...int64) (int, error) {
+	var acked int64
+	err := p.updateCollection(ctx, key, domain.TypeStream, false, func(tx *gorm.DB) error {
+		res := tx.Where("key = ? AND group_name = ? AND entry_id IN ?", key, group, ids).
+			Delete(&recordStreamPending{})
+		acked = res.RowsAffected
+		return res.Error
+	})
+	return int(acked), err
+}
+
+func (p *postgresRepo) StreamPending(ctx context.Context, key, group string, count int) ([]domain.PendingEntry, error) {
+	var rows []recordStreamPending
+	found := false
+	err := p.readCollection(ctx, key, domain.TypeStream, func(db *gorm.DB) error {
+		var groups int64
+		err := db.Model(&recordStreamGroup{}).Where("key = ? AND name = ?", key, group).Count(&groups).Error
+		if err != nil || groups == 0 {
+			return err
+		}
+		found = true
+		return db.Where("key = ? AND group_name = ?", key, group).
+			Order("entry_id").
+			Limit(count).
+			Find(&rows).Error
+	})
+	if err == nil && !found {
+		err = domain.ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]domain.PendingEntry, len(rows))
+	for i, r := range rows {
+		pending[i] = domain.PendingEntry{Id: r.EntryId, Consumer: r.Consumer, DeliveredAt: r.DeliveredAt, Deliveries: r.Deliveries}
+	}
+	return pending, nil
+}
+
+func (p *postgresRepo) StreamClaim(ctx context.Context, key, group, consumer string, minIdle time.Duration, count int) ([]domain.StreamEntry, error) {
+	var rows []recordStreamEntry
+	found := false
+	err := p.updateCollection(ctx, key, domain.TypeStream, false, func(tx *gorm.DB) error {
+		if _, err := lockStreamGroup(tx, key, group); err != nil {
+			return err
+		}
+		found = true
+
+		now := time.Now()
+		var ids []int64
+		err := tx.Model(&recordStreamPending{}).
+			Where("key = ? AND group_name = ? AND delivered_at <= ?", key, group, now.Add(-minIdle)).
+			Order("entry_id").
+			Limit(count).
+			Pluck("entry_id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		err = tx.Model(&recordStreamPending{}).
+			Where("key = ? AND group_name = ? AND entry_id IN ?", key, group, ids).
+			Updates(map[string]interface{}{
+				"consumer":     consumer,
+				"delivered_at": now,
+				"deliveries":   gorm.Expr("deliveries + 1"),
+			}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("key = ? AND id IN ?", key, ids).Order("id").Find(&rows).Error
+	})
+	if err == nil && !found {
+		err = domain.ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return streamEntries(rows), nil
+}
+
+func (p *postgresRepo) StreamTrim(ctx context.Context, key string, maxLength int, before time.Time) (int, error) {
+	var trimmed int64
+	err := p.updateCollection(ctx, key, domain.TypeStream, false, func(tx *gorm.DB) error {
+		if !before.IsZero() {
+			res := tx.Where("key = ? AND created_at < ?", key, before).Delete(&recordStreamEntry{})
+			if res.Error != nil {
+				return res.Error
+			}
+			trimmed += res.RowsAffected
+		}
+		if maxLength > 0 {
+			// the newest entry that does not fit
+			oldest := tx.Model(&recordStreamEntry{}).
+				Select("id").
+				Where("key = ?", key).
+				Order("id DESC").
+				Offset(maxLength).
+				Limit(1)
+			res := tx.Where("key = ? AND id <= (?)", key, oldest).Delete(&recordStreamEntry{})
+			if res.Error != nil {
+				return res.Error
+			}
+			trimmed += res.RowsAffected
+		}
+		return nil
+	})
+	return int(trimmed), err
+}
+
+// ExpireCollection only touches the record row: collections have no value
+// to write and are not kept in the history.
+func (p *postgresRepo) ExpireCollection(ctx context.Context, key string, ttl time.Duration) (*domain.Record, error) {
+	var current record
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("key = ?", key).
+			Where(notExpired, time.Time{}, time.Now()).
+			Take(&current).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return domain.ErrRecordNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !current.Type.IsCollection() {
+			return domain.ErrWrongType
+		}
+
+		var expireAt time.Time
+		if ttl != 0 {
+			expireAt = time.Now().Add(ttl)
+		}
+		return tx.Model(&current).Update("expire_at", expireAt).Error
+	})
+	if err != nil {
+		return nil, err
 	}
+	return current.toRecord(), nil
 }
@@ -1,11 +1,17 @@
 package record
 
 import (
+	"bytes"
 	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"math"
 	"storage/domain"
 	"time"
 
@@ -19,35 +25,127 @@ func initDB() (sqlmock.Sqlmock, error, *postgresRepo) {
 	return mock, err, &repo
 }
 
+// upsertQuery matches the write of a record, which keeps the metadata left
+// unset unless the row it replaces has expired.
+const upsertQuery = `INSERT INTO "records" .* ON CONFLICT \("key"\) DO UPDATE SET .*` +
+	`"created_at"=CASE WHEN records.expire_at > \$\d+ AND records.expire_at <= \$\d+ THEN excluded.created_at ELSE records.created_at END.* RETURNING`
+
+// upsertArgs are the arguments of the upsert of a record without
+// metadata, given its columns from type to expire_at.
+func upsertArgs(key string, columns ...driver.Value) []driver.Value {
+	args := append([]driver.Value{key}, columns...)
+	args = append(args, 0, "[]", "", sqlmock.AnyArg(), sqlmock.AnyArg(), nil)
+	// created_at, last_accessed_at, owner, tags and content_type are kept
+	for i := 0; i < 5; i++ {
+		args = append(args, time.Time{}, sqlmock.AnyArg())
+	}
+	return args
+}
+
+func upsertRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"owner", "tags", "content_type", "created_at", "updated_at", "last_accessed_at"}).
+		AddRow(0, "[]", "", time.Now(), time.Now(), nil)
+}
+
 func TestPostgresRepo_Set(t *testing.T) {
 	r := &domain.Record{
 		Key:   "key",
 		Value: "val",
+		Type:  domain.TypeString,
 		Ttl:   0,
 	}
-	model := convertToModel(r)
+	model, err := convertToModel(r)
+	assert.NoError(t, err)
 
 	mock, err, repo := initDB()
 	assert.NoError(t, err)
 
 	mock.ExpectBegin()
-	query := `UPDATE "records" SET`
-	mock.ExpectExec(query).
-		WithArgs(model.Value, model.ExpireAt, model.Key).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(upsertQuery).
+		WithArgs(upsertArgs(model.Key, model.Type, model.Value, nil, []byte(nil), domain.Compression(""), nil, nil, int64(0), "", 0, model.ExpireAt)...).
+		WillReturnRows(upsertRows())
+	mock.ExpectQuery(`SELECT \* FROM "record_versions"`).
+		WithArgs(model.Key).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "key", "version", "value"}).AddRow(1, model.Key, 1, "old"))
+	mock.ExpectQuery(`INSERT INTO "record_versions"`).
+		WithArgs(model.Key, 2, model.Value, model.Type, domain.Compression(""), nil, 0, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectExec(`DELETE FROM "record_versions"`).
+		WithArgs(model.Key, 2-historyLimit).
+		WillReturnResult(sqlmock.NewResult(0, 0))
 	mock.ExpectCommit()
 
 	err = repo.Set(context.TODO(), r)
 	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_Set_unchangedValue(t *testing.T) {
+	r := &domain.Record{
+		Key:   "key",
+		Value: "val",
+		Ttl:   time.Hour,
+	}
+
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(upsertQuery).
+		WillReturnRows(upsertRows())
+	mock.ExpectQuery(`SELECT \* FROM "record_versions"`).
+		WithArgs(r.Key).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "key", "version", "value", "type"}).AddRow(1, r.Key, 1, r.Value, domain.TypeString))
+	mock.ExpectCommit()
+
+	err = repo.Set(context.TODO(), r)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_Set_metadata(t *testing.T) {
+	createdAt := time.Now().Add(-time.Hour)
+	r := &domain.Record{Key: "report", Value: "a,b", Type: domain.TypeString, Metadata: domain.Metadata{
+		Owner:       5,
+		Tags:        []string{"csv", "daily"},
+		ContentType: "text/csv",
+	}}
+
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "records" .* ON CONFLICT \("key"\) DO UPDATE SET .*"owner"="excluded"."owner","tags"="excluded"."tags","content_type"="excluded"."content_type",`+
+		`"created_at"=CASE .* END,"last_accessed_at"=CASE .* END RETURNING`).
+		WithArgs("report", domain.TypeString, "a,b", nil, []byte(nil), domain.Compression(""), nil, nil, int64(0), "", 0, time.Time{},
+			5, `["csv","daily"]`, "text/csv", sqlmock.AnyArg(), sqlmock.AnyArg(), nil,
+			time.Time{}, sqlmock.AnyArg(), time.Time{}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"owner", "tags", "content_type", "created_at", "updated_at", "last_accessed_at"}).
+			AddRow(5, `["csv", "daily"]`, "text/csv", createdAt, time.Now(), createdAt))
+	mock.ExpectQuery(`SELECT \* FROM "record_versions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`INSERT INTO "record_versions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(`DELETE FROM "record_versions"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.NoError(t, repo.Set(context.TODO(), r))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []string{"csv", "daily"}, r.Tags)
+	assert.Equal(t, createdAt, r.CreatedAt, "the creation time of the replaced value is kept")
+	assert.Equal(t, createdAt, r.LastAccessedAt)
 }
 
 func TestPostgresRepo_Get(t *testing.T) {
 	r := &domain.Record{
 		Key:   "key",
 		Value: "val",
+		Type:  domain.TypeString,
 		Ttl:   0,
 	}
-	model := convertToModel(r)
+	model, err := convertToModel(r)
+	assert.NoError(t, err)
 
 	mock, err, repo := initDB()
 	assert.NoError(t, err)
@@ -55,8 +153,8 @@ func TestPostgresRepo_Get(t *testing.T) {
 	rows := sqlmock.NewRows([]string{"key", "value", "expire_at"}).
 		AddRow(model.Key, model.Value, model.ExpireAt)
 
-	query := `SELECT \* FROM "records"`
-	mock.ExpectQuery(query).WithArgs(model.Key).WillReturnRows(rows)
+	query := `SELECT \* FROM "records" WHERE key = \$1 AND \(expire_at = \$2 OR expire_at > \$3\)`
+	mock.ExpectQuery(query).WithArgs(model.Key, time.Time{}, sqlmock.AnyArg()).WillReturnRows(rows)
 	mock.ExpectCommit()
 
 	actual, err := repo.Get(context.TODO(), model.Key)
@@ -64,11 +162,315 @@ func TestPostgresRepo_Get(t *testing.T) {
 	assert.Equal(t, actual, r)
 }
 
+func TestPostgresRepo_Get_typed(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	rows := sqlmock.NewRows([]string{"key", "type", "value", "value_json", "value_bytes", "expire_at"}).
+		AddRow("doc", domain.TypeJson, "", `{"a": [1, 2]}`, nil, time.Time{}).
+		AddRow("blob", domain.TypeBytes, "", nil, []byte{0, 1, 2}, time.Time{})
+	mock.ExpectQuery(`SELECT \* FROM "records"`).WillReturnRows(rows)
+
+	records := repo.GetAll(context.TODO(), nil)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, &domain.Record{Key: "doc", Value: `{"a":[1,2]}`, Type: domain.TypeJson}, records[0])
+		assert.Equal(t, &domain.Record{Key: "blob", Value: "AAEC", Type: domain.TypeBytes}, records[1])
+	}
+}
+
+func TestPostgresRepo_Set_typed(t *testing.T) {
+	r := &domain.Record{Key: "counter", Value: "42", Type: domain.TypeInt}
+
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(upsertQuery).
+		WithArgs(upsertArgs("counter", domain.TypeInt, "", "42", []byte(nil), domain.Compression(""), nil, nil, int64(0), "", 0, time.Time{})...).
+		WillReturnRows(upsertRows())
+	mock.ExpectQuery(`SELECT \* FROM "record_versions"`).
+		WithArgs("counter").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "key", "version", "value", "type"}).AddRow(1, "counter", 1, "42", domain.TypeString))
+	mock.ExpectQuery(`INSERT INTO "record_versions"`).
+		WithArgs("counter", 2, "42", domain.TypeInt, domain.Compression(""), nil, 0, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectExec(`DELETE FROM "record_versions"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.NoError(t, repo.Set(context.TODO(), r))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_Set_compressed(t *testing.T) {
+	r := &domain.Record{Key: "doc", Value: "AAEC", Type: domain.TypeJson, Compression: domain.CompressionZstd}
+
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(upsertQuery).
+		WithArgs(upsertArgs("doc", domain.TypeJson, "", nil, []byte{0, 1, 2}, domain.CompressionZstd, nil, nil, int64(0), "", 0, time.Time{})...).
+		WillReturnRows(upsertRows())
+	mock.ExpectQuery(`SELECT \* FROM "record_versions"`).
+		WithArgs("doc").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`INSERT INTO "record_versions"`).
+		WithArgs("doc", 1, "AAEC", domain.TypeJson, domain.CompressionZstd, nil, 0, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(`DELETE FROM "record_versions"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.NoError(t, repo.Set(context.TODO(), r))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	r.Metadata = domain.Metadata{}
+
+	rows := sqlmock.NewRows([]string{"key", "type", "value_bytes", "compression", "expire_at"}).
+		AddRow("doc", domain.TypeJson, []byte{0, 1, 2}, domain.CompressionZstd, time.Time{})
+	mock.ExpectQuery(`SELECT \* FROM "records"`).WillReturnRows(rows)
+
+	got, err := repo.Get(context.TODO(), "doc")
+	assert.NoError(t, err)
+	assert.Equal(t, r, got)
+}
+
+func TestPostgresRepo_Set_encrypted(t *testing.T) {
+	r := &domain.Record{Key: "secret", Value: "AAEC", Type: domain.TypeInt, KeyId: 3}
+
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(upsertQuery).
+		WithArgs(upsertArgs("secret", domain.TypeInt, "", nil, []byte{0, 1, 2}, domain.Compression(""), 3, nil, int64(0), "", 0, time.Time{})...).
+		WillReturnRows(upsertRows())
+	mock.ExpectQuery(`SELECT \* FROM "record_versions"`).
+		WithArgs("secret").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`INSERT INTO "record_versions"`).
+		WithArgs("secret", 1, "AAEC", domain.TypeInt, domain.Compression(""), 3, 0, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(`DELETE FROM "record_versions"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.NoError(t, repo.Set(context.TODO(), r))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	r.Metadata = domain.Metadata{}
+
+	rows := sqlmock.NewRows([]string{"key", "type", "value_bytes", "key_id", "expire_at"}).
+		AddRow("secret", domain.TypeInt, []byte{0, 1, 2}, 3, time.Time{})
+	mock.ExpectQuery(`SELECT \* FROM "records"`).WillReturnRows(rows)
+
+	got, err := repo.Get(context.TODO(), "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, r, got)
+}
+
+func TestPostgresRepo_AddDataKey(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "encryption_keys" \("wrapped_key","master_key_id","created_at"\) VALUES \(\$1,\$2,\$3\) RETURNING "id"`).
+		WithArgs([]byte{1, 2}, "m1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectCommit()
+
+	key := &domain.DataKey{WrappedKey: []byte{1, 2}, MasterKeyId: "m1"}
+	assert.NoError(t, repo.AddDataKey(context.TODO(), key))
+	assert.Equal(t, 4, key.Id)
+	assert.False(t, key.CreatedAt.IsZero())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_ReEncrypt(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "records" WHERE key_id <> \$1 LIMIT 2 FOR UPDATE SKIP LOCKED`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "type", "value_bytes", "key_id"}).
+			AddRow("a", domain.TypeString, []byte{1}, 1))
+	mock.ExpectExec(`UPDATE "records" SET "key_id"=\$1,"value_bytes"=\$2 WHERE key = \$3`).
+		WithArgs(2, []byte{2}, "a").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "record_versions" WHERE key_id <> \$1 LIMIT 1 FOR UPDATE SKIP LOCKED`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "key", "value", "type", "key_id"}).
+			AddRow(7, "a", "AQ==", domain.TypeString, 1))
+	mock.ExpectExec(`UPDATE "record_versions" SET "key_id"=\$1,"value"=\$2 WHERE id = \$3`).
+		WithArgs(2, "Ag==", 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var seen []*domain.Record
+	keys, n, err := repo.ReEncrypt(context.TODO(), 2, 2, func(r *domain.Record) error {
+		seen = append(seen, &domain.Record{Key: r.Key, Value: r.Value, KeyId: r.KeyId})
+		r.Value, r.KeyId = "Ag==", 2
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, keys)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []*domain.Record{{Key: "a", Value: "AQ==", KeyId: 1}, {Key: "a", Value: "AQ==", KeyId: 1}}, seen)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_Update(t *testing.T) {
+	expireAt := time.Now().Add(time.Hour)
+
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "records" WHERE key = \$1 AND \(expire_at = \$2 OR expire_at > \$3\) ORDER BY "records"."key" LIMIT 1 FOR UPDATE`).
+		WithArgs("counter", time.Time{}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "type", "value_json", "expire_at", "owner", "tags"}).AddRow("counter", domain.TypeInt, "41", expireAt, 7, `["hits"]`))
+	mock.ExpectExec(`UPDATE "records" SET`).
+		WithArgs(domain.TypeInt, "", "42", []byte(nil), domain.Compression(""), nil, nil, int64(0), "", 0, expireAt, 7, `["hits"]`, "", sqlmock.AnyArg(), "counter").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`SELECT \* FROM "record_versions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "key", "version", "value", "type"}).AddRow(1, "counter", 1, "41", domain.TypeInt))
+	mock.ExpectQuery(`INSERT INTO "record_versions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectExec(`DELETE FROM "record_versions"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	r, err := repo.Update(context.TODO(), "counter", func(r *domain.Record) error {
+		return r.Incr(1)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "42", r.Value)
+	assert.Equal(t, 7, r.Owner)
+	assert.Equal(t, []string{"hits"}, r.Tags)
+	assert.False(t, r.UpdatedAt.IsZero())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_Update_wrongType(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "records"`).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "type", "value"}).AddRow("name", domain.TypeString, "bob"))
+	mock.ExpectRollback()
+
+	_, err = repo.Update(context.TODO(), "name", func(r *domain.Record) error {
+		return r.Incr(1)
+	})
+	assert.ErrorIs(t, err, domain.ErrWrongType)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_PatchJson(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE "records" SET value_json = jsonb_merge_patch\(value_json, \$1::jsonb\), updated_at = now\(\) `+
+		`WHERE key = \$2 AND type = \$3 AND compression = '' AND key_id IS NULL AND \(expire_at = \$4 OR expire_at > \$5\) RETURNING \*`).
+		WithArgs(`{"b":2}`, "doc", domain.TypeJson, time.Time{}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "type", "value_json", "expire_at"}).
+			AddRow("doc", domain.TypeJson, `{"a": 1, "b": 2}`, time.Time{}))
+	mock.ExpectQuery(`SELECT \* FROM "record_versions"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "key", "version", "value", "type"}).AddRow(1, "doc", 1, `{"a":1}`, domain.TypeJson))
+	mock.ExpectQuery(`INSERT INTO "record_versions"`).
+		WithArgs("doc", 2, `{"a": 1, "b": 2}`, domain.TypeJson, domain.Compression(""), nil, 0, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectExec(`DELETE FROM "record_versions"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	r, err := repo.PatchJson(context.TODO(), "doc", domain.PatchMerge, `{"b":2}`)
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.Record{Key: "doc", Value: `{"a":1,"b":2}`, Type: domain.TypeJson}, r)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_PatchJson_notJson(t *testing.T) {
+	tests := []struct {
+		name string
+		rows *sqlmock.Rows
+		want error
+	}{
+		{"missing", sqlmock.NewRows([]string{"type", "compression", "key_id"}), domain.ErrRecordNotFound},
+		{"wrong type", sqlmock.NewRows([]string{"type", "compression", "key_id"}).AddRow(domain.TypeString, "", nil), domain.ErrWrongType},
+		{"compressed", sqlmock.NewRows([]string{"type", "compression", "key_id"}).AddRow(domain.TypeJson, domain.CompressionZstd, nil), domain.ErrCompressed},
+		{"encrypted", sqlmock.NewRows([]string{"type", "compression", "key_id"}).AddRow(domain.TypeJson, "", 1), domain.ErrEncrypted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, err, repo := initDB()
+			assert.NoError(t, err)
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`UPDATE "records" SET value_json = jsonb_patch`).
+				WillReturnRows(sqlmock.NewRows([]string{"key"}))
+			mock.ExpectQuery(`SELECT "type","compression","key_id" FROM "records" WHERE key = \$1`).
+				WithArgs("doc", time.Time{}, sqlmock.AnyArg()).
+				WillReturnRows(tt.rows)
+			mock.ExpectRollback()
+
+			_, err = repo.PatchJson(context.TODO(), "doc", domain.PatchJson, `[]`)
+			assert.ErrorIs(t, err, tt.want)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestPostgresRepo_PatchJson_failed(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE "records" SET value_json = jsonb_patch`).
+		WillReturnError(&pgconn.PgError{Code: "P0001", Message: `path "/a" does not exist`})
+	mock.ExpectRollback()
+
+	_, err = repo.PatchJson(context.TODO(), "doc", domain.PatchJson, `[{"op":"remove","path":"/a"}]`)
+	assert.ErrorIs(t, err, domain.ErrPatchFailed)
+	assert.EqualError(t, err, `patch cannot be applied: path "/a" does not exist`)
+}
+
+func TestPostgresRepo_Get_notFound(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	query := `SELECT \* FROM "records" WHERE key = \$1`
+	mock.ExpectQuery(query).WithArgs("missing", time.Time{}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "value", "expire_at"}))
+
+	_, err = repo.Get(context.TODO(), "missing")
+	assert.ErrorIs(t, err, domain.ErrRecordNotFound)
+}
+
+func TestPostgresRepo_GetAll_filtered(t *testing.T) {
+	before := time.Now().Add(-24 * time.Hour)
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectQuery(`SELECT \* FROM "records" WHERE \(expire_at = \$1 OR expire_at > \$2\) AND tags @> \$3::jsonb AND owner = \$4 `+
+		`AND \(last_accessed_at IS NULL OR last_accessed_at < \$5\)`).
+		WithArgs(time.Time{}, sqlmock.AnyArg(), `["a","b"]`, 2, before).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "value", "owner", "tags"}).AddRow("k", "v", 2, `["a", "b", "c"]`))
+
+	result := repo.GetAll(context.TODO(), &domain.MetadataFilter{Tags: []string{"a", "b"}, Owner: 2, AccessedBefore: before})
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Len(t, result, 1)
+	assert.Equal(t, []string{"a", "b", "c"}, result[0].Tags)
+}
+
 func TestPostgresRepo_GetAll(t *testing.T) {
 	records := []*domain.Record{
 		{
 			Key:   "key",
 			Value: "val",
+			Type:  domain.TypeString,
 			Ttl:   0,
 		},
 		{
@@ -78,8 +480,8 @@ func TestPostgresRepo_GetAll(t *testing.T) {
 		},
 	}
 
-	firstRow := convertToModel(records[0])
-	secondRow := convertToModel(records[1])
+	firstRow, _ := convertToModel(records[0])
+	secondRow, _ := convertToModel(records[1])
 	rows := sqlmock.NewRows([]string{"key", "value", "expire_at"}).
 		AddRow(firstRow.Key, firstRow.Value, firstRow.ExpireAt).
 		AddRow(secondRow.Key, secondRow.Value, secondRow.ExpireAt)
@@ -87,10 +489,10 @@ func TestPostgresRepo_GetAll(t *testing.T) {
 	mock, err, repo := initDB()
 	assert.NoError(t, err)
 
-	query := `SELECT \* FROM "records"`
-	mock.ExpectQuery(query).WithArgs().WillReturnRows(rows)
+	query := `SELECT \* FROM "records" WHERE expire_at = \$1 OR expire_at > \$2`
+	mock.ExpectQuery(query).WithArgs(time.Time{}, sqlmock.AnyArg()).WillReturnRows(rows)
 
-	result := repo.GetAll(context.TODO())
+	result := repo.GetAll(context.TODO(), nil)
 	assert.Equal(t, *records[0], *result[0])
 
 	assert.Equal(t, records[1].Key, result[1].Key)
@@ -111,3 +513,769 @@ func TestPostgresRepo_Delete(t *testing.T) {
 
 	repo.Delete(context.TODO(), keys...)
 }
+
+func TestPostgresRepo_DeleteExpired(t *testing.T) {
+	now := time.Now()
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	// the outer condition keeps rows whose ttl was extended meanwhile
+	query := `DELETE FROM "records" WHERE key IN \(SELECT "key" FROM "records" WHERE expire_at > \$1 AND expire_at <= \$2 LIMIT 100\) ` +
+		`AND \(expire_at > \$3 AND expire_at <= \$4\)$`
+	mock.ExpectExec(query).
+		WithArgs(time.Time{}, now, time.Time{}, now).
+		WillReturnResult(sqlmock.NewResult(0, 42))
+	mock.ExpectCommit()
+
+	n, err := repo.DeleteExpired(context.TODO(), now, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_DeleteIfExpired(t *testing.T) {
+	now := time.Now()
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	query := `DELETE FROM "records" WHERE key IN \(\$1,\$2\) AND expire_at > \$3 AND expire_at <= \$4`
+	mock.ExpectExec(query).
+		WithArgs("a", "b", time.Time{}, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := repo.DeleteIfExpired(context.TODO(), now, "a", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_SampleVolatile(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	expireAt := time.Now().Add(-time.Minute)
+	mock.ExpectQuery(`SELECT reltuples FROM pg_class`).
+		WithArgs("records").
+		WillReturnRows(sqlmock.NewRows([]string{"reltuples"}).AddRow(1000000))
+	mock.ExpectQuery(`SELECT \* FROM "records" TABLESAMPLE SYSTEM \(\$1\) WHERE expire_at > \$2 LIMIT \$3`).
+		WithArgs(0.02, time.Time{}, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "value", "expire_at"}).AddRow("key", "val", expireAt))
+
+	records, err := repo.SampleVolatile(context.TODO(), 20)
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.True(t, records[0].IsExpired())
+	}
+}
+
+func Test_samplePercent(t *testing.T) {
+	assert.Equal(t, 100.0, samplePercent(20, -1))
+	assert.Equal(t, 100.0, samplePercent(20, 50))
+	assert.Equal(t, 0.02, samplePercent(20, 1000000))
+	assert.Equal(t, 0.001, samplePercent(20, 1e12))
+}
+
+func TestPostgresRepo_TrackAccesses(t *testing.T) {
+	now := time.Now()
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "record_accesses" \("key","hits","last_access_at"\) VALUES \(\$1,\$2,\$3\) ON CONFLICT \("key"\) DO UPDATE SET "hits"=record_accesses.hits \+ excluded.hits,"last_access_at"=excluded.last_access_at`).
+		WithArgs("key", int64(3), now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "records" SET "last_accessed_at"=\$1 WHERE key IN \(\$2\)`).
+		WithArgs(now, "key").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.TrackAccesses(context.TODO(), []*domain.RecordAccess{{Key: "key", Hits: 3, LastAccessAt: now}})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_DeleteAccessesBefore(t *testing.T) {
+	before := time.Now()
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "record_accesses" WHERE last_access_at < \$1`).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 5))
+	mock.ExpectCommit()
+
+	n, err := repo.DeleteAccessesBefore(context.TODO(), before)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_GetHot(t *testing.T) {
+	since := time.Now().Add(-time.Hour)
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	query := `SELECT records.\* FROM "records" JOIN record_accesses ON record_accesses.key = records.key ` +
+		`WHERE record_accesses.last_access_at > \$1 AND \(expire_at = \$2 OR expire_at > \$3\) ` +
+		`ORDER BY record_accesses.hits DESC LIMIT 10`
+	mock.ExpectQuery(query).
+		WithArgs(since, time.Time{}, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "value", "expire_at"}).AddRow("key", "val", time.Time{}))
+
+	records, err := repo.GetHot(context.TODO(), since, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []*domain.Record{{Key: "key", Value: "val", Type: domain.TypeString}}, records)
+}
+
+func TestPostgresRepo_GetHistory(t *testing.T) {
+	changedAt := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "key", "version", "value", "changed_by", "changed_at"}).
+		AddRow(2, "key", 2, "val2", 7, changedAt).
+		AddRow(1, "key", 1, "val1", 7, changedAt)
+
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectQuery(`SELECT \* FROM "record_versions" WHERE key = \$1 ORDER BY version desc`).
+		WithArgs("key").
+		WillReturnRows(rows)
+
+	versions, err := repo.GetHistory(context.TODO(), "key")
+	assert.NoError(t, err)
+	if assert.Len(t, versions, 2) {
+		assert.Equal(t, &domain.RecordVersion{
+			Key:       "key",
+			Value:     "val2",
+			Type:      domain.TypeString,
+			Version:   2,
+			ChangedBy: 7,
+			ChangedAt: changedAt,
+		}, versions[0])
+		assert.Equal(t, 1, versions[1].Version)
+	}
+}
+
+func TestPostgresRepo_GetVersionAt(t *testing.T) {
+	at := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "key", "version", "value"}).
+		AddRow(1, "key", 3, "val")
+
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectQuery(`SELECT \* FROM "record_versions" WHERE key = \$1 AND changed_at <= \$2 ORDER BY version desc`).
+		WithArgs("key", at).
+		WillReturnRows(rows)
+
+	v, err := repo.GetVersionAt(context.TODO(), "key", at)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, v.Version)
+	assert.Equal(t, "val", v.Value)
+}
+
+func TestPostgresRepo_CreateIndex(t *testing.T) {
+	index := &domain.RecordIndex{Name: "session_user", Prefix: "session:", Path: "/user/id"}
+
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "record_indexes"`).
+		WithArgs("session_user", "session:", "/user/id", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_records_json_session_user ` +
+		`ON records \(\(value_json #> '{user,id}'\)\) WHERE type = 'json' AND starts_with\(key, 'session:'\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, repo.CreateIndex(context.TODO(), index))
+	assert.False(t, index.CreatedAt.IsZero())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_CreateIndex_invalid(t *testing.T) {
+	_, err, repo := initDB()
+	assert.NoError(t, err)
+
+	err = repo.CreateIndex(context.TODO(), &domain.RecordIndex{Name: "x", Prefix: "a'", Path: "/a"})
+	assert.Error(t, err)
+	err = repo.CreateIndex(context.TODO(), &domain.RecordIndex{Name: "x", Prefix: "a", Path: "/a'b"})
+	assert.Error(t, err)
+}
+
+func TestPostgresRepo_DropIndex(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectQuery(`SELECT \* FROM "record_indexes" WHERE name = \$1`).
+		WithArgs("session_user").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "prefix", "path"}).AddRow("session_user", "session:", "/user_id"))
+	mock.ExpectExec(`DROP INDEX CONCURRENTLY IF EXISTS idx_records_json_session_user`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "record_indexes" WHERE name = \$1`).
+		WithArgs("session_user").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, repo.DropIndex(context.TODO(), "session_user"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_DropIndex_notFound(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectQuery(`SELECT \* FROM "record_indexes"`).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}))
+
+	assert.ErrorIs(t, repo.DropIndex(context.TODO(), "missing"), domain.ErrIndexNotFound)
+}
+
+func TestPostgresRepo_Query(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	query := `SELECT \* FROM "records" WHERE \(type = 'json' AND starts_with\(key, 'session:'\)\) ` +
+		`AND \(expire_at = \$1 OR expire_at > \$2\) ` +
+		`AND \(value_json #> '{user_id}'\) = \$3::jsonb ` +
+		`AND \(value_json #> '{age}'\) >= \$4::jsonb AND jsonb_typeof\(\(value_json #> '{age}'\)\) = jsonb_typeof\(\$5::jsonb\) ` +
+		`AND \(value_json #> '{age}'\) IS NOT NULL ` +
+		`AND \(\(value_json #> '{age}'\), key\) < \(\$6::jsonb, \$7\) ` +
+		`ORDER BY \(value_json #> '{age}'\) DESC,key DESC LIMIT 11`
+	mock.ExpectQuery(query).
+		WithArgs(time.Time{}, sqlmock.AnyArg(), "42", "18", "18", "30", "session:b").
+		WillReturnRows(sqlmock.NewRows([]string{"key", "type", "value_json", "expire_at"}).
+			AddRow("session:a", domain.TypeJson, `{"age": 20, "user_id": 42}`, time.Time{}))
+
+	records, err := repo.Query(context.TODO(), &domain.RecordQuery{
+		Prefix: "session:",
+		Filters: []domain.QueryFilter{
+			{Path: "/user_id", Op: domain.FilterEq, Value: json.RawMessage("42")},
+			{Path: "/age", Op: domain.FilterGte, Value: json.RawMessage("18")},
+		},
+		SortBy: "/age",
+		Desc:   true,
+		Limit:  11,
+		After:  &domain.QueryCursor{Key: "session:b", Value: json.RawMessage("30")},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*domain.Record{{Key: "session:a", Value: `{"age":20,"user_id":42}`, Type: domain.TypeJson}}, records)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_PutBlob(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	// the chunks are written before the transaction that points the record to them
+	mock.ExpectExec(`INSERT INTO "record_chunks" \("blob_id","seq","data"\)`).
+		WithArgs(sqlmock.AnyArg(), 0, []byte{0, 1, 2}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectQuery(upsertQuery).
+		WithArgs(upsertArgs("blob", domain.TypeBytes, "", nil, []byte(nil), domain.Compression(""), nil, sqlmock.AnyArg(), int64(3),
+			"ae4b3280e56e2faf83f414a6e3dabe9d5fbe18976544c05fed121accb85b53fc", blobChunkSize, time.Time{})...).
+		WillReturnRows(upsertRows())
+	mock.ExpectCommit()
+
+	r := &domain.Record{Key: "blob"}
+	err = repo.PutBlob(context.TODO(), r, bytes.NewReader([]byte{0, 1, 2}), 10)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	if assert.NotNil(t, r.Blob) {
+		assert.Equal(t, domain.TypeBytes, r.Type)
+		assert.Equal(t, int64(3), r.Blob.Size)
+		assert.Len(t, r.Blob.Id, 32)
+	}
+}
+
+func TestPostgresRepo_PutBlob_failed(t *testing.T) {
+	deleteChunks := `DELETE FROM "record_chunks" WHERE blob_id = \$1`
+
+	t.Run("too large", func(t *testing.T) {
+		mock, err, repo := initDB()
+		assert.NoError(t, err)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(deleteChunks).WithArgs(sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err = repo.PutBlob(context.TODO(), &domain.Record{Key: "blob"}, bytes.NewReader([]byte{0, 1, 2}), 2)
+		assert.ErrorIs(t, err, domain.ErrValueTooLarge)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("record write fails", func(t *testing.T) {
+		mock, err, repo := initDB()
+		assert.NoError(t, err)
+
+		var blobId string
+		mock.ExpectExec(`INSERT INTO "record_chunks"`).
+			WithArgs(sqlmock.AnyArg(), 0, []byte{0, 1, 2}).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectBegin()
+		mock.ExpectQuery(upsertQuery).WillReturnError(errors.New("connection reset"))
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectExec(deleteChunks).
+			WithArgs(blobIdArg{&blobId}).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		r := &domain.Record{Key: "blob"}
+		err = repo.PutBlob(context.TODO(), r, bytes.NewReader([]byte{0, 1, 2}), 10)
+		assert.Error(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+		assert.Equal(t, r.Blob.Id, blobId, "the chunks of the upload are deleted")
+	})
+}
+
+// blobIdArg matches any blob id and keeps it.
+type blobIdArg struct {
+	id *string
+}
+
+func (a blobIdArg) Match(v driver.Value) bool {
+	id, ok := v.(string)
+	*a.id = id
+	return ok
+}
+
+func TestPostgresRepo_GetChunk(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	query := `SELECT \* FROM "record_chunks" WHERE blob_id = \$1 AND seq = \$2 LIMIT 1`
+	mock.ExpectQuery(query).
+		WithArgs("b1", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"blob_id", "seq", "data"}).AddRow("b1", 1, []byte("abc")))
+	mock.ExpectQuery(query).
+		WithArgs("b1", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"blob_id", "seq", "data"}))
+
+	data, err := repo.GetChunk(context.TODO(), "b1", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("abc"), data)
+
+	_, err = repo.GetChunk(context.TODO(), "b1", 2)
+	assert.ErrorIs(t, err, domain.ErrBlobReplaced)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func collectionRows(t domain.ValueType) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"key", "type", "expire_at"}).AddRow("key", t, time.Time{})
+}
+
+func TestPostgresRepo_HashSet(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	lock := `SELECT \* FROM "records" WHERE key = \$1 .*FOR UPDATE`
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WithArgs("key").WillReturnRows(sqlmock.NewRows([]string{"key"}))
+	mock.ExpectExec(`INSERT INTO "records" .* ON CONFLICT DO NOTHING`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(lock).WithArgs("key").WillReturnRows(collectionRows(domain.TypeHash))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "record_hash_fields" WHERE key = \$1 AND field IN \(\$2,\$3\)`).
+		WithArgs("key", "a", "b").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec(`INSERT INTO "record_hash_fields" \("key","field","value"\) VALUES \(\$1,\$2,\$3\),\(\$4,\$5,\$6\) `+
+		`ON CONFLICT \("key","field"\) DO UPDATE SET "value"="excluded"."value"`).
+		WithArgs("key", "a", "1", "key", "b", "2").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM record_hash_fields WHERE key = \$1\)`).
+		WithArgs("key").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE "records" SET "updated_at"=\$1 WHERE "key" = \$2`).
+		WithArgs(sqlmock.AnyArg(), "key").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := repo.HashSet(context.TODO(), "key", map[string]string{"b": "2", "a": "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_HashSet_wrongType(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "records" WHERE key = \$1 .*FOR UPDATE`).
+		WillReturnRows(collectionRows(domain.TypeString))
+	mock.ExpectRollback()
+
+	_, err = repo.HashSet(context.TODO(), "key", map[string]string{"a": "1"})
+	assert.ErrorIs(t, err, domain.ErrWrongType)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_HashGet_missing(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectQuery(`SELECT "type" FROM "records" WHERE key = \$1 AND \(expire_at = \$2 OR expire_at > \$3\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"type"}).AddRow(domain.TypeHash))
+	mock.ExpectQuery(`SELECT \* FROM "record_hash_fields" WHERE key = \$1 AND field = \$2`).
+		WithArgs("key", "a").
+		WillReturnRows(sqlmock.NewRows([]string{"key", "field", "value"}))
+
+	_, err = repo.HashGet(context.TODO(), "key", "a")
+	assert.ErrorIs(t, err, domain.ErrFieldNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_ListPush(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "records" WHERE key = \$1 .*FOR UPDATE`).
+		WillReturnRows(collectionRows(domain.TypeList))
+	mock.ExpectQuery(`SELECT coalesce\(min\(pos\), 0\) AS first, coalesce\(max\(pos\), -1\) AS last, count\(\*\) AS length FROM "record_list_items" WHERE key = \$1`).
+		WithArgs("key").
+		WillReturnRows(sqlmock.NewRows([]string{"first", "last", "length"}).AddRow(-1, 2, 4))
+	mock.ExpectExec(`INSERT INTO "record_list_items" \("key","pos","value"\)`).
+		WithArgs("key", -2, "a", "key", -3, "b").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(`SELECT EXISTS`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE "records" SET "updated_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := repo.ListPush(context.TODO(), "key", domain.ListLeft, "a", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, 6, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_ListPop(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "records" WHERE key = \$1 .*FOR UPDATE`).
+		WillReturnRows(collectionRows(domain.TypeList))
+	mock.ExpectQuery(`SELECT \* FROM "record_list_items" WHERE key = \$1 ORDER BY pos DESC LIMIT 5`).
+		WithArgs("key").
+		WillReturnRows(sqlmock.NewRows([]string{"key", "pos", "value"}).AddRow("key", 1, "b").AddRow("key", 0, "a"))
+	mock.ExpectExec(`DELETE FROM "record_list_items" WHERE key = \$1 AND pos IN \(\$2,\$3\)`).
+		WithArgs("key", 1, 0).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(`SELECT EXISTS`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(`DELETE FROM "records" WHERE "records"."key" = \$1`).
+		WithArgs("key").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	values, err := repo.ListPop(context.TODO(), "key", domain.ListRight, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "a"}, values)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_listRange(t *testing.T) {
+	tests := []struct {
+		start, stop, n int
+		offset, limit  int
+	}{
+		{0, -1, 5, 0, 5},
+		{1, 2, 5, 1, 2},
+		{-2, -1, 5, 3, 2},
+		{-10, 10, 5, 0, 5},
+		{3, 1, 5, 0, 0},
+		{0, -1, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		offset, limit := listRange(tt.start, tt.stop, tt.n)
+		assert.Equal(t, tt.offset, offset, "%+v", tt)
+		assert.Equal(t, tt.limit, limit, "%+v", tt)
+	}
+}
+
+func TestPostgresRepo_SetAdd(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "records" WHERE key = \$1 .*FOR UPDATE`).
+		WillReturnRows(collectionRows(domain.TypeSet))
+	mock.ExpectExec(`INSERT INTO "record_set_members" \("key","member"\) VALUES \(\$1,\$2\),\(\$3,\$4\) ON CONFLICT DO NOTHING`).
+		WithArgs("key", "a", "key", "b").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT EXISTS`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE "records" SET "updated_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := repo.SetAdd(context.TODO(), "key", "a", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n, "a was a member already")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_ExpireCollection(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "records" WHERE key = \$1 AND \(expire_at = \$2 OR expire_at > \$3\) .*FOR UPDATE`).
+		WillReturnRows(collectionRows(domain.TypeSet))
+	mock.ExpectExec(`UPDATE "records" SET "expire_at"=\$1,"updated_at"=\$2 WHERE "key" = \$3`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "key").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	r, err := repo.ExpireCollection(context.TODO(), "key", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.TypeSet, r.Type)
+	assert.InDelta(t, time.Minute, r.Ttl, float64(time.Second))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_ZSetIncr(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "records" WHERE key = \$1 .*FOR UPDATE`).
+		WillReturnRows(collectionRows(domain.TypeZSet))
+	mock.ExpectQuery(`INSERT INTO record_sorted_set_members \(key, member, score\) VALUES \(\$1, \$2, \$3\)\s+`+
+		`ON CONFLICT \(key, member\) DO UPDATE SET score = record_sorted_set_members.score \+ excluded.score\s+RETURNING score`).
+		WithArgs("key", "alice", 2.5).
+		WillReturnRows(sqlmock.NewRows([]string{"score"}).AddRow(12.5))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM record_sorted_set_members WHERE key = \$1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE "records" SET "updated_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	score, err := repo.ZSetIncr(context.TODO(), "key", "alice", 2.5)
+	assert.NoError(t, err)
+	assert.Equal(t, 12.5, score)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_ZSetRank(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectQuery(`SELECT "type" FROM "records"`).
+		WillReturnRows(sqlmock.NewRows([]string{"type"}).AddRow(domain.TypeZSet))
+	mock.ExpectQuery(`SELECT \* FROM "record_sorted_set_members" WHERE key = \$1 AND member = \$2`).
+		WithArgs("key", "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"key", "member", "score"}).AddRow("key", "alice", 10))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "record_sorted_set_members" WHERE key = \$1 AND \(score, member\) > \(\$2, \$3\)`).
+		WithArgs("key", 10.0, "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	m, rank, err := repo.ZSetRank(context.TODO(), "key", "alice", true)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.ScoredMember{Member: "alice", Score: 10}, m)
+	assert.Equal(t, 3, rank)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_ZSetRank_missing(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectQuery(`SELECT "type" FROM "records"`).
+		WillReturnRows(sqlmock.NewRows([]string{"type"}))
+
+	_, _, err = repo.ZSetRank(context.TODO(), "key", "alice", false)
+	assert.ErrorIs(t, err, domain.ErrMemberNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_ZSetRangeByScore(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectQuery(`SELECT "type" FROM "records"`).
+		WillReturnRows(sqlmock.NewRows([]string{"type"}).AddRow(domain.TypeZSet))
+	mock.ExpectQuery(`SELECT \* FROM "record_sorted_set_members" WHERE key = \$1 AND score > \$2 AND score <= \$3 `+
+		`ORDER BY score DESC, member DESC LIMIT 2 OFFSET 1`).
+		WithArgs("key", 10.0, math.Inf(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "member", "score"}).AddRow("key", "bob", 30).AddRow("key", "alice", 20))
+
+	members, err := repo.ZSetRangeByScore(context.TODO(), "key", domain.ScoreRange{
+		Min: 10, MinExclusive: true, Max: math.Inf(1), Desc: true, Offset: 1, Limit: 2,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.ScoredMember{{Member: "bob", Score: 30}, {Member: "alice", Score: 20}}, members)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_ZSetPop(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "records" WHERE key = \$1 .*FOR UPDATE`).
+		WillReturnRows(collectionRows(domain.TypeZSet))
+	mock.ExpectQuery(`SELECT \* FROM "record_sorted_set_members" WHERE key = \$1 ORDER BY score, member LIMIT 1`).
+		WithArgs("key").
+		WillReturnRows(sqlmock.NewRows([]string{"key", "member", "score"}).AddRow("key", "alice", 1))
+	mock.ExpectExec(`DELETE FROM "record_sorted_set_members" WHERE key = \$1 AND member IN \(\$2\)`).
+		WithArgs("key", "alice").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT EXISTS`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE "records" SET "updated_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	members, err := repo.ZSetPop(context.TODO(), "key", false, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.ScoredMember{{Member: "alice", Score: 1}}, members)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_StreamAdd(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "records" WHERE key = \$1 .*FOR UPDATE`).
+		WillReturnRows(collectionRows(domain.TypeStream))
+	mock.ExpectQuery(`INSERT INTO record_streams \(key, last_id\) VALUES \(\$1, \$2\)\s+`+
+		`ON CONFLICT \(key\) DO UPDATE SET last_id = record_streams.last_id \+ excluded.last_id\s+RETURNING last_id`).
+		WithArgs("jobs", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"last_id"}).AddRow(7))
+	mock.ExpectExec(`INSERT INTO "record_stream_entries" \("key","id","value","created_at"\)`).
+		WithArgs("jobs", 6, "a", sqlmock.AnyArg(), "jobs", 7, "b", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM record_streams WHERE key = \$1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE "records" SET "updated_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ids, err := repo.StreamAdd(context.TODO(), "jobs", "a", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{6, 7}, ids)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_StreamReadGroup(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "records" WHERE key = \$1 .*FOR UPDATE`).
+		WillReturnRows(collectionRows(domain.TypeStream))
+	mock.ExpectQuery(`SELECT \* FROM "record_stream_groups" WHERE key = \$1 AND name = \$2 LIMIT 1 FOR UPDATE`).
+		WithArgs("jobs", "workers").
+		WillReturnRows(sqlmock.NewRows([]string{"key", "name", "last_delivered_id"}).AddRow("jobs", "workers", 3))
+	mock.ExpectQuery(`SELECT \* FROM "record_stream_entries" WHERE key = \$1 AND id > \$2 ORDER BY id LIMIT 2`).
+		WithArgs("jobs", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "id", "value", "created_at"}).
+			AddRow("jobs", 4, "a", time.Now()).
+			AddRow("jobs", 5, "b", time.Now()))
+	mock.ExpectExec(`INSERT INTO "record_stream_pending" \("key","group_name","entry_id","consumer","delivered_at","deliveries"\)`).
+		WithArgs("jobs", "workers", 4, "w1", sqlmock.AnyArg(), 1, "jobs", "workers", 5, "w1", sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`UPDATE "record_stream_groups" SET "last_delivered_id"=\$1 WHERE "key" = \$2 AND "name" = \$3`).
+		WithArgs(5, "jobs", "workers").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT EXISTS`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE "records" SET "updated_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	entries, err := repo.StreamReadGroup(context.TODO(), "jobs", "workers", "w1", 2)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, int64(4), entries[0].Id)
+		assert.Equal(t, "b", entries[1].Value)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_StreamReadGroup_missing(t *testing.T) {
+	t.Run("missing stream", func(t *testing.T) {
+		mock, err, repo := initDB()
+		assert.NoError(t, err)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT \* FROM "records" WHERE key = \$1 .*FOR UPDATE`).
+			WillReturnRows(sqlmock.NewRows([]string{"key"}))
+		mock.ExpectCommit()
+
+		_, err = repo.StreamReadGroup(context.TODO(), "jobs", "workers", "w1", 1)
+		assert.ErrorIs(t, err, domain.ErrGroupNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("missing group", func(t *testing.T) {
+		mock, err, repo := initDB()
+		assert.NoError(t, err)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT \* FROM "records" WHERE key = \$1 .*FOR UPDATE`).
+			WillReturnRows(collectionRows(domain.TypeStream))
+		mock.ExpectQuery(`SELECT \* FROM "record_stream_groups"`).
+			WillReturnRows(sqlmock.NewRows([]string{"key", "name", "last_delivered_id"}))
+		mock.ExpectRollback()
+
+		_, err = repo.StreamReadGroup(context.TODO(), "jobs", "workers", "w1", 1)
+		assert.ErrorIs(t, err, domain.ErrGroupNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPostgresRepo_StreamClaim(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "records" WHERE key = \$1 .*FOR UPDATE`).
+		WillReturnRows(collectionRows(domain.TypeStream))
+	mock.ExpectQuery(`SELECT \* FROM "record_stream_groups"`).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "name", "last_delivered_id"}).AddRow("jobs", "workers", 5))
+	mock.ExpectQuery(`SELECT "entry_id" FROM "record_stream_pending" WHERE key = \$1 AND group_name = \$2 AND delivered_at <= \$3 ORDER BY entry_id LIMIT 10`).
+		WithArgs("jobs", "workers", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"entry_id"}).AddRow(4))
+	mock.ExpectExec(`UPDATE "record_stream_pending" SET "consumer"=\$1,"delivered_at"=\$2,"deliveries"=deliveries \+ 1 `+
+		`WHERE key = \$3 AND group_name = \$4 AND entry_id IN \(\$5\)`).
+		WithArgs("w2", sqlmock.AnyArg(), "jobs", "workers", 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "record_stream_entries" WHERE key = \$1 AND id IN \(\$2\) ORDER BY id`).
+		WithArgs("jobs", 4).
+		WillReturnRows(sqlmock.NewRows([]string{"key", "id", "value", "created_at"}).AddRow("jobs", 4, "a", time.Now()))
+	mock.ExpectQuery(`SELECT EXISTS`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE "records" SET "updated_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	entries, err := repo.StreamClaim(context.TODO(), "jobs", "workers", "w2", time.Minute, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "a", entries[0].Value)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresRepo_StreamTrim(t *testing.T) {
+	mock, err, repo := initDB()
+	assert.NoError(t, err)
+
+	before := time.Now().Add(-time.Hour)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "records" WHERE key = \$1 .*FOR UPDATE`).
+		WillReturnRows(collectionRows(domain.TypeStream))
+	mock.ExpectExec(`DELETE FROM "record_stream_entries" WHERE key = \$1 AND created_at < \$2`).
+		WithArgs("jobs", before).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`DELETE FROM "record_stream_entries" WHERE key = \$1 AND id <= \(SELECT "id" FROM "record_stream_entries" `+
+		`WHERE key = \$2 ORDER BY id DESC LIMIT 1 OFFSET 100\)`).
+		WithArgs("jobs", "jobs").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectQuery(`SELECT EXISTS`).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE "records" SET "updated_at"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := repo.StreamTrim(context.TODO(), "jobs", 100, before)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
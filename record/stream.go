@@ -0,0 +1,255 @@
+package record
+
+import (
+	"context"
+	"errors"
+	"storage/domain"
+	"sync"
+	"time"
+)
+
+// streamWaiters wakes the reads blocked on a stream once entries are added
+// to it, on this replica or, through the invalidation bus, on another one.
+type streamWaiters struct {
+	mu      sync.Mutex
+	waiting map[string]*streamWaiter
+	stopped chan struct{}
+	stop    sync.Once
+}
+
+type streamWaiter struct {
+	woken chan struct{}
+	reads int
+}
+
+func newStreamWaiters() *streamWaiters {
+	return &streamWaiters{waiting: map[string]*streamWaiter{}, stopped: make(chan struct{})}
+}
+
+// wait returns a channel closed by the next notify of key, and a function
+// to call once the read stops waiting.
+func (w *streamWaiters) wait(key string) (<-chan struct{}, func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	waiter, ok := w.waiting[key]
+	if !ok {
+		waiter = &streamWaiter{woken: make(chan struct{})}
+		w.waiting[key] = waiter
+	}
+	waiter.reads++
+	return waiter.woken, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if waiter.reads--; waiter.reads == 0 && w.waiting[key] == waiter {
+			delete(w.waiting, key)
+		}
+	}
+}
+
+// notify wakes the reads waiting for keys, every one when there are none.
+func (w *streamWaiters) notify(keys ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(keys) == 0 {
+		for key, waiter := range w.waiting {
+			close(waiter.woken)
+			delete(w.waiting, key)
+		}
+		return
+	}
+	for _, key := range keys {
+		if waiter, ok := w.waiting[key]; ok {
+			close(waiter.woken)
+			delete(w.waiting, key)
+		}
+	}
+}
+
+// StreamWaiter is implemented by record services whose stream reads can
+// block.
+type StreamWaiter interface {
+	StopWaiting()
+}
+
+// StopWaiting ends the blocked stream reads, which return what they found
+// so far, and keeps later reads from blocking, so that shutdown does not
+// wait for them.
+func (s *service) StopWaiting() {
+	s.streams.stop.Do(func() { close(s.streams.stopped) })
+}
+
+// block runs read until it returns entries or block elapses, capped by
+// StreamMaxBlock. Reads are retried when entries are added to key and
+// every StreamPollInterval, in case the addition went unnoticed.
+func (s *service) block(ctx context.Context, key string, block time.Duration, read func() ([]domain.StreamEntry, error)) ([]domain.StreamEntry, error) {
+	if block > s.config.StreamMaxBlock {
+		block = s.config.StreamMaxBlock
+	}
+	select {
+	case <-s.streams.stopped:
+		block = 0
+	default:
+	}
+	deadline := time.Now().Add(block)
+
+	for {
+		// registered before reading, so that an addition in between is not missed
+		woken, done := s.streams.wait(key)
+		entries, err := read()
+		if err != nil || len(entries) > 0 {
+			done()
+			return entries, err
+		}
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			done()
+			return entries, nil
+		}
+		if wait > s.config.StreamPollInterval {
+			wait = s.config.StreamPollInterval
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-woken:
+		case <-timer.C:
+		case <-s.streams.stopped:
+			deadline = time.Now()
+		case <-ctx.Done():
+			timer.Stop()
+			done()
+			return nil, ctx.Err()
+		}
+		timer.Stop()
+		done()
+	}
+}
+
+func (s *service) StreamAdd(ctx context.Context, key string, values ...string) (_ []int64, err error) {
+	ctx, span := tracer.Start(ctx, "record.StreamAdd", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
+
+	if err = s.checkElements(key, values); err != nil {
+		return nil, err
+	}
+	ids, err := s.repo.StreamAdd(ctx, key, values...)
+	if err != nil {
+		return nil, err
+	}
+	s.collectionChanged(ctx, key)
+	s.streams.notify(key)
+	return ids, nil
+}
+
+func (s *service) StreamRead(ctx context.Context, key string, after int64, count int, block time.Duration) ([]domain.StreamEntry, error) {
+	if count < 1 {
+		return nil, errors.New("count must be positive")
+	}
+	return s.block(ctx, key, block, func() ([]domain.StreamEntry, error) {
+		return s.repo.StreamRead(ctx, key, after, count)
+	})
+}
+
+func (s *service) StreamCreateGroup(ctx context.Context, key, group string, after int64) (err error) {
+	ctx, span := tracer.Start(ctx, "record.StreamCreateGroup", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
+
+	if group == "" {
+		return errors.New("group name is required")
+	}
+	if after < 0 && after != domain.StreamLatest {
+		return errors.New("invalid start id")
+	}
+	if s.encrypts(key) {
+		return domain.ErrEncrypted
+	}
+	if err = s.repo.StreamCreateGroup(ctx, key, group, after); err != nil {
+		return err
+	}
+	s.collectionChanged(ctx, key)
+	return nil
+}
+
+func (s *service) StreamDeleteGroup(ctx context.Context, key, group string) (err error) {
+	ctx, span := tracer.Start(ctx, "record.StreamDeleteGroup", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
+
+	if err = s.repo.StreamDeleteGroup(ctx, key, group); err != nil {
+		return err
+	}
+	s.collectionChanged(ctx, key)
+	return nil
+}
+
+func (s *service) StreamReadGroup(ctx context.Context, key, group, consumer string, count int, block time.Duration) (_ []domain.StreamEntry, err error) {
+	ctx, span := tracer.Start(ctx, "record.StreamReadGroup", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
+
+	if consumer == "" {
+		return nil, errors.New("consumer is required")
+	}
+	if count < 1 {
+		return nil, errors.New("count must be positive")
+	}
+	entries, err := s.block(ctx, key, block, func() ([]domain.StreamEntry, error) {
+		return s.repo.StreamReadGroup(ctx, key, group, consumer, count)
+	})
+	if len(entries) > 0 {
+		s.collectionChanged(ctx, key)
+	}
+	return entries, err
+}
+
+func (s *service) StreamAck(ctx context.Context, key, group string, ids ...int64) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "record.StreamAck", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
+
+	if len(ids) == 0 {
+		return 0, errors.New("no ids given")
+	}
+	acked, err := s.repo.StreamAck(ctx, key, group, ids...)
+	if acked > 0 {
+		s.collectionChanged(ctx, key)
+	}
+	return acked, err
+}
+
+func (s *service) StreamPending(ctx context.Context, key, group string, count int) ([]domain.PendingEntry, error) {
+	if count < 1 {
+		return nil, errors.New("count must be positive")
+	}
+	return s.repo.StreamPending(ctx, key, group, count)
+}
+
+func (s *service) StreamClaim(ctx context.Context, key, group, consumer string, minIdle time.Duration, count int) (_ []domain.StreamEntry, err error) {
+	ctx, span := tracer.Start(ctx, "record.StreamClaim", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
+
+	if consumer == "" {
+		return nil, errors.New("consumer is required")
+	}
+	if minIdle < 0 || count < 1 {
+		return nil, errors.New("min idle must not be negative and count must be positive")
+	}
+	entries, err := s.repo.StreamClaim(ctx, key, group, consumer, minIdle, count)
+	if len(entries) > 0 {
+		s.collectionChanged(ctx, key)
+	}
+	return entries, err
+}
+
+func (s *service) StreamTrim(ctx context.Context, key string, maxLength int, before time.Time) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "record.StreamTrim", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
+
+	if maxLength < 0 || (maxLength == 0 && before.IsZero()) {
+		return 0, errors.New("a positive max length or a time is required")
+	}
+	trimmed, err := s.repo.StreamTrim(ctx, key, maxLength, before)
+	if trimmed > 0 {
+		s.collectionChanged(ctx, key)
+	}
+	return trimmed, err
+}
@@ -0,0 +1,142 @@
+package record
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"storage/domain"
+	"storage/domain/mocks"
+	"testing"
+	"time"
+)
+
+func Test_streamWaiters(t *testing.T) {
+	w := newStreamWaiters()
+
+	woken, done := w.wait("jobs")
+	other, _ := w.wait("other")
+	w.notify("jobs")
+	assert.True(t, isClosed(woken))
+	assert.False(t, isClosed(other))
+	done()
+
+	w.notify()
+	assert.True(t, isClosed(other))
+	assert.Empty(t, w.waiting)
+}
+
+func isClosed(c <-chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+func Test_service_StreamRead_block(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+	entries := []domain.StreamEntry{{Id: 1, Value: "a"}}
+	repo.On("StreamRead", mock.Anything, "jobs", int64(0), 10).Return([]domain.StreamEntry{}, nil).Once()
+	repo.On("StreamRead", mock.Anything, "jobs", int64(0), 10).Return(entries, nil).Once()
+	repo.On("StreamAdd", mock.Anything, "jobs", []string{"a"}).Return([]int64{1}, nil).Once()
+
+	config := DefaultConfig()
+	config.StreamPollInterval = time.Minute
+	s := NewRecordService(repo, config).(*service)
+	defer s.Close()
+
+	read := make(chan []domain.StreamEntry)
+	go func() {
+		got, err := s.StreamRead(context.TODO(), "jobs", 0, 10, 10*time.Second)
+		assert.NoError(t, err)
+		read <- got
+	}()
+
+	assert.Eventually(t, func() bool {
+		s.streams.mu.Lock()
+		defer s.streams.mu.Unlock()
+		return s.streams.waiting["jobs"] != nil
+	}, time.Second, time.Millisecond)
+	_, err := s.StreamAdd(context.TODO(), "jobs", "a")
+	assert.NoError(t, err)
+
+	select {
+	case got := <-read:
+		assert.Equal(t, entries, got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the read was not woken by the addition")
+	}
+	repo.AssertExpectations(t)
+}
+
+func Test_service_StreamRead_timeout(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+	repo.On("StreamRead", mock.Anything, "jobs", int64(3), 1).Return([]domain.StreamEntry{}, nil)
+
+	config := DefaultConfig()
+	config.StreamPollInterval = 10 * time.Millisecond
+	s := NewRecordService(repo, config)
+	defer s.Close()
+
+	start := time.Now()
+	got, err := s.StreamRead(context.TODO(), "jobs", 3, 1, 50*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Greater(t, len(repo.Calls), 1, "the read must be retried every poll interval")
+
+	_, err = s.StreamRead(context.TODO(), "jobs", 3, 0, 0)
+	assert.Error(t, err)
+}
+
+func Test_service_StopWaiting(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+	repo.On("StreamReadGroup", mock.Anything, "jobs", "workers", "w1", 1).Return([]domain.StreamEntry{}, nil)
+
+	config := DefaultConfig()
+	config.StreamPollInterval = time.Minute
+	s := NewRecordService(repo, config)
+	defer s.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		got, err := s.StreamReadGroup(context.TODO(), "jobs", "workers", "w1", 1, time.Minute)
+		assert.NoError(t, err)
+		assert.Empty(t, got)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	s.(StreamWaiter).StopWaiting()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the read kept blocking after StopWaiting")
+	}
+
+	// later reads do not block at all
+	start := time.Now()
+	_, err := s.StreamReadGroup(context.TODO(), "jobs", "workers", "w1", 1, time.Minute)
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func Test_service_StreamClaim(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+	entries := []domain.StreamEntry{{Id: 4, Value: "a"}}
+	repo.On("StreamClaim", mock.Anything, "jobs", "workers", "w2", time.Minute, 10).Return(entries, nil).Once()
+
+	s := NewRecordService(repo, DefaultConfig())
+	defer s.Close()
+
+	got, err := s.StreamClaim(context.TODO(), "jobs", "workers", "w2", time.Minute, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, entries, got)
+
+	_, err = s.StreamClaim(context.TODO(), "jobs", "workers", "", time.Minute, 10)
+	assert.Error(t, err)
+	_, err = s.StreamClaim(context.TODO(), "jobs", "workers", "w2", -time.Second, 10)
+	assert.Error(t, err)
+	repo.AssertExpectations(t)
+}
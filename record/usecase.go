@@ -4,76 +4,259 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"github.com/allegro/bigcache/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/singleflight"
 	"log"
+	"storage/cache"
 	"storage/domain"
+	"storage/lifecycle"
+	"storage/metrics"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type service struct {
-	repo  domain.RecordRepository
-	cache *bigcache.BigCache
+	repo     domain.RecordRepository
+	config   Config
+	cache    cache.Cache
+	cacheErr error
+	loads    singleflight.Group
+	gens     generations
+	accesses *accessLog
+	warm     atomic.Bool
+	keys     *keyring
+	streams  *streamWaiters
+
+	cancel    context.CancelFunc
+	jobs      sync.WaitGroup
+	deletes   sync.WaitGroup
+	refreshes sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
 }
 
-func NewRecordService(repo domain.RecordRepository) domain.RecordService {
-	cache, _ := bigcache.New(context.Background(), getCacheConfig())
+// NewRecordService creates a record service in front of repo. When the
+// cache cannot be created the service keeps working directly against the
+// repository and reports the failure through CheckCache.
+func NewRecordService(repo domain.RecordRepository, config Config) domain.RecordService {
+	c, err := cache.New(config.Cache)
+	if err != nil {
+		log.Printf("record cache disabled: %v\n", err)
+		c = cache.NewNoopCache()
+	}
 
-	s := service{
-		repo:  repo,
-		cache: cache,
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &service{
+		repo:     repo,
+		config:   config,
+		cache:    c,
+		cacheErr: err,
+		streams:  newStreamWaiters(),
+		cancel:   cancel,
 	}
 
-	go printCacheStats(cache)
-	go s.removeExpiredRecordJob(10 * time.Minute)
+	s.goJob(func() { lifecycle.Every(ctx, time.Hour, s.printCacheStats) })
+	s.accesses = newAccessLog(config.AccessLogMaxKeys)
+	s.goJob(func() { lifecycle.Every(ctx, config.AccessLogFlushInterval, s.maintainAccessLog) })
+	if config.WarmUpKeys > 0 {
+		s.goJob(func() { s.warmUp(ctx) })
+	} else {
+		s.warm.Store(true)
+	}
+	if config.Invalidation != nil {
+		config.Invalidation.Subscribe(s.invalidate)
+	}
+	if config.MasterKey != nil {
+		s.keys = newKeyring(repo, config.MasterKey, config.PreviousMasterKey)
+		s.goJob(func() { lifecycle.Every(ctx, config.ReEncryptInterval, s.maintainKeys) })
+	}
 
-	return &s
+	strategies, err := newExpirationStrategies(repo, config)
+	if err != nil {
+		log.Printf("%v, falling back to %s\n", err, ExpirationSweep)
+		strategies = []ExpirationStrategy{NewSweepExpiration(repo, config)}
+	}
+	for _, strategy := range strategies {
+		strategy := strategy
+		s.goJob(func() { runExpiration(ctx, strategy, config.Leader) })
+	}
+
+	return s
 }
 
-func getCacheConfig() bigcache.Config {
-	config := bigcache.DefaultConfig(10 * time.Minute)
-	config.MaxEntriesInWindow = 10000
-	config.HardMaxCacheSize = 32 // MB
-	return config
+// Close stops the background jobs, waits for pending deletes and refreshes,
+// flushes the access log and releases the cache.
+func (s *service) Close() error {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		s.jobs.Wait()
+		s.deletes.Wait()
+		s.refreshes.Wait()
+
+		if s.accesses != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			s.flushAccesses(ctx)
+			cancel()
+		}
+
+		s.closeErr = s.cache.Close()
+	})
+	return s.closeErr
 }
 
-func (s *service) Set(ctx context.Context, record *domain.Record) error {
-	return s.repo.Set(ctx, record)
+func (s *service) goJob(job func()) {
+	s.jobs.Add(1)
+	go func() {
+		defer s.jobs.Done()
+		job()
+	}()
+}
+
+// deleteExpiredAsync lazily removes keys that were found expired on read,
+// without blocking the caller. Keys written again in the meantime are kept.
+func (s *service) deleteExpiredAsync(keys ...string) {
+	now := time.Now()
+	s.deletes.Add(1)
+	go func() {
+		defer s.deletes.Done()
+		if _, err := s.repo.DeleteIfExpired(context.Background(), now, keys...); err != nil {
+			log.Printf("lazy expiration: %v\n", err)
+		}
+	}()
 }
 
-func (s *service) Get(ctx context.Context, key string) (*domain.Record, error) {
-	if value := s.cacheGet(key); value != nil {
-		return value, nil
+func (s *service) Set(ctx context.Context, record *domain.Record) (err error) {
+	ctx, span := tracer.Start(ctx, "record.Set", keyAttribute(record.Key))
+	defer func() { endSpan(span, err) }()
+
+	if err = record.Normalize(); err != nil {
+		return err
+	}
+	if err = record.Metadata.Validate(); err != nil {
+		return err
+	}
+	if int64(len(record.Value)) > s.config.MaxValueSize {
+		return domain.ErrValueTooLarge
+	}
+	stored, err := s.seal(ctx, record)
+	if err != nil {
+		return err
+	}
+	if err = s.repo.Set(ctx, stored); err != nil {
+		return err
+	}
+	record.Metadata = stored.Metadata
+
+	s.loads.Forget(record.Key)
+	s.cacheDelete(record.Key)
+	s.publishInvalidation(ctx, record.Key)
+	return nil
+}
+
+func (s *service) Get(ctx context.Context, key string) (_ *domain.Record, err error) {
+	ctx, span := tracer.Start(ctx, "record.Get", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
+
+	if s.accesses != nil {
+		s.accesses.track(key)
+	}
+
+	if entry := s.cacheGet(ctx, key); entry != nil {
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		if entry.Missing {
+			metrics.CacheNegative.WithLabelValues("hit").Inc()
+			return nil, domain.ErrRecordNotFound
+		}
+		record := entry.toRecord()
+		if err = s.open(ctx, record); err == nil {
+			if entry.stale() {
+				span.SetAttributes(attribute.Bool("cache.stale", true))
+				metrics.CacheStaleServed.Inc()
+				s.revalidate(ctx, key)
+			}
+			return record, nil
+		}
+		// e.g. sealed with a data key that was deleted since
+		log.Printf("cached %s: %v\n", key, err)
+		s.cacheDelete(key)
+	}
+	span.SetAttributes(attribute.Bool("cache.hit", false))
+
+	// concurrent misses for the same key share one repository call, which
+	// must not fail for everyone when the first caller goes away
+	v, err, shared := s.loads.Do(key, func() (any, error) {
+		return s.load(detach(ctx), key)
+	})
+	if shared {
+		metrics.CacheCoalescedRequests.Inc()
+	}
+	if err != nil {
+		return nil, err
 	}
 
+	record := *v.(*domain.Record)
+	if err = s.open(ctx, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// revalidate refreshes the cached record of key in the background. Like
+// any load, a refresh that raced a write of key does not cache what it read.
+func (s *service) revalidate(ctx context.Context, key string) {
+	ctx = detach(ctx)
+	s.refreshes.Add(1)
+	go func() {
+		defer s.refreshes.Done()
+		_, _, _ = s.loads.Do(key, func() (any, error) {
+			return s.load(ctx, key)
+		})
+	}()
+}
+
+// load reads key from the repository and caches the outcome, unless key
+// was written meanwhile.
+func (s *service) load(ctx context.Context, key string) (*domain.Record, error) {
+	gen := s.gens.current(key)
 	record, err := s.repo.Get(ctx, key)
+	if errors.Is(err, domain.ErrRecordNotFound) {
+		s.gens.ifCurrent(key, gen, func() { s.cacheMissing(ctx, key) })
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	if record.IsExpired() {
-		go s.repo.Delete(context.Background(), key)
+		s.deleteExpiredAsync(key)
+		s.gens.ifCurrent(key, gen, func() { s.cacheMissing(ctx, key) })
 		return nil, errors.New("record expired")
 	}
 
-	s.cacheSet(key, record)
+	s.gens.ifCurrent(key, gen, func() { s.cacheSet(ctx, key, record) })
 
 	return record, nil
 }
 
-func (s *service) GetAll(ctx context.Context) []*domain.Record {
-	records := s.repo.GetAll(ctx)
+func (s *service) GetAll(ctx context.Context, filter *domain.MetadataFilter) []*domain.Record {
+	ctx, span := tracer.Start(ctx, "record.GetAll")
+	defer span.End()
+
+	records := s.repo.GetAll(ctx, filter)
 	var notExpiredRecords []*domain.Record
 	var expiredKeys []string
 	for _, r := range records {
 		if r.IsExpired() {
 			expiredKeys = append(expiredKeys, r.Key)
+		} else if err := s.open(ctx, r); err != nil {
+			log.Printf("get all: %s: %v\n", r.Key, err)
 		} else {
 			notExpiredRecords = append(notExpiredRecords, r)
 		}
 	}
 
 	if len(expiredKeys) > 0 {
-		go s.repo.Delete(context.Background(), expiredKeys...)
+		s.deleteExpiredAsync(expiredKeys...)
 	}
 
 	return notExpiredRecords
@@ -84,52 +267,347 @@ func (s *service) SetTtl(ctx context.Context, record *domain.Record) (*domain.Re
 	if err != nil {
 		return nil, err
 	}
+	if r.Type.IsCollection() {
+		if r, err = s.repo.ExpireCollection(ctx, record.Key, record.Ttl); err != nil {
+			return nil, err
+		}
+		s.collectionChanged(ctx, record.Key)
+		return r, nil
+	}
+	if err = s.open(ctx, r); err != nil {
+		return nil, err
+	}
 
 	r.Ttl = record.Ttl
-	if err = s.repo.Set(ctx, r); err != nil {
+	if err = s.Set(ctx, r); err != nil {
 		return nil, err
 	}
 
 	return r, nil
 }
 
-func (s *service) removeExpiredRecordJob(per time.Duration) {
-	for range time.Tick(per) {
-		records := s.repo.GetAll(context.Background())
-		var expiredKeys []string
-		for _, record := range records {
-			if record.IsExpired() {
-				expiredKeys = append(expiredKeys, record.Key)
-			}
+func (s *service) GetAt(ctx context.Context, key string, at time.Time) (*domain.Record, error) {
+	v, err := s.repo.GetVersionAt(ctx, key, at)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.openVersion(ctx, v); err != nil {
+		return nil, err
+	}
+
+	return &domain.Record{
+		Key:         v.Key,
+		Value:       v.Value,
+		Type:        v.Type,
+		Compression: v.Compression,
+	}, nil
+}
+
+func (s *service) History(ctx context.Context, key string) ([]*domain.RecordVersion, error) {
+	versions, err := s.repo.GetHistory(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		if err = s.openVersion(ctx, v); err != nil {
+			return nil, err
 		}
+	}
+	return versions, nil
+}
+
+// Restore writes the value of an older version back as the current value.
+// The ttl of the current record, if any, is kept.
+func (s *service) Restore(ctx context.Context, key string, version int) (*domain.Record, error) {
+	v, err := s.repo.GetVersion(ctx, key, version)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.openVersion(ctx, v); err != nil {
+		return nil, err
+	}
+
+	record := &domain.Record{
+		Key:         key,
+		Value:       v.Value,
+		Type:        v.Type,
+		Compression: v.Compression,
+	}
+	if current, err := s.repo.Get(ctx, key); err == nil && !current.IsExpired() {
+		record.Ttl = current.Ttl
+	}
 
-		s.repo.Delete(context.Background(), expiredKeys...)
+	if err = s.Set(ctx, record); err != nil {
+		return nil, err
 	}
+
+	return record, nil
 }
 
-func (s *service) cacheGet(key string) *domain.Record {
-	if value, err := s.cache.Get(key); err == nil {
-		var record domain.Record
-		json.Unmarshal(value, &record)
+func (s *service) Incr(ctx context.Context, key string, delta float64) (_ *domain.Record, err error) {
+	ctx, span := tracer.Start(ctx, "record.Incr", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
 
-		if record.IsExpired() {
+	var record domain.Record
+	updated, err := s.repo.Update(ctx, key, func(r *domain.Record) error {
+		if err := s.open(ctx, r); err != nil {
+			return err
+		}
+		if err := r.Incr(delta); err != nil {
+			return err
+		}
+		record = *r
+		stored, err := s.seal(ctx, r)
+		if err != nil {
+			return err
+		}
+		*r = *stored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	record.Metadata = updated.Metadata
+
+	s.loads.Forget(key)
+	s.cacheDelete(key)
+	s.publishInvalidation(ctx, key)
+	return &record, nil
+}
+
+func (s *service) GetPath(ctx context.Context, key, path string) (json.RawMessage, error) {
+	record, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if record.Type != domain.TypeJson {
+		return nil, domain.ErrWrongType
+	}
+
+	return domain.JsonPointerGet(record.Value, path)
+}
+
+// Patch applies the patch inside the database and caches the patched
+// record right away.
+func (s *service) Patch(ctx context.Context, key string, patchType domain.PatchType, patch string) (_ *domain.Record, err error) {
+	ctx, span := tracer.Start(ctx, "record.Patch", keyAttribute(key))
+	defer func() { endSpan(span, err) }()
+
+	if err = domain.ValidatePatch(patchType, patch); err != nil {
+		return nil, err
+	}
+
+	record, err := s.repo.PatchJson(ctx, key, patchType, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	s.loads.Forget(key)
+	s.gens.bump(key)
+	s.cacheSet(ctx, key, record)
+	s.publishInvalidation(ctx, key)
+	return record, nil
+}
+
+func (s *service) cacheGet(ctx context.Context, key string) *cacheEntry {
+	_, span := tracer.Start(ctx, "cache.Get", keyAttribute(key))
+	defer span.End()
+
+	value, err := s.cache.Get(key)
+	if err != nil {
+		return nil
+	}
+	span.AddEvent("hit")
+
+	var entry cacheEntry
+	if err = json.Unmarshal(value, &entry); err != nil || entry.expired() || entry.tooStale() {
+		s.cache.Delete(key)
+		return nil
+	}
+
+	return &entry
+}
+
+func (s *service) cacheSet(ctx context.Context, key string, value *domain.Record) {
+	_, span := tracer.Start(ctx, "cache.Set", keyAttribute(key))
+	defer span.End()
+
+	if value.Blob != nil || len(value.Value) > s.config.CacheMaxValueSize {
+		// an older, smaller value may still be cached
+		metrics.CacheBypassed.Inc()
+		s.cache.Delete(key)
+		return
+	}
+
+	if value.KeyId != 0 && s.config.CachePlaintext {
+		plain := *value
+		if err := s.decrypt(ctx, &plain); err != nil {
 			s.cache.Delete(key)
-			return nil
+			return
 		}
+		value = &plain
+	}
+
+	entry := newCacheEntry(value)
+	if s.config.CacheFreshness > 0 {
+		entry.RefreshAt = time.Now().Add(s.config.CacheFreshness)
+		entry.StaleUntil = entry.RefreshAt.Add(s.config.StaleWhileRevalidate)
+	}
+
+	v, _ := json.Marshal(entry)
+	s.cache.Set(key, v)
+}
+
+// cacheMissing remembers for a short while that key does not exist.
+func (s *service) cacheMissing(ctx context.Context, key string) {
+	if s.config.NegativeCacheTtl <= 0 {
+		return
+	}
+
+	_, span := tracer.Start(ctx, "cache.SetMissing", keyAttribute(key))
+	defer span.End()
+
+	v, _ := json.Marshal(&cacheEntry{
+		Key:      key,
+		Missing:  true,
+		ExpireAt: time.Now().Add(s.config.NegativeCacheTtl),
+	})
+	if s.cache.Set(key, v) == nil {
+		metrics.CacheNegative.WithLabelValues("stored").Inc()
+	}
+}
+
+// cacheEntry keeps the absolute expiry of a cached record so that its ttl
+// keeps counting down while it sits in the cache.
+type cacheEntry struct {
+	Key   string
+	Value string
+	Type  domain.ValueType `json:",omitempty"`
+	// Compression is set when Value holds the compressed value, base64
+	// encoded, as it was persisted.
+	Compression domain.Compression `json:",omitempty"`
+	// KeyId is set when Value holds the encrypted value, base64 encoded,
+	// which is kept unless CachePlaintext is set.
+	KeyId    int `json:",omitempty"`
+	ExpireAt time.Time
+	// Missing marks a negative entry for a key that does not exist.
+	Missing  bool `json:",omitempty"`
+	Metadata domain.Metadata
+	// RefreshAt and StaleUntil bound the freshness of the entry when
+	// CacheFreshness is set; in between the entry is served stale.
+	RefreshAt  time.Time
+	StaleUntil time.Time
+}
+
+func (e *cacheEntry) expired() bool {
+	return !e.ExpireAt.IsZero() && !time.Now().Before(e.ExpireAt)
+}
+
+func (e *cacheEntry) stale() bool {
+	return !e.RefreshAt.IsZero() && !time.Now().Before(e.RefreshAt)
+}
+
+func (e *cacheEntry) tooStale() bool {
+	return !e.StaleUntil.IsZero() && !time.Now().Before(e.StaleUntil)
+}
+
+func newCacheEntry(r *domain.Record) *cacheEntry {
+	var expireAt time.Time
+	if r.Ttl != 0 {
+		expireAt = time.Now().Add(r.Ttl)
+	}
+	return &cacheEntry{
+		Key:         r.Key,
+		Value:       r.Value,
+		Type:        r.Type,
+		Compression: r.Compression,
+		KeyId:       r.KeyId,
+		ExpireAt:    expireAt,
+		Metadata:    r.Metadata,
+	}
+}
 
-		return &record
+func (e *cacheEntry) toRecord() *domain.Record {
+	var ttl time.Duration
+	if !e.ExpireAt.IsZero() {
+		ttl = time.Until(e.ExpireAt)
+	}
+	return &domain.Record{
+		Key:         e.Key,
+		Value:       e.Value,
+		Type:        e.Type,
+		Compression: e.Compression,
+		KeyId:       e.KeyId,
+		Ttl:         ttl,
+		Metadata:    e.Metadata,
 	}
+}
+
+// cacheDelete drops key from the cache after a write. Loads that read key
+// before the write see its generation change and do not cache the old
+// value.
+func (s *service) cacheDelete(key string) {
+	s.gens.bump(key)
+	s.cache.Delete(key)
+}
+
+// detachedContext keeps the values of a context, such as the trace span,
+// but not its cancellation.
+type detachedContext struct {
+	context.Context
+}
+
+func detach(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
 
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
 	return nil
 }
 
-func (s *service) cacheSet(key string, value *domain.Record) {
-	v, _ := json.Marshal(value)
-	s.cache.Set(key, v)
+func (detachedContext) Err() error {
+	return nil
+}
+
+// publishInvalidation tells the other replicas to drop keys from their
+// cache. The write already succeeded, so failures are only logged; stale
+// entries then live until they leave the cache.
+func (s *service) publishInvalidation(ctx context.Context, keys ...string) {
+	if s.config.Invalidation == nil {
+		return
+	}
+	if err := s.config.Invalidation.Publish(ctx, keys...); err != nil {
+		log.Printf("cache invalidation: %v\n", err)
+	}
 }
 
-func printCacheStats(cache *bigcache.BigCache) {
-	for range time.Tick(time.Hour) {
-		log.Printf("cache stats: %+v,	length: %d\n", cache.Stats(), cache.Len())
+// invalidate applies an invalidation published by another replica, which
+// may have added entries to the streams of keys.
+func (s *service) invalidate(keys []string) {
+	s.streams.notify(keys...)
+	if len(keys) == 0 {
+		s.gens.bumpAll()
+		_ = s.cache.Reset()
+		return
 	}
+	for _, key := range keys {
+		s.cacheDelete(key)
+	}
+}
+
+// CheckCache reports whether the cache was initialised.
+func (s *service) CheckCache(context.Context) error {
+	return s.cacheErr
+}
+
+func (s *service) CacheStats() metrics.CacheStats {
+	return s.cache.Stats()
+}
+
+func (s *service) printCacheStats(context.Context) {
+	log.Printf("cache stats: %+v\n", s.cache.Stats())
 }
@@ -5,8 +5,11 @@ import (
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"storage/cache"
 	"storage/domain"
 	"storage/domain/mocks"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -24,16 +27,232 @@ func Test_service_Set(t *testing.T) {
 			On("Set", mock.Anything, &mockRecord).
 			Return(nil).Once()
 
-		u := NewRecordService(repo)
+		u := NewRecordService(repo, DefaultConfig())
+		defer u.Close()
 		err := u.Set(context.TODO(), &mockRecord)
 		assert.NoError(t, err)
 
 		repo.AssertExpectations(t)
 	})
+
+	t.Run("broadcasts invalidation", func(t *testing.T) {
+		repo.
+			On("Set", mock.Anything, &mockRecord).
+			Return(nil).Once()
+		bus := new(mocks.MockInvalidationBus)
+		bus.
+			On("Subscribe", mock.Anything).Return().Once().
+			On("Publish", mock.Anything, []string{mockRecord.Key}).Return(nil).Once()
+
+		config := DefaultConfig()
+		config.Invalidation = bus
+		u := NewRecordService(repo, config)
+		defer u.Close()
+		err := u.Set(context.TODO(), &mockRecord)
+		assert.NoError(t, err)
+
+		bus.AssertExpectations(t)
+	})
+
+	t.Run("invalid metadata", func(t *testing.T) {
+		u := NewRecordService(repo, DefaultConfig())
+		defer u.Close()
+		for _, m := range []domain.Metadata{
+			{Tags: []string{""}},
+			{Tags: []string{strings.Repeat("t", 129)}},
+			{Owner: -1},
+			{ContentType: "text/"},
+		} {
+			err := u.Set(context.TODO(), &domain.Record{Key: "key", Value: "val", Metadata: m})
+			assert.Error(t, err, "%+v", m)
+		}
+	})
+}
+
+func Test_service_Set_typed(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+
+	t.Run("normalizes the value", func(t *testing.T) {
+		r := &domain.Record{Key: "doc", Value: `{ "a": 1 }`, Type: domain.TypeJson}
+		repo.On("Set", mock.Anything, &domain.Record{Key: "doc", Value: `{"a":1}`, Type: domain.TypeJson}).
+			Return(nil).Once()
+
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
+		assert.NoError(t, s.Set(context.TODO(), r))
+
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("rejects invalid values", func(t *testing.T) {
+		repo := new(mocks.MockRecordRepository)
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
+		err := s.Set(context.TODO(), &domain.Record{Key: "n", Value: "1.5", Type: domain.TypeInt})
+		assert.EqualError(t, err, `invalid int value "1.5"`)
+
+		repo.AssertNotCalled(t, "Set", mock.Anything, mock.Anything)
+	})
+}
+
+func Test_service_Incr(t *testing.T) {
+	t.Run("int", func(t *testing.T) {
+		repo := new(mocks.MockRecordRepository)
+		repo.On("Update", mock.Anything, "counter", mock.Anything).
+			Return(&domain.Record{Key: "counter", Value: "41", Type: domain.TypeInt}, nil).Once()
+
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
+		r, err := s.Incr(context.TODO(), "counter", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "42", r.Value)
+	})
+
+	t.Run("float", func(t *testing.T) {
+		repo := new(mocks.MockRecordRepository)
+		repo.On("Update", mock.Anything, "ratio", mock.Anything).
+			Return(&domain.Record{Key: "ratio", Value: "0.5", Type: domain.TypeFloat}, nil).Once()
+
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
+		r, err := s.Incr(context.TODO(), "ratio", 0.25)
+		assert.NoError(t, err)
+		assert.Equal(t, "0.75", r.Value)
+	})
+
+	t.Run("fractional increment of an int", func(t *testing.T) {
+		repo := new(mocks.MockRecordRepository)
+		repo.On("Update", mock.Anything, "counter", mock.Anything).
+			Return(&domain.Record{Key: "counter", Value: "41", Type: domain.TypeInt}, nil).Once()
+
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
+		_, err := s.Incr(context.TODO(), "counter", 0.5)
+		assert.Error(t, err)
+	})
+
+	t.Run("increment out of the int range", func(t *testing.T) {
+		repo := new(mocks.MockRecordRepository)
+		repo.On("Update", mock.Anything, "counter", mock.Anything).
+			Return(&domain.Record{Key: "counter", Value: "5", Type: domain.TypeInt}, nil).Once()
+
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
+		// 1<<63 is float64(math.MaxInt64) and overflows int64
+		_, err := s.Incr(context.TODO(), "counter", 9223372036854775808)
+		assert.Error(t, err)
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		repo := new(mocks.MockRecordRepository)
+		repo.On("Update", mock.Anything, "name", mock.Anything).
+			Return(&domain.Record{Key: "name", Value: "bob", Type: domain.TypeString}, nil).Once()
+
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
+		_, err := s.Incr(context.TODO(), "name", 1)
+		assert.ErrorIs(t, err, domain.ErrWrongType)
+	})
+}
+
+func Test_service_Patch(t *testing.T) {
+	t.Run("caches the patched record", func(t *testing.T) {
+		repo := new(mocks.MockRecordRepository)
+		repo.On("TrackAccesses", mock.Anything, mock.Anything).Return(nil).Maybe()
+		patched := &domain.Record{Key: "doc", Value: `{"a":1,"b":2}`, Type: domain.TypeJson}
+		repo.On("PatchJson", mock.Anything, "doc", domain.PatchMerge, `{"b":2}`).Return(patched, nil).Once()
+
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
+		r, err := s.Patch(context.TODO(), "doc", domain.PatchMerge, `{"b":2}`)
+		assert.NoError(t, err)
+		assert.Equal(t, patched, r)
+
+		// served from the cache, the repository is not asked again
+		b, err := s.GetPath(context.TODO(), "doc", "/b")
+		assert.NoError(t, err)
+		assert.JSONEq(t, `2`, string(b))
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("invalid patch", func(t *testing.T) {
+		repo := new(mocks.MockRecordRepository)
+
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
+		_, err := s.Patch(context.TODO(), "doc", domain.PatchJson, `[{"op":"rename","path":"/a"}]`)
+		assert.EqualError(t, err, `invalid json patch operation 0: unknown op "rename"`)
+		repo.AssertNotCalled(t, "PatchJson", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func Test_service_GetPath(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+	repo.On("TrackAccesses", mock.Anything, mock.Anything).Return(nil).Maybe()
+	repo.
+		On("Get", mock.Anything, "doc").
+		Return(&domain.Record{Key: "doc", Value: `{"a":{"b/c":[1,{"d":true}]}}`, Type: domain.TypeJson}, nil).Once().
+		On("Get", mock.Anything, "name").
+		Return(&domain.Record{Key: "name", Value: "bob", Type: domain.TypeString}, nil).Once()
+
+	s := NewRecordService(repo, DefaultConfig())
+	defer s.Close()
+
+	v, err := s.GetPath(context.TODO(), "doc", "/a/b~1c/1")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"d":true}`, string(v))
+
+	v, err = s.GetPath(context.TODO(), "doc", "")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a":{"b/c":[1,{"d":true}]}}`, string(v))
+
+	_, err = s.GetPath(context.TODO(), "doc", "/a/x")
+	assert.ErrorIs(t, err, domain.ErrPathNotFound)
+
+	_, err = s.GetPath(context.TODO(), "doc", "/a/b~1c/01")
+	assert.ErrorIs(t, err, domain.ErrPathNotFound)
+
+	_, err = s.GetPath(context.TODO(), "name", "/a")
+	assert.ErrorIs(t, err, domain.ErrWrongType)
+}
+
+func Test_service_invalidate(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+	repo.On("TrackAccesses", mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockRecord := domain.Record{
+		Key:   "key",
+		Value: "val",
+	}
+	repo.
+		On("Get", mock.Anything, mockRecord.Key).
+		Return(&mockRecord, nil).Twice()
+
+	var invalidate func(keys []string)
+	bus := new(mocks.MockInvalidationBus)
+	bus.On("Subscribe", mock.Anything).
+		Run(func(args mock.Arguments) { invalidate = args.Get(0).(func(keys []string)) }).
+		Return().Once()
+
+	config := DefaultConfig()
+	config.Invalidation = bus
+	u := NewRecordService(repo, config)
+	defer u.Close()
+
+	_, err := u.Get(context.TODO(), mockRecord.Key)
+	assert.NoError(t, err)
+	_, err = u.Get(context.TODO(), mockRecord.Key)
+	assert.NoError(t, err)
+	repo.AssertNumberOfCalls(t, "Get", 1)
+
+	invalidate([]string{mockRecord.Key})
+	_, err = u.Get(context.TODO(), mockRecord.Key)
+	assert.NoError(t, err)
+	repo.AssertNumberOfCalls(t, "Get", 2)
 }
 
 func Test_service_Get(t *testing.T) {
 	repo := new(mocks.MockRecordRepository)
+	repo.On("TrackAccesses", mock.Anything, mock.Anything).Return(nil).Maybe()
 	mockRecord := domain.Record{
 		Key:   "key",
 		Value: "val",
@@ -45,7 +264,8 @@ func Test_service_Get(t *testing.T) {
 			On("Get", mock.Anything, mockRecord.Key).
 			Return(&mockRecord, nil).Once()
 
-		u := NewRecordService(repo)
+		u := NewRecordService(repo, DefaultConfig())
+		defer u.Close()
 		r, err := u.Get(context.TODO(), mockRecord.Key)
 		assert.Equal(t, mockRecord, *r)
 		assert.NoError(t, err)
@@ -59,7 +279,8 @@ func Test_service_Get(t *testing.T) {
 			On("Get", mock.Anything, mockRecord.Key).
 			Return(nil, expectedErr).Once()
 
-		u := NewRecordService(repo)
+		u := NewRecordService(repo, DefaultConfig())
+		defer u.Close()
 		r, err := u.Get(context.TODO(), mockRecord.Key)
 		assert.Empty(t, r)
 		assert.Equal(t, expectedErr, err)
@@ -73,16 +294,16 @@ func Test_service_Get(t *testing.T) {
 
 		repo.
 			On("Get", mock.Anything, mockRecord.Key).Return(&mockRecord, nil).Once().
-			On("Delete", mock.Anything, []string{mockRecord.Key}).Return().Once()
+			On("DeleteIfExpired", mock.Anything, mock.Anything, []string{mockRecord.Key}).Return(int64(1), nil).Once()
 
-		s := NewRecordService(repo)
+		s := NewRecordService(repo, DefaultConfig())
 		r, err := s.Get(context.TODO(), mockRecord.Key)
 		assert.Empty(t, r)
 		if assert.Error(t, err) {
 			assert.Equal(t, errors.New("record expired"), err)
 		}
 
-		time.Sleep(time.Millisecond)
+		assert.NoError(t, s.Close())
 		repo.AssertExpectations(t)
 	})
 }
@@ -104,15 +325,15 @@ func Test_service_GetAll(t *testing.T) {
 
 	t.Run("get all record", func(t *testing.T) {
 		repo.
-			On("GetAll", mock.Anything).Return(mockRecords).Once().
-			On("Delete", mock.Anything, []string{mockRecords[1].Key}).Return().Once()
+			On("GetAll", mock.Anything, mock.Anything).Return(mockRecords).Once().
+			On("DeleteIfExpired", mock.Anything, mock.Anything, []string{mockRecords[1].Key}).Return(int64(1), nil).Once()
 
-		s := NewRecordService(repo)
-		records := s.GetAll(context.TODO())
+		s := NewRecordService(repo, DefaultConfig())
+		records := s.GetAll(context.TODO(), nil)
 		expected := []*domain.Record{mockRecords[0]}
 		assert.Equal(t, expected, records)
 
-		time.Sleep(time.Millisecond * 1)
+		assert.NoError(t, s.Close())
 		repo.AssertExpectations(t)
 	})
 }
@@ -122,6 +343,7 @@ func Test_service_SetTtl(t *testing.T) {
 	mockRecord := domain.Record{
 		Key:   "key",
 		Value: "val",
+		Type:  domain.TypeString,
 		Ttl:   0,
 	}
 	newTtl := 10
@@ -134,7 +356,8 @@ func Test_service_SetTtl(t *testing.T) {
 		repo.
 			On("Set", mock.Anything, &mockRecordWithNewTtl).Return(nil)
 
-		s := NewRecordService(repo)
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
 		r, err := s.SetTtl(context.TODO(), &mockRecordWithNewTtl)
 		assert.NoError(t, err)
 		assert.Equal(t, &mockRecordWithNewTtl, r)
@@ -146,7 +369,8 @@ func Test_service_SetTtl(t *testing.T) {
 		repo.On("Get", mock.Anything, mockRecord.Key).
 			Return(nil, errors.New("")).Once()
 
-		s := NewRecordService(repo)
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
 		r, err := s.SetTtl(context.TODO(), &mockRecordWithNewTtl)
 		assert.Empty(t, r)
 		assert.Error(t, err)
@@ -155,30 +379,288 @@ func Test_service_SetTtl(t *testing.T) {
 	})
 }
 
-func Test_service_removeExpiredRecordJob(t *testing.T) {
+func Test_service_GetAt(t *testing.T) {
 	repo := new(mocks.MockRecordRepository)
-	mockRecords := []*domain.Record{
-		{
-			Key:   "key1",
-			Value: "val",
-			Ttl:   0,
-		},
-		{
-			Key:   "key2",
-			Value: "val",
-			Ttl:   -1,
-		},
+	at := time.Now().Add(-time.Hour)
+
+	t.Run("success", func(t *testing.T) {
+		repo.On("GetVersionAt", mock.Anything, "key", at).
+			Return(&domain.RecordVersion{Key: "key", Value: "old", Type: domain.TypeString, Version: 1}, nil).Once()
+
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
+		r, err := s.GetAt(context.TODO(), "key", at)
+		assert.NoError(t, err)
+		assert.Equal(t, &domain.Record{Key: "key", Value: "old", Type: domain.TypeString}, r)
+
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("no version at that time", func(t *testing.T) {
+		repo.On("GetVersionAt", mock.Anything, "key", at).
+			Return(nil, errors.New("record not found")).Once()
+
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
+		r, err := s.GetAt(context.TODO(), "key", at)
+		assert.Empty(t, r)
+		assert.Error(t, err)
+
+		repo.AssertExpectations(t)
+	})
+}
+
+func Test_service_Restore(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+	current := domain.Record{
+		Key:   "key",
+		Value: "new",
+		Ttl:   time.Hour,
 	}
 
-	t.Run("get all record", func(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		restored := &domain.Record{Key: "key", Value: "42", Type: domain.TypeInt, Ttl: current.Ttl}
 		repo.
-			On("GetAll", mock.Anything).Return(mockRecords).
-			On("Delete", mock.Anything, []string{mockRecords[1].Key}).Return()
+			On("GetVersion", mock.Anything, "key", 1).
+			Return(&domain.RecordVersion{Key: "key", Value: "42", Type: domain.TypeInt, Version: 1}, nil).Once().
+			On("Get", mock.Anything, "key").Return(&current, nil).Once().
+			On("Set", mock.Anything, restored).Return(nil).Once()
+
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
+		r, err := s.Restore(context.TODO(), "key", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, restored, r)
+
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("version not exist", func(t *testing.T) {
+		repo.On("GetVersion", mock.Anything, "key", 5).
+			Return(nil, errors.New("record not found")).Once()
 
-		s := service{repo: repo}
-		go s.removeExpiredRecordJob(time.Millisecond)
-		time.Sleep(time.Millisecond * 10)
+		s := NewRecordService(repo, DefaultConfig())
+		defer s.Close()
+		r, err := s.Restore(context.TODO(), "key", 5)
+		assert.Empty(t, r)
+		assert.Error(t, err)
 
 		repo.AssertExpectations(t)
 	})
 }
+
+func Test_service_Close(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+
+	s := NewRecordService(repo, DefaultConfig())
+	done := make(chan error)
+	go func() { done <- s.Close() }()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("background jobs did not stop")
+	}
+}
+
+func Test_cacheEntry(t *testing.T) {
+	e := newCacheEntry(&domain.Record{Key: "key", Value: "val", Ttl: time.Millisecond})
+	time.Sleep(2 * time.Millisecond)
+	assert.True(t, e.toRecord().IsExpired())
+
+	e = newCacheEntry(&domain.Record{Key: "key", Value: "val"})
+	assert.Equal(t, &domain.Record{Key: "key", Value: "val"}, e.toRecord())
+
+	r := &domain.Record{Key: "key", Value: "val", Metadata: domain.Metadata{Owner: 1, Tags: []string{"a"}, CreatedAt: time.Now()}}
+	assert.Equal(t, r, newCacheEntry(r).toRecord())
+}
+
+func Test_service_Get_negativeCache(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+	repo.On("TrackAccesses", mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockRecord := domain.Record{Key: "key", Value: "val"}
+	repo.
+		On("Get", mock.Anything, mockRecord.Key).Return(nil, domain.ErrRecordNotFound).Once().
+		On("Set", mock.Anything, &mockRecord).Return(nil).Once().
+		On("Get", mock.Anything, mockRecord.Key).Return(&mockRecord, nil).Once()
+
+	s := NewRecordService(repo, DefaultConfig())
+	defer s.Close()
+
+	for i := 0; i < 2; i++ {
+		_, err := s.Get(context.TODO(), mockRecord.Key)
+		assert.ErrorIs(t, err, domain.ErrRecordNotFound)
+	}
+	repo.AssertNumberOfCalls(t, "Get", 1)
+
+	// a write replaces the negative entry
+	assert.NoError(t, s.Set(context.TODO(), &mockRecord))
+	r, err := s.Get(context.TODO(), mockRecord.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, mockRecord, *r)
+	repo.AssertExpectations(t)
+}
+
+func Test_service_Get_coalescing(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+	repo.On("TrackAccesses", mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockRecord := domain.Record{Key: "key", Value: "val"}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	repo.On("Get", mock.Anything, mockRecord.Key).
+		Run(func(mock.Arguments) {
+			close(started)
+			<-release
+		}).
+		Return(&mockRecord, nil).Once()
+
+	s := NewRecordService(repo, DefaultConfig())
+	defer s.Close()
+
+	var wg sync.WaitGroup
+	get := func(ctx context.Context) {
+		defer wg.Done()
+		r, err := s.Get(ctx, mockRecord.Key)
+		if assert.NoError(t, err) {
+			assert.Equal(t, mockRecord, *r)
+		}
+	}
+
+	// the first caller giving up does not fail the others
+	ctx, cancel := context.WithCancel(context.Background())
+	wg.Add(1)
+	go get(ctx)
+	<-started
+	cancel()
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go get(context.Background())
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	repo.AssertNumberOfCalls(t, "Get", 1)
+}
+
+func Test_service_Get_writeDuringLoad(t *testing.T) {
+	for name, loaded := range map[string]error{
+		"missing key": domain.ErrRecordNotFound,
+		"old value":   nil,
+	} {
+		t.Run(name, func(t *testing.T) {
+			repo := new(mocks.MockRecordRepository)
+			repo.On("TrackAccesses", mock.Anything, mock.Anything).Return(nil).Maybe()
+			old := domain.Record{Key: "key", Value: "old"}
+			updated := domain.Record{Key: "key", Value: "new"}
+
+			started := make(chan struct{})
+			release := make(chan struct{})
+			get := repo.On("Get", mock.Anything, old.Key).
+				Run(func(mock.Arguments) {
+					close(started)
+					<-release
+				}).Once()
+			if loaded != nil {
+				get.Return(nil, loaded)
+			} else {
+				get.Return(&old, nil)
+			}
+			repo.On("Set", mock.Anything, &updated).Return(nil).Once()
+
+			s := NewRecordService(repo, DefaultConfig()).(*service)
+			defer s.Close()
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				_, _ = s.Get(context.TODO(), old.Key)
+			}()
+			<-started
+			assert.NoError(t, s.Set(context.TODO(), &updated))
+			close(release)
+			<-done
+
+			_, err := s.cache.Get(old.Key)
+			assert.ErrorIs(t, err, cache.ErrNotFound, "the load must not cache what it read before the write")
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_service_Get_staleWhileRevalidate(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+	repo.On("TrackAccesses", mock.Anything, mock.Anything).Return(nil).Maybe()
+	old := domain.Record{Key: "key", Value: "old"}
+	updated := domain.Record{Key: "key", Value: "new"}
+	repo.
+		On("Get", mock.Anything, old.Key).Return(&old, nil).Once().
+		On("Get", mock.Anything, old.Key).Return(&updated, nil).Once()
+
+	config := DefaultConfig()
+	config.CacheFreshness = time.Millisecond
+	config.StaleWhileRevalidate = time.Hour
+	s := NewRecordService(repo, config)
+	defer s.Close()
+
+	r, err := s.Get(context.TODO(), old.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, old, *r)
+	time.Sleep(2 * time.Millisecond)
+
+	// the stale value is served while it is refreshed
+	r, err = s.Get(context.TODO(), old.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, old, *r)
+	s.(*service).refreshes.Wait()
+
+	r, err = s.Get(context.TODO(), old.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, updated, *r)
+	repo.AssertNumberOfCalls(t, "Get", 2)
+}
+
+func Test_service_Get_writeDuringRevalidate(t *testing.T) {
+	repo := new(mocks.MockRecordRepository)
+	repo.On("TrackAccesses", mock.Anything, mock.Anything).Return(nil).Maybe()
+	old := domain.Record{Key: "key", Value: "old"}
+	updated := domain.Record{Key: "key", Value: "new"}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	repo.
+		On("Get", mock.Anything, old.Key).Return(&old, nil).Once().
+		On("Get", mock.Anything, old.Key).
+		Run(func(mock.Arguments) {
+			close(started)
+			<-release
+		}).
+		Return(&old, nil).Once().
+		On("Set", mock.Anything, &updated).Return(nil).Once()
+
+	config := DefaultConfig()
+	config.CacheFreshness = time.Millisecond
+	config.StaleWhileRevalidate = time.Hour
+	s := NewRecordService(repo, config).(*service)
+	defer s.Close()
+
+	_, err := s.Get(context.TODO(), old.Key)
+	assert.NoError(t, err)
+	time.Sleep(2 * time.Millisecond)
+
+	// the stale hit starts a refresh, which reads the old value before the write
+	r, err := s.Get(context.TODO(), old.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, old, *r)
+	<-started
+	assert.NoError(t, s.Set(context.TODO(), &updated))
+	close(release)
+	s.refreshes.Wait()
+
+	_, err = s.cache.Get(old.Key)
+	assert.ErrorIs(t, err, cache.ErrNotFound, "the refresh must not cache the value it read before the write")
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,182 @@
+package record
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"storage/domain"
+	"strconv"
+	"time"
+)
+
+const (
+	mimeOctetStream = "application/octet-stream"
+	mimeMergePatch  = "application/merge-patch+json"
+	mimeJsonPatch   = "application/json-patch+json"
+)
+
+// maxRawValueSize bounds the body of raw writes.
+const maxRawValueSize = 1 << 20
+
+// decodeValue turns the json value of a request into the canonical text of
+// a record value. Without a type, the type is taken from the json value.
+// Scalars may also be sent as json strings, e.g. "42" for an int.
+func decodeValue(raw json.RawMessage, t domain.ValueType) (domain.ValueType, string, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return "", "", errors.New("value must not be null")
+	}
+
+	if t == "" {
+		t = inferType(raw)
+	} else if _, err := domain.ParseValueType(string(t)); err != nil {
+		return "", "", err
+	}
+
+	if t == domain.TypeJson || raw[0] != '"' {
+		if t == domain.TypeString || t == domain.TypeBytes {
+			return "", "", fmt.Errorf("%s values must be sent as json strings", t)
+		}
+		return t, string(raw), nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "", "", err
+	}
+	return t, s, nil
+}
+
+func inferType(raw json.RawMessage) domain.ValueType {
+	switch raw[0] {
+	case '"':
+		return domain.TypeString
+	case '{', '[':
+		return domain.TypeJson
+	case 't', 'f':
+		return domain.TypeBool
+	}
+	if _, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+		return domain.TypeInt
+	}
+	return domain.TypeFloat
+}
+
+// encodeValue renders a record value as json: strings and bytes as json
+// strings, every other type as the json value it holds.
+func encodeValue(t domain.ValueType, value string) json.RawMessage {
+	switch t {
+	case domain.TypeString, domain.TypeBytes, "":
+		b, _ := json.Marshal(value)
+		return b
+	default:
+		return json.RawMessage(value)
+	}
+}
+
+// rawRecord reads a record from a raw request body. The value type follows
+// the content type unless it is given as a query parameter.
+func rawRecord(c *gin.Context) (*domain.Record, error) {
+	t, err := domain.ParseValueType(c.Query("type"))
+	if err != nil {
+		return nil, err
+	}
+	if c.Query("type") == "" {
+		switch c.ContentType() {
+		case gin.MIMEJSON:
+			t = domain.TypeJson
+		case mimeOctetStream:
+			t = domain.TypeBytes
+		}
+	}
+
+	compression, err := domain.ParseCompression(c.Query("compression"))
+	if err != nil {
+		return nil, err
+	}
+
+	var ttl time.Duration
+	if s := c.Query("ttl"); s != "" {
+		if ttl, err = time.ParseDuration(s); err != nil {
+			return nil, err
+		}
+	}
+
+	var owner int
+	if s := c.Query("owner"); s != "" {
+		if owner, err = strconv.Atoi(s); err != nil {
+			return nil, errors.New("invalid owner: " + s)
+		}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxRawValueSize))
+	if err != nil {
+		return nil, err
+	}
+
+	value := string(body)
+	if t == domain.TypeBytes {
+		value = base64.StdEncoding.EncodeToString(body)
+	}
+	return &domain.Record{
+		Key:         c.Param("key"),
+		Value:       value,
+		Type:        t,
+		Ttl:         ttl,
+		Compression: compression,
+		Metadata: domain.Metadata{
+			Owner:       owner,
+			Tags:        c.QueryArray("tag"),
+			ContentType: c.Query("content_type"),
+		},
+	}, nil
+}
+
+// writeRaw writes the raw value of r with its content type.
+func writeRaw(c *gin.Context, r *domain.Record) {
+	b, err := r.Bytes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Header("X-Value-Type", string(r.Type))
+	c.Data(http.StatusOK, rawContentType(r), b)
+}
+
+// rawContentType is the content type the raw value of r is served with:
+// the one set on write, or else the one of its value type.
+func rawContentType(r *domain.Record) string {
+	if r.ContentType != "" {
+		return r.ContentType
+	}
+	switch r.Type {
+	case domain.TypeString:
+		return gin.MIMEPlain + "; charset=utf-8"
+	case domain.TypeBytes:
+		return mimeOctetStream
+	default:
+		return gin.MIMEJSON
+	}
+}
+
+// etag is the entity tag of the raw value of r: the sha256 of its content,
+// which streamed values carry along and inline ones are hashed for.
+func etag(r *domain.Record) (string, error) {
+	if r.Blob != nil {
+		return `"` + r.Blob.Checksum + `"`, nil
+	}
+	b, err := r.Bytes()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:]) + `"`, nil
+}
@@ -0,0 +1,49 @@
+package record
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"storage/domain"
+	"testing"
+)
+
+func Test_decodeValue(t *testing.T) {
+	tests := []struct {
+		raw       string
+		valueType domain.ValueType
+		wantType  domain.ValueType
+		want      string
+		wantErr   bool
+	}{
+		{raw: `"text"`, wantType: domain.TypeString, want: "text"},
+		{raw: `42`, wantType: domain.TypeInt, want: "42"},
+		{raw: `4.2`, wantType: domain.TypeFloat, want: "4.2"},
+		{raw: `true`, wantType: domain.TypeBool, want: "true"},
+		{raw: `{"a":1}`, wantType: domain.TypeJson, want: `{"a":1}`},
+		{raw: `"42"`, valueType: domain.TypeInt, wantType: domain.TypeInt, want: "42"},
+		{raw: `"text"`, valueType: domain.TypeJson, wantType: domain.TypeJson, want: `"text"`},
+		{raw: `"AAEC"`, valueType: domain.TypeBytes, wantType: domain.TypeBytes, want: "AAEC"},
+		{raw: `42`, valueType: domain.TypeString, wantErr: true},
+		{raw: `42`, valueType: "decimal", wantErr: true},
+		{raw: `null`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			valueType, value, err := decodeValue(json.RawMessage(tt.raw), tt.valueType)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantType, valueType)
+			assert.Equal(t, tt.want, value)
+		})
+	}
+}
+
+func Test_encodeValue(t *testing.T) {
+	assert.JSONEq(t, `"text"`, string(encodeValue(domain.TypeString, "text")))
+	assert.JSONEq(t, `"AAEC"`, string(encodeValue(domain.TypeBytes, "AAEC")))
+	assert.JSONEq(t, `42`, string(encodeValue(domain.TypeInt, "42")))
+	assert.JSONEq(t, `{"a":1}`, string(encodeValue(domain.TypeJson, `{"a":1}`)))
+}
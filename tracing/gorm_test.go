@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"testing"
+)
+
+type item struct {
+	Key string
+}
+
+func TestGormPlugin(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	gormDb, err := gorm.Open(postgres.New(postgres.Config{Conn: db}))
+	assert.NoError(t, err)
+	assert.NoError(t, gormDb.Use(&gormPlugin{tracer: tp.Tracer("test")}))
+
+	mock.ExpectQuery(`SELECT \* FROM "items"`).
+		WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("a"))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	var items []item
+	assert.NoError(t, gormDb.WithContext(ctx).Find(&items).Error)
+	parent.End()
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		query := spans[0]
+		assert.Equal(t, "gorm.query", query.Name())
+		assert.Equal(t, parent.SpanContext().SpanID(), query.Parent().SpanID())
+
+		attrs := map[string]string{}
+		for _, a := range query.Attributes() {
+			attrs[string(a.Key)] = a.Value.Emit()
+		}
+		assert.Equal(t, "postgresql", attrs["db.system"])
+		assert.Equal(t, "items", attrs["db.sql.table"])
+		assert.Equal(t, "1", attrs["db.rows_affected"])
+	}
+}
@@ -75,12 +75,31 @@ func (c *controller) login(ctx *gin.Context) {
 func (c *controller) JwtAuthMiddleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		token := extractToken(ctx)
-		isValid := c.service.VerifyToken(token)
+		id, isValid := c.service.VerifyToken(token)
 		if !isValid {
 			ctx.String(http.StatusUnauthorized, "Unauthorized")
 			ctx.Abort()
 			return
 		}
+		ctx.Request = ctx.Request.WithContext(domain.ContextWithUserId(ctx.Request.Context(), id))
+		ctx.Next()
+	}
+}
+
+// AdminMiddleware only lets through users whose id is in adminIds.
+// It must be used after JwtAuthMiddleware.
+func (c *controller) AdminMiddleware(adminIds []int) gin.HandlerFunc {
+	admins := make(map[int]bool, len(adminIds))
+	for _, id := range adminIds {
+		admins[id] = true
+	}
+
+	return func(ctx *gin.Context) {
+		if !admins[domain.UserIdFromContext(ctx.Request.Context())] {
+			ctx.String(http.StatusForbidden, "Forbidden")
+			ctx.Abort()
+			return
+		}
 		ctx.Next()
 	}
 }
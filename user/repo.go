@@ -3,7 +3,6 @@ package user
 import (
 	"context"
 	"gorm.io/gorm"
-	"log"
 	"storage/domain"
 )
 
@@ -18,10 +17,6 @@ type postgresRepo struct {
 }
 
 func NewPostgresUserRepository(db *gorm.DB) domain.UserRepository {
-	if err := db.AutoMigrate(user{}); err != nil {
-		log.Println(err)
-	}
-
 	return &postgresRepo{db: db}
 }
 
@@ -15,7 +15,7 @@ func NewJwtTokenGenerator(secret string) domain.TokenGenerator {
 	return &jwtTokenGenerator{secret: []byte(secret)}
 }
 
-func (j *jwtTokenGenerator) Verify(tokenStr string) bool {
+func (j *jwtTokenGenerator) Verify(tokenStr string) (int, bool) {
 	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
@@ -23,11 +23,19 @@ func (j *jwtTokenGenerator) Verify(tokenStr string) bool {
 		return j.secret, nil
 	})
 	if err != nil {
-		return false
+		return 0, false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return 0, false
 	}
-	_, ok := token.Claims.(jwt.MapClaims)
-	return ok && token.Valid
 
+	idStr, _ := claims["id"].(string)
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
 }
 
 func (j *jwtTokenGenerator) Generate(id int) (string, error) {
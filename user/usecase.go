@@ -56,6 +56,6 @@ func (s *service) Login(ctx context.Context, u *domain.User) (string, error) {
 	return token, nil
 }
 
-func (s *service) VerifyToken(token string) bool {
+func (s *service) VerifyToken(token string) (int, bool) {
 	return s.tokenGenerator.Verify(token)
 }